@@ -61,3 +61,12 @@ func (slice PluginList) Swap(i, j int) {
 type InstallPluginCommand struct {
 	Version string `json:"version"`
 }
+
+// PluginMetric is a single sample of a plugin's Prometheus metrics.
+// Value is null for NaN and infinite samples.
+type PluginMetric struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Value  *float64          `json:"value"`
+	Type   string            `json:"type"`
+}
@@ -1,12 +1,14 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
@@ -15,6 +17,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana/pkg/api/dtos"
@@ -28,6 +31,9 @@ import (
 	"github.com/grafana/grafana/pkg/util/errutil"
 	"github.com/grafana/grafana/pkg/util/proxyutil"
 	"github.com/grafana/grafana/pkg/web"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"google.golang.org/protobuf/proto"
 )
 
 func (hs *HTTPServer) GetPluginList(c *models.ReqContext) response.Response {
@@ -209,20 +215,129 @@ func (hs *HTTPServer) GetPluginMarkdown(c *models.ReqContext) response.Response
 	return resp
 }
 
-// CollectPluginMetrics collect metrics from a plugin.
+// collectDurationMetric is the synthetic metric reporting how long the metrics of a plugin took to collect
+const collectDurationMetric = "grafana_plugin_metrics_collect_duration_seconds"
+
+// CollectPluginMetrics collect metrics from a plugin. With collectDuration=true, the time it took
+// to collect them is appended as the grafana_plugin_metrics_collect_duration_seconds gauge.
 //
 // /api/plugins/:pluginId/metrics
 func (hs *HTTPServer) CollectPluginMetrics(c *models.ReqContext) response.Response {
 	pluginID := web.Params(c.Req)[":pluginId"]
+	start := time.Now()
 	resp, err := hs.pluginClient.CollectMetrics(c.Req.Context(), &backend.CollectMetricsRequest{PluginContext: backend.PluginContext{PluginID: pluginID}})
 	if err != nil {
 		return translatePluginRequestErrorToAPIError(err)
 	}
 
+	exposition := resp.PrometheusMetrics
+	if c.QueryBool("collectDuration") {
+		exposition, err = appendCollectDuration(exposition, pluginID, time.Since(start))
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to add the collection duration", err)
+		}
+	}
+
+	if strings.Contains(c.Req.Header.Get("Accept"), "application/json") {
+		metrics, err := parsePluginMetrics(exposition)
+		if err != nil {
+			return response.Error(http.StatusInternalServerError, "Failed to parse plugin metrics", err)
+		}
+		return response.JSON(http.StatusOK, metrics)
+	}
+
 	headers := make(http.Header)
 	headers.Set("Content-Type", "text/plain")
 
-	return response.CreateNormalResponse(headers, resp.PrometheusMetrics, http.StatusOK)
+	return response.CreateNormalResponse(headers, exposition, http.StatusOK)
+}
+
+// appendCollectDuration appends the collectDurationMetric gauge of a plugin to its Prometheus text exposition.
+func appendCollectDuration(exposition []byte, pluginID string, duration time.Duration) ([]byte, error) {
+	family := &dto.MetricFamily{
+		Name: proto.String(collectDurationMetric),
+		Help: proto.String("Time taken to collect the metrics of the plugin."),
+		Type: dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{
+			Label: []*dto.LabelPair{{Name: proto.String("plugin"), Value: proto.String(pluginID)}},
+			Gauge: &dto.Gauge{Value: proto.Float64(duration.Seconds())},
+		}},
+	}
+
+	var buf bytes.Buffer
+	buf.Write(exposition)
+	if len(exposition) > 0 && exposition[len(exposition)-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+	if _, err := expfmt.MetricFamilyToText(&buf, family); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// parsePluginMetrics converts the Prometheus text exposition of a plugin into one entry per sample.
+// Summaries and histograms are expanded into their _sum, _count and quantile or _bucket samples.
+func parsePluginMetrics(exposition []byte) ([]dtos.PluginMetric, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(exposition))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	metrics := make([]dtos.PluginMetric, 0)
+	for _, name := range names {
+		family := families[name]
+		metricType := strings.ToLower(family.GetType().String())
+		add := func(name string, labels map[string]string, value float64) {
+			sample := dtos.PluginMetric{Name: name, Labels: labels, Type: metricType}
+			if !math.IsNaN(value) && !math.IsInf(value, 0) {
+				sample.Value = &value
+			}
+			metrics = append(metrics, sample)
+		}
+
+		for _, m := range family.GetMetric() {
+			labels := func(extra ...string) map[string]string {
+				l := make(map[string]string, len(m.GetLabel())+len(extra)/2)
+				for _, pair := range m.GetLabel() {
+					l[pair.GetName()] = pair.GetValue()
+				}
+				for i := 0; i+1 < len(extra); i += 2 {
+					l[extra[i]] = extra[i+1]
+				}
+				return l
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, labels(), m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, labels(), m.GetGauge().GetValue())
+			case dto.MetricType_SUMMARY:
+				for _, q := range m.GetSummary().GetQuantile() {
+					add(name, labels("quantile", fmt.Sprint(q.GetQuantile())), q.GetValue())
+				}
+				add(name+"_sum", labels(), m.GetSummary().GetSampleSum())
+				add(name+"_count", labels(), float64(m.GetSummary().GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				for _, b := range m.GetHistogram().GetBucket() {
+					add(name+"_bucket", labels("le", fmt.Sprint(b.GetUpperBound())), float64(b.GetCumulativeCount()))
+				}
+				add(name+"_sum", labels(), m.GetHistogram().GetSampleSum())
+				add(name+"_count", labels(), float64(m.GetHistogram().GetSampleCount()))
+			default:
+				add(name, labels(), m.GetUntyped().GetValue())
+			}
+		}
+	}
+
+	return metrics, nil
 }
 
 // getPluginAssets returns public plugin assets (images, JS, etc.)
@@ -9,17 +9,21 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/api/response"
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/web"
 )
 
 func Test_GetPluginAssets(t *testing.T) {
@@ -211,6 +215,71 @@ func TestMakePluginResourceRequest(t *testing.T) {
 	require.Equal(t, "sandbox", resp.Header().Get("Content-Security-Policy"))
 }
 
+func TestCollectPluginMetrics(t *testing.T) {
+	exposition := `# HELP plugin_requests_total Total requests.
+# TYPE plugin_requests_total counter
+plugin_requests_total{endpoint="query"} 3
+# HELP plugin_up Whether the plugin is up.
+# TYPE plugin_up gauge
+plugin_up 1
+`
+	hs := HTTPServer{
+		Cfg:          setting.NewCfg(),
+		log:          log.New(),
+		pluginClient: &fakePluginClient{prometheusMetrics: []byte(exposition)},
+	}
+
+	collect := func(accept string, query ...string) response.Response {
+		req := httptest.NewRequest(http.MethodGet, "/api/plugins/test-plugin/metrics"+strings.Join(query, ""), nil)
+		req.Header.Set("Accept", accept)
+		req = web.SetURLParams(req, map[string]string{":pluginId": "test-plugin"})
+		return hs.CollectPluginMetrics(&models.ReqContext{Context: &web.Context{Req: req}})
+	}
+
+	t.Run("should return the exposition as text by default", func(t *testing.T) {
+		resp := collect("")
+		require.Equal(t, http.StatusOK, resp.Status())
+		assert.Equal(t, exposition, string(resp.Body()))
+	})
+
+	t.Run("should return the samples as JSON with an application/json accept header", func(t *testing.T) {
+		resp := collect("application/json")
+		require.Equal(t, http.StatusOK, resp.Status())
+
+		var metrics []map[string]interface{}
+		require.NoError(t, json.Unmarshal(resp.Body(), &metrics))
+		assert.Equal(t, []map[string]interface{}{
+			{"name": "plugin_requests_total", "labels": map[string]interface{}{"endpoint": "query"}, "value": float64(3), "type": "counter"},
+			{"name": "plugin_up", "labels": map[string]interface{}{}, "value": float64(1), "type": "gauge"},
+		}, metrics)
+	})
+
+	t.Run("should append the collection duration when asked for", func(t *testing.T) {
+		resp := collect("", "?collectDuration=true")
+		require.Equal(t, http.StatusOK, resp.Status())
+
+		body := string(resp.Body())
+		assert.True(t, strings.HasPrefix(body, exposition))
+		assert.Contains(t, body, "# TYPE grafana_plugin_metrics_collect_duration_seconds gauge\n")
+		assert.Contains(t, body, `grafana_plugin_metrics_collect_duration_seconds{plugin="test-plugin"} `)
+
+		resp = collect("application/json", "?collectDuration=true")
+		require.Equal(t, http.StatusOK, resp.Status())
+		var metrics []dtos.PluginMetric
+		require.NoError(t, json.Unmarshal(resp.Body(), &metrics))
+		require.Len(t, metrics, 3)
+		assert.Equal(t, "grafana_plugin_metrics_collect_duration_seconds", metrics[0].Name)
+		assert.Equal(t, map[string]string{"plugin": "test-plugin"}, metrics[0].Labels)
+		require.NotNil(t, metrics[0].Value)
+		assert.GreaterOrEqual(t, *metrics[0].Value, float64(0))
+	})
+
+	t.Run("should leave the duration out by default", func(t *testing.T) {
+		resp := collect("")
+		assert.NotContains(t, string(resp.Body()), "grafana_plugin_metrics_collect_duration_seconds")
+	})
+}
+
 func callGetPluginAsset(sc *scenarioContext) {
 	sc.fakeReqWithParams("GET", sc.url, map[string]string{}).exec()
 }
@@ -252,6 +321,12 @@ type fakePluginClient struct {
 	plugins.Client
 
 	req *backend.CallResourceRequest
+
+	prometheusMetrics []byte
+}
+
+func (c *fakePluginClient) CollectMetrics(ctx context.Context, req *backend.CollectMetricsRequest) (*backend.CollectMetricsResult, error) {
+	return &backend.CollectMetricsResult{PrometheusMetrics: c.prometheusMetrics}, nil
 }
 
 func (c *fakePluginClient) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
@@ -62,3 +62,12 @@ type DataSourceCreated struct {
 	UID       string    `json:"uid"`
 	OrgID     int64     `json:"org_id"`
 }
+
+type ServiceAccountTokenRevoked struct {
+	Timestamp        time.Time `json:"timestamp"`
+	ID               int64     `json:"id"`
+	Name             string    `json:"name"`
+	OrgID            int64     `json:"org_id"`
+	ServiceAccountID int64     `json:"service_account_id"`
+	Reason           string    `json:"reason"`
+}
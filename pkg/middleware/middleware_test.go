@@ -197,6 +197,273 @@ func TestMiddlewareContext(t *testing.T) {
 		assert.Equal(t, "Expired API key", sc.respJson["message"])
 	})
 
+	middlewareScenario(t, "Valid API key, but expired, with a custom status code", func(t *testing.T, sc *scenarioContext) {
+		sc.contextHandler.GetTime = fakeGetTime()
+
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			expires := sc.contextHandler.GetTime().Add(-1 * time.Second).Unix()
+			query.Result = &models.ApiKey{OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash,
+				Expires: &expires}
+			return nil
+		})
+
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+
+		assert.Equal(t, 419, sc.resp.Code)
+		assert.Equal(t, "Expired API key", sc.respJson["message"])
+	}, func(cfg *setting.Cfg) {
+		cfg.ApiKeyExpiredStatusCode = 419
+	})
+
+	middlewareScenario(t, "Valid API key, but paused", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash, IsPaused: true}
+			return nil
+		})
+
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+
+		assert.Equal(t, 403, sc.resp.Code)
+		assert.Equal(t, "token paused", sc.respJson["message"])
+	})
+
+	middlewareScenario(t, "Valid API key that was resumed after being paused", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		paused := true
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash, IsPaused: paused}
+			return nil
+		})
+
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+		assert.Equal(t, 403, sc.resp.Code)
+
+		paused = false
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+		assert.Equal(t, 200, sc.resp.Code)
+		assert.True(t, sc.context.IsSignedIn)
+		assert.Equal(t, int64(12), sc.context.OrgId)
+	})
+
+	middlewareScenario(t, "Valid API key of a disabled service account", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		saID := int64(42)
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash, ServiceAccountId: &saID}
+			return nil
+		})
+
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetSignedInUserQuery) error {
+			query.Result = &models.SignedInUser{OrgId: 12, UserId: saID, IsDisabled: true}
+			return nil
+		})
+
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+
+		assert.Equal(t, 401, sc.resp.Code)
+		assert.Equal(t, "Service account is disabled", sc.respJson["message"])
+	})
+
+	middlewareScenario(t, "Valid API key of an expired service account that isn't disabled yet", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		saID := int64(42)
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash, ServiceAccountId: &saID}
+			return nil
+		})
+
+		expiresAt := time.Now().Add(-time.Minute)
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetSignedInUserQuery) error {
+			query.Result = &models.SignedInUser{OrgId: 12, UserId: saID, ExpiresAt: &expiresAt}
+			return nil
+		})
+
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+
+		assert.Equal(t, 401, sc.resp.Code)
+		assert.Equal(t, "Service account has expired", sc.respJson["message"])
+	})
+
+	middlewareScenario(t, "Valid API key of a service account that expires later", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		saID := int64(42)
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash, ServiceAccountId: &saID}
+			return nil
+		})
+
+		expiresAt := time.Now().Add(time.Hour)
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetSignedInUserQuery) error {
+			query.Result = &models.SignedInUser{OrgId: 12, UserId: saID, ExpiresAt: &expiresAt}
+			return nil
+		})
+
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+
+		assert.Equal(t, 200, sc.resp.Code)
+		assert.True(t, sc.context.IsSignedIn)
+		assert.Equal(t, saID, sc.context.UserId)
+	})
+
+	middlewareScenario(t, "Valid read-only API key of a service account", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		saID := int64(42)
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash, ServiceAccountId: &saID, IsReadOnly: true}
+			return nil
+		})
+
+		cached := &models.SignedInUser{OrgId: 12, UserId: saID, OrgRole: models.ROLE_EDITOR}
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetSignedInUserQuery) error {
+			query.Result = cached
+			return nil
+		})
+
+		sc.m.Post("/api/dashboards/db", ReqEditorRole, sc.defaultHandler)
+		sc.fakeReq("POST", "/api/dashboards/db").withValidApiKey().exec()
+
+		assert.Equal(t, 403, sc.resp.Code)
+
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+		assert.Equal(t, 200, sc.resp.Code)
+		assert.True(t, sc.context.IsReadOnly)
+		assert.Equal(t, models.ROLE_VIEWER, sc.context.OrgRole)
+		// the user shared with the other keys of the service account isn't restricted
+		assert.False(t, cached.IsReadOnly)
+		assert.Equal(t, models.ROLE_EDITOR, cached.OrgRole)
+	})
+
+	middlewareScenario(t, "Valid API key records its last use", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		var lastUsedAt *time.Time
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{Id: 10, OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash, LastUsedAt: lastUsedAt}
+			return nil
+		})
+		var updates []*models.UpdateApiKeyLastUsedCommand
+		bus.AddHandler("test", func(ctx context.Context, cmd *models.UpdateApiKeyLastUsedCommand) error {
+			updates = append(updates, cmd)
+			lastUsedAt = &cmd.LastUsedAt
+			return nil
+		})
+
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+		assert.Equal(t, 200, sc.resp.Code)
+		require.Len(t, updates, 1)
+		assert.Equal(t, int64(10), updates[0].Id)
+
+		// used again within the throttling interval
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+		assert.Equal(t, 200, sc.resp.Code)
+		assert.Len(t, updates, 1)
+	})
+
+	middlewareScenario(t, "Valid API key of a disabled service account doesn't record its last use", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		saID := int64(42)
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{Id: 10, OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash, ServiceAccountId: &saID}
+			return nil
+		})
+		disabled := true
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetSignedInUserQuery) error {
+			query.Result = &models.SignedInUser{OrgId: 12, UserId: saID, IsDisabled: disabled}
+			return nil
+		})
+		var updates []*models.UpdateApiKeyLastUsedCommand
+		bus.AddHandler("test", func(ctx context.Context, cmd *models.UpdateApiKeyLastUsedCommand) error {
+			updates = append(updates, cmd)
+			return nil
+		})
+
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+		assert.Equal(t, 401, sc.resp.Code)
+		assert.Empty(t, updates)
+
+		disabled = false
+		sc.fakeReq("GET", "/").withValidApiKey().exec()
+		assert.Equal(t, 200, sc.resp.Code)
+		require.Len(t, updates, 1)
+		assert.Equal(t, int64(10), updates[0].Id)
+	})
+
+	middlewareScenario(t, "Valid API key from an allowed IP address", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash,
+				IpAllowlist: "192.168.0.0/16,10.0.0.0/8"}
+			return nil
+		})
+
+		sc.fakeReq("GET", "/")
+		sc.req.RemoteAddr = "10.1.2.3:12345"
+		sc.withValidApiKey().exec()
+
+		assert.Equal(t, 200, sc.resp.Code)
+		assert.True(t, sc.context.IsSignedIn)
+		assert.Equal(t, int64(12), sc.context.OrgId)
+	})
+
+	middlewareScenario(t, "Valid API key from a blocked IP address", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash,
+				IpAllowlist: "192.168.0.0/16,10.0.0.0/8"}
+			return nil
+		})
+
+		sc.fakeReq("GET", "/")
+		sc.req.RemoteAddr = "172.16.0.1:12345"
+		sc.withValidApiKey().exec()
+
+		assert.Equal(t, 403, sc.resp.Code)
+		assert.Equal(t, "API key is not allowed from this IP address", sc.respJson["message"])
+	})
+
+	middlewareScenario(t, "Valid API key from a blocked IP address claiming an allowed one", func(t *testing.T, sc *scenarioContext) {
+		keyhash, err := util.EncodePassword("v5nAwpMafFP6znaS4urhdWDLS5511M42", "asd")
+		require.NoError(t, err)
+
+		bus.AddHandler("test", func(ctx context.Context, query *models.GetApiKeyByNameQuery) error {
+			query.Result = &models.ApiKey{OrgId: 12, Role: models.ROLE_EDITOR, Key: keyhash,
+				IpAllowlist: "192.168.0.0/16,10.0.0.0/8"}
+			return nil
+		})
+
+		sc.fakeReq("GET", "/")
+		sc.req.RemoteAddr = "172.16.0.1:12345"
+		sc.req.Header.Set("X-Real-IP", "10.1.2.3")
+		sc.req.Header.Set("X-Forwarded-For", "10.1.2.3")
+		sc.withValidApiKey().exec()
+
+		assert.Equal(t, 403, sc.resp.Code)
+		assert.Equal(t, "API key is not allowed from this IP address", sc.respJson["message"])
+	})
+
 	middlewareScenario(t, "Non-expired auth token in cookie which is not being rotated", func(
 		t *testing.T, sc *scenarioContext) {
 		const userID int64 = 12
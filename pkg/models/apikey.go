@@ -16,12 +16,22 @@ type ApiKey struct {
 	Id               int64
 	OrgId            int64
 	Name             string
-	Key              string
+	Key              string `json:"-"`
 	Role             RoleType
 	Created          time.Time
 	Updated          time.Time
 	Expires          *int64
 	ServiceAccountId *int64
+	// IpAllowlist is a comma-separated list of CIDRs the key may be used from.
+	// An empty list means the key isn't restricted. It is checked against the address of the connection,
+	// forwarded headers are ignored, so behind a proxy it must allow the address of the proxy.
+	IpAllowlist string
+	// IsPaused rejects the key at authentication time without deleting it.
+	IsPaused bool
+	// LastUsedAt is when the key last authenticated a request, nil if it never did.
+	LastUsedAt *time.Time
+	// IsReadOnly limits the key to read actions, on top of what its role allows.
+	IsReadOnly bool
 }
 
 // ---------------------
@@ -32,6 +42,8 @@ type AddApiKeyCommand struct {
 	OrgId         int64    `json:"-"`
 	Key           string   `json:"-"`
 	SecondsToLive int64    `json:"secondsToLive"`
+	IpAllowlist   []string `json:"ipAllowlist"`
+	ReadOnly      bool     `json:"readOnly"`
 	Result        *ApiKey  `json:"-"`
 }
 
@@ -40,6 +52,11 @@ type DeleteApiKeyCommand struct {
 	OrgId int64 `json:"-"`
 }
 
+type UpdateApiKeyLastUsedCommand struct {
+	Id         int64
+	LastUsedAt time.Time
+}
+
 // ----------------------
 // QUERIES
 
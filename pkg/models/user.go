@@ -41,6 +41,8 @@ type User struct {
 	Created    time.Time
 	Updated    time.Time
 	LastSeenAt time.Time
+	// ExpiresAt is when a service account gets disabled. Nil means it doesn't expire.
+	ExpiresAt *time.Time
 }
 
 func (u *User) NameOrFallback() string {
@@ -180,9 +182,14 @@ type SignedInUser struct {
 	OrgCount       int
 	IsGrafanaAdmin bool
 	IsAnonymous    bool
+	IsDisabled     bool
 	HelpFlags1     HelpFlags1
 	LastSeenAt     time.Time
-	Teams          []int64
+	// ExpiresAt is when a service account expires. Nil means it doesn't expire.
+	ExpiresAt *time.Time
+	Teams     []int64
+	// IsReadOnly limits the user to read actions. It's set for requests authenticated with a read-only API key.
+	IsReadOnly bool
 	// Permissions grouped by orgID and actions
 	Permissions map[int64]map[string][]string `json:"-"`
 }
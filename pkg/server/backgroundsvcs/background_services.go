@@ -21,7 +21,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/provisioning"
 	"github.com/grafana/grafana/pkg/services/rendering"
 	secretsManager "github.com/grafana/grafana/pkg/services/secrets/manager"
-	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+	serviceaccountsmanager "github.com/grafana/grafana/pkg/services/serviceaccounts/manager"
 	"github.com/grafana/grafana/pkg/services/thumbs"
 	"github.com/grafana/grafana/pkg/services/updatechecker"
 )
@@ -34,9 +34,10 @@ func ProvideBackgroundServiceRegistry(
 	grafanaUpdateChecker *updatechecker.GrafanaService, pluginsUpdateChecker *updatechecker.PluginsService,
 	metrics *metrics.InternalMetricsService, secretsService *secretsManager.SecretsService,
 	remoteCache *remotecache.RemoteCache, thumbnailsService thumbs.Service,
+	serviceAccountsService *serviceaccountsmanager.ServiceAccountsService,
 	// Need to make sure these are initialized, is there a better place to put them?
 	_ *dashboardsnapshots.Service, _ *alerting.AlertNotificationService,
-	_ serviceaccounts.Service, _ *guardian.Provider,
+	_ *guardian.Provider,
 	_ *plugindashboardsservice.DashboardUpdater,
 ) *BackgroundServiceRegistry {
 	return NewBackgroundServiceRegistry(
@@ -58,7 +59,8 @@ func ProvideBackgroundServiceRegistry(
 		tracing,
 		remoteCache,
 		secretsService,
-		thumbnailsService)
+		thumbnailsService,
+		serviceAccountsService)
 }
 
 // BackgroundServiceRegistry provides background services.
@@ -139,6 +139,19 @@ func GroupScopesByAction(permissions []*Permission) map[string][]string {
 	return m
 }
 
+// IsReadAction reports whether action only reads, like dashboards:read or datasources:query.
+func IsReadAction(action string) bool {
+	i := strings.LastIndex(action, ":")
+	if i < 0 {
+		return false
+	}
+	switch action[i+1:] {
+	case "read", "list", "query", "explore":
+		return true
+	}
+	return false
+}
+
 func ValidateScope(scope string) bool {
 	prefix, last := scope[:len(scope)-1], scope[len(scope)-1]
 	// verify that last char is either ':' or '/' if last character of scope is '*'
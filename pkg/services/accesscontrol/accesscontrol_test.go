@@ -97,3 +97,24 @@ func TestGetResourcesMetadata(t *testing.T) {
 		})
 	}
 }
+
+func TestIsReadAction(t *testing.T) {
+	tests := []struct {
+		action string
+		want   bool
+	}{
+		{action: "dashboards:read", want: true},
+		{action: "users.authtoken:list", want: true},
+		{action: "datasources:query", want: true},
+		{action: "datasources:explore", want: true},
+		{action: "dashboards:write", want: false},
+		{action: "dashboards:create", want: false},
+		{action: "dashboards.permissions:write", want: false},
+		{action: "read", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsReadAction(tt.action))
+		})
+	}
+}
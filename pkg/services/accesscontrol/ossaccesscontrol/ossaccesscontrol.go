@@ -125,6 +125,10 @@ func (ac *OSSAccessControlService) GetUserPermissions(ctx context.Context, user
 	resolved := make([]*accesscontrol.Permission, 0, len(permissions))
 	keywordMutator := ac.scopeResolver.GetResolveKeywordScopeMutator(user)
 	for _, p := range permissions {
+		// read-only users keep their read actions only
+		if user.IsReadOnly && !accesscontrol.IsReadAction(p.Action) {
+			continue
+		}
 		// if the permission has a keyword in its scope it will be resolved
 		p.Scope, err = keywordMutator(ctx, p.Scope)
 		if err != nil {
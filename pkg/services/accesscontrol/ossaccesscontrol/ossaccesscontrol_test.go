@@ -570,6 +570,8 @@ func TestOSSAccessControlService_Evaluate(t *testing.T) {
 		Name:    "Test User",
 		Email:   "testuser@example.org",
 	}
+	readOnlyUser := testUser
+	readOnlyUser.IsReadOnly = true
 	registration := accesscontrol.RoleRegistration{
 		Role: accesscontrol.RoleDTO{
 			Version:     1,
@@ -611,6 +613,22 @@ func TestOSSAccessControlService_Evaluate(t *testing.T) {
 			wantAccess: true,
 			wantErr:    false,
 		},
+		{
+			name:       "Should allow a read action to a read-only user",
+			user:       readOnlyUser,
+			rawPerm:    accesscontrol.Permission{Action: "dashboards:read", Scope: "dashboards:*"},
+			evaluator:  accesscontrol.EvalPermission("dashboards:read", "dashboards:uid:1"),
+			wantAccess: true,
+			wantErr:    false,
+		},
+		{
+			name:       "Should deny a write action to a read-only user",
+			user:       readOnlyUser,
+			rawPerm:    accesscontrol.Permission{Action: "dashboards:write", Scope: "dashboards:*"},
+			evaluator:  accesscontrol.EvalPermission("dashboards:write", "dashboards:uid:1"),
+			wantAccess: false,
+			wantErr:    false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -4,6 +4,7 @@ package contexthandler
 import (
 	"context"
 	"errors"
+	"net"
 	"net/url"
 	"strconv"
 	"strings"
@@ -178,6 +179,22 @@ func (h *ContextHandler) initContextWithAnonymousUser(reqContext *models.ReqCont
 	return true
 }
 
+// apiKeyLastUsedInterval throttles the updates of the last use of API keys,
+// a key used more often is only written once per interval.
+const apiKeyLastUsedInterval = time.Minute
+
+// updateAPIKeyLastUsed records the use of an API key unless it was recorded within apiKeyLastUsedInterval.
+// Failing to record it doesn't fail the request.
+func updateAPIKeyLastUsed(reqContext *models.ReqContext, apikey *models.ApiKey, now time.Time) {
+	if apikey.LastUsedAt != nil && now.Sub(*apikey.LastUsedAt) < apiKeyLastUsedInterval {
+		return
+	}
+	cmd := models.UpdateApiKeyLastUsedCommand{Id: apikey.Id, LastUsedAt: now}
+	if err := bus.Dispatch(reqContext.Req.Context(), &cmd); err != nil {
+		reqContext.Logger.Warn("Failed to update the last use of the API key", "id", apikey.Id, "err", err)
+	}
+}
+
 func (h *ContextHandler) initContextWithAPIKey(reqContext *models.ReqContext) bool {
 	header := reqContext.Req.Header.Get("Authorization")
 	parts := strings.SplitN(header, " ", 2)
@@ -231,17 +248,40 @@ func (h *ContextHandler) initContextWithAPIKey(reqContext *models.ReqContext) bo
 		getTime = time.Now
 	}
 	if apikey.Expires != nil && *apikey.Expires <= getTime().Unix() {
-		reqContext.JsonApiErr(401, "Expired API key", err)
+		status := h.Cfg.ApiKeyExpiredStatusCode
+		if status == 0 {
+			status = 401
+		}
+		reqContext.JsonApiErr(status, "Expired API key", err)
+		return true
+	}
+
+	if apikey.IsPaused {
+		reqContext.JsonApiErr(403, "token paused", nil)
 		return true
 	}
 
+	if apikey.IpAllowlist != "" {
+		// check the address of the connection, X-Real-IP and X-Forwarded-For are set by the client
+		ip, err := network.GetIPFromAddress(reqContext.Req.RemoteAddr)
+		if err != nil || !isIPAllowed(ip, apikey.IpAllowlist) {
+			reqContext.JsonApiErr(403, "API key is not allowed from this IP address", err)
+			return true
+		}
+	}
+
 	if apikey.ServiceAccountId == nil || *apikey.ServiceAccountId < 1 { //There is no service account attached to the apikey
+		updateAPIKeyLastUsed(reqContext, apikey, getTime())
+
 		//Use the old APIkey method.  This provides backwards compatibility.
 		reqContext.SignedInUser = &models.SignedInUser{}
 		reqContext.OrgRole = apikey.Role
 		reqContext.ApiKeyId = apikey.Id
 		reqContext.OrgId = apikey.OrgId
 		reqContext.IsSignedIn = true
+		if apikey.IsReadOnly {
+			restrictToReadOnly(reqContext.SignedInUser)
+		}
 		return true
 	}
 
@@ -260,11 +300,56 @@ func (h *ContextHandler) initContextWithAPIKey(reqContext *models.ReqContext) bo
 		return true
 	}
 
+	if query.Result.IsDisabled {
+		reqContext.JsonApiErr(401, "Service account is disabled", nil)
+		return true
+	}
+
+	// the account may not be disabled yet, that only happens periodically once it has expired
+	if query.Result.ExpiresAt != nil && !query.Result.ExpiresAt.After(getTime()) {
+		reqContext.JsonApiErr(401, "Service account has expired", nil)
+		return true
+	}
+
+	// only uses that authenticate count, the rejected ones would keep dormant tokens from being revoked
+	updateAPIKeyLastUsed(reqContext, apikey, getTime())
+
 	reqContext.IsSignedIn = true
 	reqContext.SignedInUser = query.Result
+	if apikey.IsReadOnly {
+		// the signed in user can be shared through the cache, restrict a copy of it
+		user := *query.Result
+		user.Permissions = nil
+		restrictToReadOnly(&user)
+		reqContext.SignedInUser = &user
+	}
 	return true
 }
 
+// restrictToReadOnly limits user to reading: its role is lowered to Viewer, and access control
+// only grants it the read actions of its permissions.
+func restrictToReadOnly(user *models.SignedInUser) {
+	user.IsReadOnly = true
+	user.IsGrafanaAdmin = false
+	if user.OrgRole.Includes(models.ROLE_VIEWER) {
+		user.OrgRole = models.ROLE_VIEWER
+	}
+}
+
+// isIPAllowed checks ip against a comma-separated list of CIDRs.
+func isIPAllowed(ip net.IP, allowlist string) bool {
+	for _, cidr := range strings.Split(allowlist, ",") {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			continue
+		}
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *ContextHandler) initContextWithBasicAuth(reqContext *models.ReqContext, orgID int64) bool {
 	if !h.Cfg.BasicAuthEnabled {
 		return false
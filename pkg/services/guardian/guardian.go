@@ -88,6 +88,10 @@ func (g *dashboardGuardianImpl) CanCreate(_ int64, _ bool) (bool, error) {
 }
 
 func (g *dashboardGuardianImpl) HasPermission(permission models.PermissionType) (bool, error) {
+	if g.user.IsReadOnly && permission > models.PERMISSION_VIEW {
+		return g.logHasPermissionResult(permission, false, nil)
+	}
+
 	if g.user.OrgRole == models.ROLE_ADMIN {
 		return g.logHasPermissionResult(permission, true, nil)
 	}
@@ -2,8 +2,12 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/api/response"
@@ -17,6 +21,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/serviceaccounts"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts/database"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
 	"github.com/grafana/grafana/pkg/web"
 )
 
@@ -57,78 +62,238 @@ func (api *ServiceAccountsAPI) RegisterAPIEndpoints(
 	api.RouterRegister.Group("/api/serviceaccounts", func(serviceAccountsRoute routing.RouteRegister) {
 		serviceAccountsRoute.Get("/search", auth(middleware.ReqOrgAdmin,
 			accesscontrol.EvalPermission(serviceaccounts.ActionRead)), routing.Wrap(api.SearchOrgServiceAccountsWithPaging))
+		serviceAccountsRoute.Post("/tokens/list", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionRead)), routing.Wrap(api.ListTokensForServiceAccounts))
+		serviceAccountsRoute.Get("/tokens/dormant", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionRead, serviceaccounts.ScopeAll)), routing.Wrap(api.ListDormantTokens))
+		serviceAccountsRoute.Get("/tokens/expiringSoon", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionRead, serviceaccounts.ScopeAll)), routing.Wrap(api.ListExpiringTokens))
+		serviceAccountsRoute.Get("/quota", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionRead, serviceaccounts.ScopeAll)), routing.Wrap(api.GetServiceAccountsQuota))
+		serviceAccountsRoute.Get("/stats", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionRead, serviceaccounts.ScopeAll)), routing.Wrap(api.GetServiceAccountsStats))
+		serviceAccountsRoute.Get("/export", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionRead, serviceaccounts.ScopeAll)), routing.Wrap(api.ExportServiceAccounts))
+		serviceAccountsRoute.Get("/available", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionCreate)), routing.Wrap(api.IsServiceAccountNameAvailable))
 		serviceAccountsRoute.Post("/", auth(middleware.ReqOrgAdmin,
 			accesscontrol.EvalPermission(serviceaccounts.ActionCreate)), routing.Wrap(api.CreateServiceAccount))
+		serviceAccountsRoute.Post("/validate", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionCreate)), routing.Wrap(api.ValidateServiceAccounts))
+		serviceAccountsRoute.Post("/provision", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalAll(
+				accesscontrol.EvalPermission(serviceaccounts.ActionCreate),
+				accesscontrol.EvalPermission(serviceaccounts.ActionWrite, serviceaccounts.ScopeAll),
+			)), routing.Wrap(api.ProvisionServiceAccount))
 		serviceAccountsRoute.Get("/:serviceAccountId", auth(middleware.ReqOrgAdmin,
 			accesscontrol.EvalPermission(serviceaccounts.ActionRead, serviceaccounts.ScopeID)), routing.Wrap(api.RetrieveServiceAccount))
+		serviceAccountsRoute.Get("/:serviceAccountId/activity", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionRead, serviceaccounts.ScopeID)), routing.Wrap(api.GetServiceAccountActivity))
 		serviceAccountsRoute.Patch("/:serviceAccountId", auth(middleware.ReqOrgAdmin,
 			accesscontrol.EvalPermission(serviceaccounts.ActionWrite, serviceaccounts.ScopeID)), routing.Wrap(api.updateServiceAccount))
+		serviceAccountsRoute.Post("/:serviceAccountId/disable", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionWrite, serviceaccounts.ScopeID)), routing.Wrap(api.DisableServiceAccount))
+		serviceAccountsRoute.Post("/:serviceAccountId/enable", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionWrite, serviceaccounts.ScopeID)), routing.Wrap(api.EnableServiceAccount))
 		serviceAccountsRoute.Delete("/:serviceAccountId", auth(middleware.ReqOrgAdmin,
 			accesscontrol.EvalPermission(serviceaccounts.ActionDelete, serviceaccounts.ScopeID)), routing.Wrap(api.DeleteServiceAccount))
+		serviceAccountsRoute.Delete("/byName/:name", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionDelete)), routing.Wrap(api.DeleteServiceAccountByName))
 		serviceAccountsRoute.Post("/upgradeall", auth(middleware.ReqOrgAdmin,
 			accesscontrol.EvalPermission(serviceaccounts.ActionCreate)), routing.Wrap(api.UpgradeServiceAccounts))
 		serviceAccountsRoute.Post("/convert/:keyId", auth(middleware.ReqOrgAdmin,
 			accesscontrol.EvalPermission(serviceaccounts.ActionCreate, serviceaccounts.ScopeID)), routing.Wrap(api.ConvertToServiceAccount))
 		serviceAccountsRoute.Get("/:serviceAccountId/tokens", auth(middleware.ReqOrgAdmin,
 			accesscontrol.EvalPermission(serviceaccounts.ActionRead, serviceaccounts.ScopeID)), routing.Wrap(api.ListTokens))
+		serviceAccountsRoute.Get("/:serviceAccountId/tokens/:tokenId", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionRead, serviceaccounts.ScopeID)), routing.Wrap(api.GetToken))
 		serviceAccountsRoute.Post("/:serviceAccountId/tokens", auth(middleware.ReqOrgAdmin,
 			accesscontrol.EvalPermission(serviceaccounts.ActionWrite, serviceaccounts.ScopeID)), routing.Wrap(api.CreateToken))
+		serviceAccountsRoute.Patch("/:serviceAccountId/tokens/:tokenId", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionWrite, serviceaccounts.ScopeID)), routing.Wrap(api.UpdateToken))
+		serviceAccountsRoute.Post("/:serviceAccountId/tokens/:tokenId/rotate", auth(middleware.ReqOrgAdmin,
+			accesscontrol.EvalPermission(serviceaccounts.ActionWrite, serviceaccounts.ScopeID)), routing.Wrap(api.RotateToken))
 		serviceAccountsRoute.Delete("/:serviceAccountId/tokens/:tokenId", auth(middleware.ReqOrgAdmin,
 			accesscontrol.EvalPermission(serviceaccounts.ActionWrite, serviceaccounts.ScopeID)), routing.Wrap(api.DeleteToken))
-	})
+	}, routing.Wrap(api.requireSupportedCasing))
 }
 
 // POST /api/serviceaccounts
 func (api *ServiceAccountsAPI) CreateServiceAccount(c *models.ReqContext) response.Response {
-	type createServiceAccountForm struct {
-		Name string `json:"name" binding:"Required"`
-	}
-	cmd := createServiceAccountForm{}
+	cmd := serviceaccounts.CreateServiceAccountForm{}
 	if err := web.Bind(c.Req, &cmd); err != nil {
-		return response.Error(http.StatusBadRequest, "Bad request data", err)
+		return api.bindErrorResponse(c, err)
+	}
+	if err := serviceaccounts.CheckNameAllowed(api.cfg, cmd.Name); err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	}
+	if cmd.Role == nil {
+		cmd.Role = api.defaultRole()
+	} else if !cmd.Role.IsValid() {
+		return api.errorResponse(c, http.StatusBadRequest, "Invalid role specified", nil)
 	}
 
-	serviceAccount, err := api.store.CreateServiceAccount(c.Req.Context(), c.OrgId, cmd.Name)
+	if resp := api.checkOrgLimit(c); resp != nil {
+		return resp
+	}
+
+	serviceAccount, err := api.store.CreateServiceAccount(c.Req.Context(), c.OrgId, &cmd)
 	switch {
-	case errors.Is(err, &database.ErrSAInvalidName{}):
-		return response.Error(http.StatusBadRequest, "Invalid service account name", err)
+	case errors.Is(err, serviceaccounts.ErrServiceAccountAlreadyExists):
+		return api.errorResponse(c, http.StatusConflict, "a service account with that name already exists in this organization", err)
+	case errors.As(err, new(*database.ErrSAInvalidLogin)):
+		return api.errorResponse(c, http.StatusBadRequest, err.Error(), nil)
 	case err != nil:
-		return response.Error(http.StatusInternalServerError, "Failed to create service account", err)
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to create service account", err)
 	}
+	api.auditLog(c, auditCreateServiceAccount, serviceAccount.Id)
+
+	return api.jsonResponse(c, http.StatusCreated, serviceAccount).
+		SetHeader("Location", fmt.Sprintf("%s/api/serviceaccounts/%d", api.cfg.AppSubURL, serviceAccount.Id))
+}
 
-	return response.JSON(http.StatusCreated, serviceAccount)
+// checkOrgLimit returns a forbidden response when the org has as many service accounts as it may have,
+// and nil when another one can be created.
+func (api *ServiceAccountsAPI) checkOrgLimit(c *models.ReqContext) response.Response {
+	err := serviceaccounts.CheckOrgLimit(c.Req.Context(), api.cfg, api.store, c.OrgId)
+	switch {
+	case errors.Is(err, serviceaccounts.ErrServiceAccountQuotaReached):
+		return api.errorResponse(c, http.StatusForbidden, fmt.Sprintf(
+			"Service account quota reached, an organization can have at most %d service accounts. "+
+				"Delete unused service accounts or ask a server admin to raise the limit.", serviceaccounts.OrgLimit(api.cfg)), nil)
+	case err != nil:
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to count service accounts", err)
+	}
+	return nil
+}
+
+// defaultRole returns the role of service accounts created without one, Viewer unless configured otherwise
+func (api *ServiceAccountsAPI) defaultRole() *models.RoleType {
+	role := models.RoleType(api.cfg.ServiceAccountDefaultRole)
+	if !role.IsValid() {
+		role = models.ROLE_VIEWER
+	}
+	return &role
+}
+
+// GET /api/serviceaccounts/available?name=foo
+func (api *ServiceAccountsAPI) IsServiceAccountNameAvailable(c *models.ReqContext) response.Response {
+	name := c.Query("name")
+	if name == "" {
+		return api.errorResponse(c, http.StatusBadRequest, "name is required", nil)
+	}
+
+	available, err := api.store.IsServiceAccountNameAvailable(c.Req.Context(), name)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to check service account name", err)
+	}
+
+	return api.jsonResponse(c, http.StatusOK, util.DynMap{"name": name, "available": available})
+}
+
+// GET /api/serviceaccounts/quota
+func (api *ServiceAccountsAPI) GetServiceAccountsQuota(c *models.ReqContext) response.Response {
+	used, err := api.store.CountServiceAccounts(c.Req.Context(), c.OrgId)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to count service accounts", err)
+	}
+
+	quota := serviceaccounts.ServiceAccountsQuotaDTO{Used: used, Limit: -1, Remaining: -1}
+	if limit := serviceaccounts.OrgLimit(api.cfg); limit >= 0 {
+		quota.Limit = limit
+		quota.Remaining = quota.Limit - used
+		if quota.Remaining < 0 {
+			quota.Remaining = 0
+		}
+	}
+
+	return api.jsonResponse(c, http.StatusOK, quota)
+}
+
+// GET /api/serviceaccounts/stats
+func (api *ServiceAccountsAPI) GetServiceAccountsStats(c *models.ReqContext) response.Response {
+	stats, err := api.store.GetServiceAccountsStats(c.Req.Context(), c.OrgId)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to get service account stats", err)
+	}
+	return api.jsonResponse(c, http.StatusOK, stats)
 }
 
 func (api *ServiceAccountsAPI) DeleteServiceAccount(ctx *models.ReqContext) response.Response {
 	scopeID, err := strconv.ParseInt(web.Params(ctx.Req)[":serviceAccountId"], 10, 64)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "serviceAccountId is invalid", err)
+		return api.errorResponse(ctx, http.StatusBadRequest, "serviceAccountId is invalid", err)
 	}
 	err = api.service.DeleteServiceAccount(ctx.Req.Context(), ctx.OrgId, scopeID)
 	if err != nil {
-		return response.Error(http.StatusInternalServerError, "Service account deletion error", err)
+		return api.errorResponse(ctx, http.StatusInternalServerError, "Service account deletion error", err)
 	}
-	return response.Success("Service account deleted")
+	api.auditLog(ctx, auditDeleteServiceAccount, scopeID)
+	return api.successResponse(ctx, "Service account deleted")
+}
+
+// DELETE /api/serviceaccounts/byName/:name
+func (api *ServiceAccountsAPI) DeleteServiceAccountByName(c *models.ReqContext) response.Response {
+	name := web.Params(c.Req)[":name"]
+	ids, err := api.store.GetServiceAccountIDsByName(c.Req.Context(), c.OrgId, name)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to look up service account", err)
+	}
+	switch {
+	case len(ids) == 0:
+		return api.errorResponse(c, http.StatusNotFound, "Service account not found", nil)
+	case len(ids) > 1:
+		return api.errorResponse(c, http.StatusConflict, fmt.Sprintf("%d service accounts are named %q, delete by ID instead", len(ids), name), nil)
+	}
+
+	// the route only checks the action, the scope can be checked once the name is resolved
+	if !api.accesscontrol.IsDisabled() {
+		scope := accesscontrol.Scope("serviceaccounts", "id", strconv.FormatInt(ids[0], 10))
+		hasAccess, err := api.accesscontrol.Evaluate(c.Req.Context(), c.SignedInUser,
+			accesscontrol.EvalPermission(serviceaccounts.ActionDelete, scope))
+		if err != nil {
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to evaluate permissions", err)
+		}
+		if !hasAccess {
+			return api.errorResponse(c, http.StatusForbidden, "Not allowed to delete this service account", nil)
+		}
+	}
+
+	if err := api.service.DeleteServiceAccount(c.Req.Context(), c.OrgId, ids[0]); err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Service account deletion error", err)
+	}
+	api.auditLog(c, auditDeleteServiceAccount, ids[0])
+	return api.successResponse(c, "Service account deleted")
 }
 
 func (api *ServiceAccountsAPI) UpgradeServiceAccounts(ctx *models.ReqContext) response.Response {
 	if err := api.store.UpgradeServiceAccounts(ctx.Req.Context()); err == nil {
-		return response.Success("Service accounts upgraded")
+		return api.successResponse(ctx, "Service accounts upgraded")
 	} else {
-		return response.Error(http.StatusInternalServerError, "Internal server error", err)
+		return api.errorResponse(ctx, http.StatusInternalServerError, "Internal server error", err)
 	}
 }
 
 func (api *ServiceAccountsAPI) ConvertToServiceAccount(ctx *models.ReqContext) response.Response {
 	keyId, err := strconv.ParseInt(web.Params(ctx.Req)[":keyId"], 10, 64)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "Key ID is invalid", err)
+		return api.errorResponse(ctx, http.StatusBadRequest, "Key ID is invalid", err)
 	}
-	if err := api.store.ConvertToServiceAccounts(ctx.Req.Context(), []int64{keyId}); err == nil {
-		return response.Success("Service accounts converted")
-	} else {
-		return response.Error(500, "Internal server error", err)
+	converted, err := api.store.ConvertToServiceAccounts(ctx.Req.Context(), []int64{keyId})
+	if err != nil {
+		return api.errorResponse(ctx, 500, "Internal server error", err)
+	}
+
+	result := make([]serviceaccounts.ConvertedApiKeyDTO, 0, len(converted))
+	for keyID, serviceAccountID := range converted {
+		result = append(result, serviceaccounts.ConvertedApiKeyDTO{KeyId: keyID, ServiceAccountId: serviceAccountID})
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].KeyId < result[j].KeyId })
+
+	return api.jsonResponse(ctx, http.StatusOK, util.DynMap{
+		"message":   "Service accounts converted",
+		"converted": result,
+	})
 }
 
 func (api *ServiceAccountsAPI) getAccessControlMetadata(c *models.ReqContext, saIDs map[string]bool) map[string]accesscontrol.Metadata {
@@ -151,16 +316,21 @@ func (api *ServiceAccountsAPI) getAccessControlMetadata(c *models.ReqContext, sa
 func (api *ServiceAccountsAPI) RetrieveServiceAccount(ctx *models.ReqContext) response.Response {
 	scopeID, err := strconv.ParseInt(web.Params(ctx.Req)[":serviceAccountId"], 10, 64)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "Service Account ID is invalid", err)
+		return api.errorResponse(ctx, http.StatusBadRequest, "Service Account ID is invalid", err)
+	}
+
+	loc, err := requestLocation(ctx)
+	if err != nil {
+		return api.errorResponse(ctx, http.StatusBadRequest, "Invalid time zone", err)
 	}
 
 	serviceAccount, err := api.store.RetrieveServiceAccount(ctx.Req.Context(), ctx.OrgId, scopeID)
 	if err != nil {
 		switch {
 		case errors.Is(err, serviceaccounts.ErrServiceAccountNotFound):
-			return response.Error(http.StatusNotFound, "Failed to retrieve service account", err)
+			return api.errorResponse(ctx, http.StatusNotFound, "Failed to retrieve service account", err)
 		default:
-			return response.Error(http.StatusInternalServerError, "Failed to retrieve service account", err)
+			return api.errorResponse(ctx, http.StatusInternalServerError, "Failed to retrieve service account", err)
 		}
 	}
 
@@ -168,40 +338,137 @@ func (api *ServiceAccountsAPI) RetrieveServiceAccount(ctx *models.ReqContext) re
 	metadata := api.getAccessControlMetadata(ctx, map[string]bool{saIDString: true})
 	serviceAccount.AvatarUrl = dtos.GetGravatarUrlWithDefault("", serviceAccount.Name)
 	serviceAccount.AccessControl = metadata[saIDString]
-	return response.JSON(http.StatusOK, serviceAccount)
+	serviceAccount.Created = serviceAccount.Created.In(loc)
+	serviceAccount.Updated = serviceAccount.Updated.In(loc)
+	serviceAccount.LastSeenAt = serviceAccount.LastSeenAt.In(loc)
+	if serviceAccount.ExpiresAt != nil {
+		expiresAt := serviceAccount.ExpiresAt.In(loc)
+		serviceAccount.ExpiresAt = &expiresAt
+	}
+	if accepts(ctx, halContentType) {
+		return api.halResponse(ctx, serviceAccount)
+	}
+	return api.jsonResponse(ctx, http.StatusOK, serviceAccount)
+}
+
+// GET /api/serviceaccounts/:serviceAccountId/activity
+func (api *ServiceAccountsAPI) GetServiceAccountActivity(c *models.ReqContext) response.Response {
+	scopeID, err := strconv.ParseInt(web.Params(c.Req)[":serviceAccountId"], 10, 64)
+	if err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Service Account ID is invalid", err)
+	}
+
+	serviceAccount, err := api.store.RetrieveServiceAccount(c.Req.Context(), c.OrgId, scopeID)
+	if err != nil {
+		switch {
+		case errors.Is(err, serviceaccounts.ErrServiceAccountNotFound):
+			return api.errorResponse(c, http.StatusNotFound, "Failed to retrieve service account", err)
+		default:
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to retrieve service account", err)
+		}
+	}
+
+	now := time.Now()
+	counts, err := api.store.CountTokensByExpiration(c.Req.Context(), c.OrgId, []int64{scopeID}, now, now.Add(sevenDaysAhead))
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to count service account tokens", err)
+	}
+
+	activity := serviceaccounts.ServiceAccountActivityDTO{
+		Id:         serviceAccount.Id,
+		IsDisabled: serviceAccount.IsDisabled,
+	}
+	if tokens, ok := counts[scopeID]; ok {
+		activity.Tokens = tokens.Tokens
+		activity.ExpiredTokens = tokens.Expired
+		activity.TokensExpiringSoon = tokens.ExpiringSoon
+	}
+	// accounts that never authenticated keep the placeholder set at creation
+	if !serviceAccount.LastSeenAt.Before(serviceAccount.Created) {
+		lastSeenAt := serviceAccount.LastSeenAt
+		activity.LastSeenAt = &lastSeenAt
+	}
+
+	return api.jsonResponse(c, http.StatusOK, activity)
 }
 
 func (api *ServiceAccountsAPI) updateServiceAccount(c *models.ReqContext) response.Response {
 	scopeID, err := strconv.ParseInt(web.Params(c.Req)[":serviceAccountId"], 10, 64)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "Service Account ID is invalid", err)
+		return api.errorResponse(c, http.StatusBadRequest, "Service Account ID is invalid", err)
 	}
 
 	cmd := &serviceaccounts.UpdateServiceAccountForm{}
-	if err := web.Bind(c.Req, &cmd); err != nil {
-		return response.Error(http.StatusBadRequest, "Bad request data", err)
+	if err := web.Bind(c.Req, cmd); err != nil {
+		return api.bindErrorResponse(c, err)
+	}
+	if cmd.Name != nil {
+		if err := serviceaccounts.CheckNameAllowed(api.cfg, *cmd.Name); err != nil {
+			return api.errorResponse(c, http.StatusBadRequest, err.Error(), nil)
+		}
 	}
 
 	if cmd.Role != nil && !cmd.Role.IsValid() {
-		return response.Error(http.StatusBadRequest, "Invalid role specified", nil)
+		return api.errorResponse(c, http.StatusBadRequest, "Invalid role specified", nil)
 	}
 
 	resp, err := api.store.UpdateServiceAccount(c.Req.Context(), c.OrgId, scopeID, cmd)
 	if err != nil {
 		switch {
 		case errors.Is(err, serviceaccounts.ErrServiceAccountNotFound):
-			return response.Error(http.StatusNotFound, "Failed to retrieve service account", err)
+			return api.errorResponse(c, http.StatusNotFound, "Failed to retrieve service account", err)
 		default:
-			return response.Error(http.StatusInternalServerError, "Failed update service account", err)
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed update service account", err)
 		}
 	}
+	api.auditLog(c, auditUpdateServiceAccount, scopeID)
 
 	saIDString := strconv.FormatInt(resp.Id, 10)
 	metadata := api.getAccessControlMetadata(c, map[string]bool{saIDString: true})
 	resp.AvatarUrl = dtos.GetGravatarUrlWithDefault("", resp.Name)
 	resp.AccessControl = metadata[saIDString]
 
-	return response.JSON(http.StatusOK, resp)
+	return api.jsonResponse(c, http.StatusOK, resp)
+}
+
+// POST /api/serviceaccounts/:serviceAccountId/disable
+func (api *ServiceAccountsAPI) DisableServiceAccount(c *models.ReqContext) response.Response {
+	return api.setServiceAccountDisabled(c, true)
+}
+
+// POST /api/serviceaccounts/:serviceAccountId/enable
+func (api *ServiceAccountsAPI) EnableServiceAccount(c *models.ReqContext) response.Response {
+	return api.setServiceAccountDisabled(c, false)
+}
+
+// setServiceAccountDisabled disables or enables a service account and returns it. The tokens
+// of a disabled account are kept but rejected when authenticating.
+func (api *ServiceAccountsAPI) setServiceAccountDisabled(c *models.ReqContext, disabled bool) response.Response {
+	scopeID, err := strconv.ParseInt(web.Params(c.Req)[":serviceAccountId"], 10, 64)
+	if err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Service Account ID is invalid", err)
+	}
+
+	if err := api.store.SetServiceAccountDisabled(c.Req.Context(), c.OrgId, scopeID, disabled); err != nil {
+		switch {
+		case errors.Is(err, serviceaccounts.ErrServiceAccountNotFound):
+			return api.errorResponse(c, http.StatusNotFound, "Failed to retrieve service account", err)
+		default:
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed update service account", err)
+		}
+	}
+
+	serviceAccount, err := api.store.RetrieveServiceAccount(c.Req.Context(), c.OrgId, scopeID)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to retrieve service account", err)
+	}
+
+	saIDString := strconv.FormatInt(serviceAccount.Id, 10)
+	metadata := api.getAccessControlMetadata(c, map[string]bool{saIDString: true})
+	serviceAccount.AvatarUrl = dtos.GetGravatarUrlWithDefault("", serviceAccount.Name)
+	serviceAccount.AccessControl = metadata[saIDString]
+
+	return api.jsonResponse(c, http.StatusOK, serviceAccount)
 }
 
 // SearchOrgServiceAccountsWithPaging is an HTTP handler to search for org users with paging.
@@ -216,26 +483,101 @@ func (api *ServiceAccountsAPI) SearchOrgServiceAccountsWithPaging(c *models.ReqC
 	if page < 1 {
 		page = 1
 	}
-	serviceAccountSearch, err := api.store.SearchOrgServiceAccounts(ctx, c.OrgId, c.Query("query"), page, perPage, c.SignedInUser)
+	filter := serviceaccounts.FilterIncludeAll
+	switch {
+	case c.QueryBool("expiredTokens") && c.QueryBool("disabled"):
+		return api.errorResponse(c, http.StatusBadRequest, "expiredTokens and disabled can't be combined", nil)
+	case c.QueryBool("expiredTokens"):
+		filter = serviceaccounts.FilterOnlyExpiredTokens
+	case c.QueryBool("disabled"):
+		filter = serviceaccounts.FilterOnlyDisabled
+	}
+	role := models.RoleType(c.Query("role"))
+	if role != "" && !role.IsValid() {
+		return api.errorResponse(c, http.StatusBadRequest, fmt.Sprintf("Invalid role %q, expected one of Viewer, Editor or Admin", role), nil)
+	}
+	sortOpts, err := parseSortOpts(c.Query("sort"), c.Query("direction"))
+	if err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	}
+	loc, err := requestLocation(c)
+	if err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Invalid time zone", err)
+	}
+	query := &serviceaccounts.SearchOrgServiceAccountsQuery{
+		OrgID:        c.OrgId,
+		Query:        c.Query("query"),
+		Filter:       filter,
+		Role:         role,
+		Page:         page,
+		Limit:        perPage,
+		SortOpts:     sortOpts,
+		SignedInUser: c.SignedInUser,
+	}
+	// countOnly skips fetching and enriching the accounts when only the total is needed
+	if c.QueryBool("countOnly") {
+		count, err := api.store.CountOrgServiceAccounts(ctx, query)
+		if err != nil {
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to count service accounts for current organization", err)
+		}
+		return api.jsonResponse(c, http.StatusOK, util.DynMap{"totalCount": count})
+	}
+	serviceAccountSearch, err := api.store.SearchOrgServiceAccounts(ctx, query)
 	if err != nil {
-		return response.Error(http.StatusInternalServerError, "Failed to get service accounts for current organization", err)
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to get service accounts for current organization", err)
+	}
+	// always serialize an empty list as [] rather than null
+	if serviceAccountSearch.ServiceAccounts == nil {
+		serviceAccountSearch.ServiceAccounts = make([]*serviceaccounts.ServiceAccountDTO, 0)
 	}
 
 	saIDs := map[string]bool{}
+	for _, sa := range serviceAccountSearch.ServiceAccounts {
+		saIDs[strconv.FormatInt(sa.Id, 10)] = true
+	}
+	// compute the metadata of the whole page at once rather than once per account
+	metadata := api.getAccessControlMetadata(c, saIDs)
+
 	for i := range serviceAccountSearch.ServiceAccounts {
 		sa := serviceAccountSearch.ServiceAccounts[i]
 		sa.AvatarUrl = dtos.GetGravatarUrlWithDefault("", sa.Name)
-
-		saIDString := strconv.FormatInt(sa.Id, 10)
-		saIDs[saIDString] = true
-		metadata := api.getAccessControlMetadata(c, map[string]bool{saIDString: true})
 		sa.AccessControl = metadata[strconv.FormatInt(sa.Id, 10)]
-		tokens, err := api.store.ListTokens(ctx, sa.OrgId, sa.Id)
-		if err != nil {
-			api.log.Warn("Failed to list tokens for service account", "serviceAccount", sa.Id)
+		if sa.ExpiresAt != nil {
+			expiresAt := sa.ExpiresAt.In(loc)
+			sa.ExpiresAt = &expiresAt
+		}
+	}
+
+	if accepts(c, ndjsonContentType) {
+		return api.ndjsonResponse(c, serviceAccountSearch.ServiceAccounts)
+	}
+
+	return api.jsonResponse(c, http.StatusOK, serviceAccountSearch)
+}
+
+// parseSortOpts reads the sort and direction query parameters of a search.
+// Without a sort field the store's default order is kept.
+func parseSortOpts(field, direction string) (serviceaccounts.SortOpts, error) {
+	sortOpts := serviceaccounts.SortOpts{}
+	if field != "" {
+		valid := make([]string, 0, len(serviceaccounts.SortFields))
+		for _, sortField := range serviceaccounts.SortFields {
+			if field == string(sortField) {
+				sortOpts.Field = sortField
+			}
+			valid = append(valid, string(sortField))
+		}
+		if sortOpts.Field == "" {
+			return sortOpts, fmt.Errorf("unknown sort field %q, valid fields are %s", field, strings.Join(valid, ", "))
 		}
-		sa.Tokens = int64(len(tokens))
 	}
 
-	return response.JSON(http.StatusOK, serviceAccountSearch)
+	switch direction {
+	case "", "asc":
+	case "desc":
+		sortOpts.Descending = true
+	default:
+		return sortOpts, fmt.Errorf("unknown sort direction %q, valid directions are asc, desc", direction)
+	}
+	return sortOpts, nil
 }
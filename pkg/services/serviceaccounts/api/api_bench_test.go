@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/models"
+	accesscontrolmock "github.com/grafana/grafana/pkg/services/accesscontrol/mock"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts/tests"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// searchResultStore returns a fixed page of service accounts from SearchOrgServiceAccounts.
+type searchResultStore struct {
+	tests.ServiceAccountsStoreMock
+	serviceAccounts int
+}
+
+func (s *searchResultStore) SearchOrgServiceAccounts(ctx context.Context,
+	query *serviceaccounts.SearchOrgServiceAccountsQuery) (*serviceaccounts.SearchServiceAccountsResult, error) {
+	result := &serviceaccounts.SearchServiceAccountsResult{Page: query.Page, PerPage: query.Limit}
+	for i := 1; i <= s.serviceAccounts; i++ {
+		result.ServiceAccounts = append(result.ServiceAccounts, &serviceaccounts.ServiceAccountDTO{
+			Id: int64(i), OrgId: query.OrgID, Name: fmt.Sprintf("sa-%d", i),
+		})
+	}
+	result.TotalCount = int64(len(result.ServiceAccounts))
+	return result, nil
+}
+
+func BenchmarkSearchOrgServiceAccountsWithPaging10(b *testing.B) {
+	benchmarkSearchOrgServiceAccountsWithPaging(b, 10)
+}
+
+func BenchmarkSearchOrgServiceAccountsWithPaging100(b *testing.B) {
+	benchmarkSearchOrgServiceAccountsWithPaging(b, 100)
+}
+
+func BenchmarkSearchOrgServiceAccountsWithPaging500(b *testing.B) {
+	benchmarkSearchOrgServiceAccountsWithPaging(b, 500)
+}
+
+func BenchmarkSearchOrgServiceAccountsWithPaging1000(b *testing.B) {
+	benchmarkSearchOrgServiceAccountsWithPaging(b, 1000)
+}
+
+func benchmarkSearchOrgServiceAccountsWithPaging(b *testing.B, serviceAccounts int) {
+	acmock := accesscontrolmock.New()
+	store := &searchResultStore{serviceAccounts: serviceAccounts}
+	saAPI := NewServiceAccountsAPI(setting.NewCfg(), &tests.ServiceAccountMock{}, acmock, routing.NewRouteRegister(), store)
+
+	user := &models.SignedInUser{
+		OrgId:   1,
+		OrgRole: models.ROLE_ADMIN,
+		Permissions: map[int64]map[string][]string{1: {
+			serviceaccounts.ActionRead:   {serviceaccounts.ScopeAll},
+			serviceaccounts.ActionWrite:  {serviceaccounts.ScopeAll},
+			serviceaccounts.ActionDelete: {"serviceaccounts:id:1"},
+		}},
+	}
+	req := httptest.NewRequest(http.MethodGet, serviceAccountPath+"search?accesscontrol=true", nil)
+	c := &models.ReqContext{Context: &web.Context{Req: req}, SignedInUser: user}
+
+	// every metadata computation checks whether access control is disabled first
+	acmock.Calls.IsDisabled = nil
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if resp := saAPI.SearchOrgServiceAccountsWithPaging(c); resp.Status() != http.StatusOK {
+			b.Fatalf("unexpected status %d", resp.Status())
+		}
+	}
+
+	b.ReportMetric(float64(len(acmock.Calls.IsDisabled))/float64(b.N), "metadata/op")
+	// token counts come with the search, listing the tokens of every account would add one query per account
+	tokenQueries := len(store.Calls.ListTokens) + len(store.Calls.ListTokensForAccounts)
+	b.ReportMetric(float64(tokenQueries)/float64(b.N), "tokenQueries/op")
+}
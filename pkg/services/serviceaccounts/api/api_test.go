@@ -3,14 +3,19 @@ package api
 import (
 	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/components/apikeygen"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
@@ -70,7 +75,7 @@ func TestServiceAccountsAPI_CreateServiceAccount(t *testing.T) {
 		{
 			desc:      "not ok - duplicate name",
 			body:      map[string]interface{}{"name": "New SA"},
-			wantError: "service account name already in use",
+			wantError: "a service account with that name already exists in this organization",
 			acmock: tests.SetupMockAccesscontrol(
 				t,
 				func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
@@ -78,12 +83,12 @@ func TestServiceAccountsAPI_CreateServiceAccount(t *testing.T) {
 				},
 				false,
 			),
-			expectedCode: http.StatusBadRequest,
+			expectedCode: http.StatusConflict,
 		},
 		{
 			desc:      "not ok - missing name",
 			body:      map[string]interface{}{},
-			wantError: "required value Name must not be empty",
+			wantError: "invalid name: must not be empty",
 			acmock: tests.SetupMockAccesscontrol(
 				t,
 				func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
@@ -139,14 +144,136 @@ func TestServiceAccountsAPI_CreateServiceAccount(t *testing.T) {
 					assert.NotEmpty(t, actualBody["id"])
 					assert.Equal(t, tc.body["name"], actualBody["name"].(string))
 					assert.Equal(t, tc.wantID, actualBody["login"].(string))
+					assert.Equal(t, fmt.Sprintf(serviceAccountIDPath, actualBody["id"]), actual.Header().Get("Location"))
 				} else if actualCode == http.StatusBadRequest {
-					assert.Contains(t, tc.wantError, actualBody["error"].(string))
+					assert.Equal(t, tc.wantError, actualBody["message"])
+				} else if actualCode == http.StatusConflict {
+					assert.Equal(t, tc.wantError, actualBody["message"])
+					assert.Equal(t, "serviceaccounts.conflict", actualBody["messageId"])
 				}
 			})
 		})
 	}
 }
 
+func TestServiceAccountsAPI_CreateServiceAccountCasing(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	saStore := database.NewServiceAccountsStore(store)
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionCreate}}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &tests.ServiceAccountMock{}, routing.NewRouteRegister(), acmock, store, saStore)
+
+	create := func(t *testing.T, name, query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, serviceAccountPath+query, strings.NewReader(fmt.Sprintf(`{"name": %q}`, name)))
+		require.NoError(t, err)
+		req.Header.Add("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should use snake_case when asked", func(t *testing.T) {
+		actual := create(t, "sa-snake", "?casing=snake_case")
+		require.Equal(t, http.StatusCreated, actual.Code, actual.Body.String())
+
+		actualBody := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &actualBody))
+		assert.Contains(t, actualBody, "org_id")
+		assert.Contains(t, actualBody, "is_disabled")
+		assert.NotContains(t, actualBody, "orgId")
+		assert.NotContains(t, actualBody, "isDisabled")
+		assert.NotEmpty(t, actual.Header().Get("Location"))
+	})
+
+	t.Run("should reject an unknown casing without creating the service account", func(t *testing.T) {
+		actual := create(t, "sa-kebab", "?casing=kebab-case")
+		require.Equal(t, http.StatusBadRequest, actual.Code)
+
+		available, err := saStore.IsServiceAccountNameAvailable(context.Background(), "sa-kebab")
+		require.NoError(t, err)
+		assert.True(t, available)
+	})
+}
+
+func TestServiceAccountsAPI_CreateServiceAccountDefaultRole(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	saStore := database.NewServiceAccountsStore(store)
+	svcmock := tests.ServiceAccountMock{}
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionCreate}}, nil
+		},
+		false,
+	)
+
+	testCases := []struct {
+		desc         string
+		defaultRole  string
+		body         map[string]interface{}
+		expectedCode int
+		expectedRole models.RoleType
+	}{
+		{
+			desc:         "should apply the configured default role when role is omitted",
+			defaultRole:  "Editor",
+			body:         map[string]interface{}{"name": "Default Role SA"},
+			expectedCode: http.StatusCreated,
+			expectedRole: models.ROLE_EDITOR,
+		},
+		{
+			desc:         "should fall back to Viewer when no default role is configured",
+			body:         map[string]interface{}{"name": "Unconfigured Role SA"},
+			expectedCode: http.StatusCreated,
+			expectedRole: models.ROLE_VIEWER,
+		},
+		{
+			desc:         "should keep an explicitly requested role",
+			defaultRole:  "Viewer",
+			body:         map[string]interface{}{"name": "Admin SA", "role": "Admin"},
+			expectedCode: http.StatusCreated,
+			expectedRole: models.ROLE_ADMIN,
+		},
+		{
+			desc:         "should reject an invalid role",
+			defaultRole:  "Viewer",
+			body:         map[string]interface{}{"name": "Invalid Role SA", "role": "Owner"},
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server, saAPI := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+			saAPI.cfg.ServiceAccountDefaultRole = tc.defaultRole
+
+			marshalled, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, serviceAccountPath, bytes.NewReader(marshalled))
+			require.NoError(t, err)
+			req.Header.Add("Content-Type", "application/json")
+			actual := httptest.NewRecorder()
+			server.ServeHTTP(actual, req)
+			require.Equal(t, tc.expectedCode, actual.Code, actual.Body.String())
+
+			if tc.expectedCode == http.StatusCreated {
+				created := serviceaccounts.ServiceAccountDTO{}
+				require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &created))
+				assert.Equal(t, string(tc.expectedRole), created.Role)
+
+				profile, err := saStore.RetrieveServiceAccount(context.Background(), 1, created.Id)
+				require.NoError(t, err)
+				assert.Equal(t, string(tc.expectedRole), profile.Role)
+			}
+		})
+	}
+}
+
 // test the accesscontrol endpoints
 // with permissions and without permissions
 func TestServiceAccountsAPI_DeleteServiceAccount(t *testing.T) {
@@ -210,6 +337,79 @@ func TestServiceAccountsAPI_DeleteServiceAccount(t *testing.T) {
 	})
 }
 
+func TestServiceAccountsAPI_DeleteServiceAccountByName(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-unique", Name: "Unique", IsServiceAccount: true})
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-twin-1", Name: "Twin", IsServiceAccount: true})
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-twin-2", Name: "Twin", IsServiceAccount: true})
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "not-a-sa", Name: "Regular", IsServiceAccount: false})
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionDelete, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+
+	testCases := []struct {
+		desc         string
+		name         string
+		expectedCode int
+	}{
+		{desc: "should delete a service account found by name", name: "Unique", expectedCode: http.StatusOK},
+		{desc: "should return not found for an unknown name", name: "Missing", expectedCode: http.StatusNotFound},
+		{desc: "should not match users that aren't service accounts", name: "Regular", expectedCode: http.StatusNotFound},
+		{desc: "should return a conflict for an ambiguous name", name: "Twin", expectedCode: http.StatusConflict},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+			req, err := http.NewRequest(http.MethodDelete, serviceAccountPath+"byName/"+tc.name, nil)
+			require.NoError(t, err)
+			actual := httptest.NewRecorder()
+			server.ServeHTTP(actual, req)
+			require.Equal(t, tc.expectedCode, actual.Code, actual.Body.String())
+		})
+	}
+}
+
+func TestServiceAccountsAPI_ConvertToServiceAccount(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionCreate, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+
+	keyCmd := &models.AddApiKeyCommand{Name: "legacy-key", Role: models.ROLE_EDITOR, OrgId: 1, Key: "legacy-key-hash"}
+	require.NoError(t, store.AddAPIKey(context.Background(), keyCmd))
+
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%sconvert/%d", serviceAccountPath, keyCmd.Result.Id), nil)
+	require.NoError(t, err)
+	actual := httptest.NewRecorder()
+	server.ServeHTTP(actual, req)
+	require.Equal(t, http.StatusOK, actual.Code, actual.Body.String())
+
+	body := struct {
+		Converted []serviceaccounts.ConvertedApiKeyDTO `json:"converted"`
+	}{}
+	require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &body))
+	require.Len(t, body.Converted, 1)
+	assert.Equal(t, keyCmd.Result.Id, body.Converted[0].KeyId)
+
+	keyQuery := &models.GetApiKeyByIdQuery{ApiKeyId: keyCmd.Result.Id}
+	require.NoError(t, store.GetApiKeyById(context.Background(), keyQuery))
+	require.NotNil(t, keyQuery.Result.ServiceAccountId)
+	assert.Equal(t, *keyQuery.Result.ServiceAccountId, body.Converted[0].ServiceAccountId)
+}
+
 func serviceAccountRequestScenario(t *testing.T, httpMethod string, endpoint string, user *tests.TestUser, fn func(httpmethod string, endpoint string, user *tests.TestUser)) {
 	t.Helper()
 	fn(httpMethod, endpoint, user)
@@ -226,6 +426,7 @@ func setupTestServer(t *testing.T, svc *tests.ServiceAccountMock,
 
 	m := web.New()
 	signedUser := &models.SignedInUser{
+		UserId:  1,
 		OrgId:   1,
 		OrgRole: models.ROLE_ADMIN,
 	}
@@ -328,10 +529,191 @@ func TestServiceAccountsAPI_RetrieveServiceAccount(t *testing.T) {
 	}
 }
 
+func TestServiceAccountsAPI_RetrieveServiceAccountTimeZone(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-tz", IsServiceAccount: true})
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+
+	var retrieve = func(t *testing.T, query string, header string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(serviceAccountIDPath, sa.Id)+query, nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set("Accept-Timezone", header)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	var createdAt = func(t *testing.T, actual *httptest.ResponseRecorder) time.Time {
+		require.Equal(t, http.StatusOK, actual.Code)
+		profile := struct {
+			Created string `json:"createdAt"`
+		}{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &profile))
+		created, err := time.Parse(time.RFC3339, profile.Created)
+		require.NoError(t, err)
+		return created
+	}
+
+	// Asia/Tokyo doesn't observe daylight saving time
+	tokyoOffset := 9 * 60 * 60
+
+	t.Run("should default to UTC", func(t *testing.T) {
+		created := createdAt(t, retrieve(t, "", ""))
+		_, offset := created.Zone()
+		assert.Equal(t, 0, offset)
+	})
+
+	t.Run("should convert timestamps to the zone in the tz parameter", func(t *testing.T) {
+		utc := createdAt(t, retrieve(t, "", ""))
+		created := createdAt(t, retrieve(t, "?tz=Asia/Tokyo", ""))
+		_, offset := created.Zone()
+		assert.Equal(t, tokyoOffset, offset)
+		assert.True(t, created.Equal(utc))
+	})
+
+	t.Run("should convert timestamps to the zone in the Accept-Timezone header", func(t *testing.T) {
+		created := createdAt(t, retrieve(t, "", "Asia/Tokyo"))
+		_, offset := created.Zone()
+		assert.Equal(t, tokyoOffset, offset)
+	})
+
+	t.Run("should be bad request for an invalid zone", func(t *testing.T) {
+		actual := retrieve(t, "?tz=Not/AZone", "")
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+	})
+}
+
+func TestServiceAccountsAPI_SearchOrgServiceAccountsTimeZone(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	expiresAt := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	_, err := saStore.CreateServiceAccount(context.Background(), 1,
+		&serviceaccounts.CreateServiceAccountForm{Name: "expiring", ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	var search = func(t *testing.T, query string, header string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, serviceAccountPath+"search"+query, nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set("Accept-Timezone", header)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	var searchExpiresAt = func(t *testing.T, actual *httptest.ResponseRecorder) time.Time {
+		require.Equal(t, http.StatusOK, actual.Code)
+		result := struct {
+			ServiceAccounts []struct {
+				ExpiresAt string `json:"expiresAt"`
+			} `json:"serviceAccounts"`
+		}{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &result))
+		require.Len(t, result.ServiceAccounts, 1)
+		parsed, err := time.Parse(time.RFC3339, result.ServiceAccounts[0].ExpiresAt)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	// Asia/Tokyo doesn't observe daylight saving time
+	tokyoOffset := 9 * 60 * 60
+
+	t.Run("should default to UTC", func(t *testing.T) {
+		_, offset := searchExpiresAt(t, search(t, "", "")).Zone()
+		assert.Equal(t, 0, offset)
+	})
+
+	t.Run("should convert timestamps to the zone in the tz parameter", func(t *testing.T) {
+		converted := searchExpiresAt(t, search(t, "?tz=Asia/Tokyo", ""))
+		_, offset := converted.Zone()
+		assert.Equal(t, tokyoOffset, offset)
+		assert.True(t, converted.Equal(expiresAt))
+	})
+
+	t.Run("should convert timestamps to the zone in the Accept-Timezone header", func(t *testing.T) {
+		_, offset := searchExpiresAt(t, search(t, "", "Asia/Tokyo")).Zone()
+		assert.Equal(t, tokyoOffset, offset)
+	})
+
+	t.Run("should be bad request for an invalid zone", func(t *testing.T) {
+		actual := search(t, "?tz=Not/AZone", "")
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+	})
+}
+
 func newString(s string) *string {
 	return &s
 }
 
+func TestServiceAccountsAPI_RetrieveServiceAccountHAL(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-hal", IsServiceAccount: true})
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+
+	var retrieve = func(t *testing.T, accept string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(serviceAccountIDPath, sa.Id), nil)
+		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		body := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		return recorder, body
+	}
+
+	t.Run("should add links when HAL is accepted", func(t *testing.T) {
+		actual, body := retrieve(t, "application/hal+json, application/json;q=0.9")
+		assert.Equal(t, "application/hal+json", actual.Header().Get("Content-Type"))
+		assert.Equal(t, "sa-hal", body["login"])
+		assert.Equal(t, map[string]interface{}{
+			"self":   map[string]interface{}{"href": fmt.Sprintf("/api/serviceaccounts/%d", sa.Id)},
+			"tokens": map[string]interface{}{"href": fmt.Sprintf("/api/serviceaccounts/%d/tokens", sa.Id)},
+			"org":    map[string]interface{}{"href": fmt.Sprintf("/api/orgs/%d", sa.OrgId)},
+		}, body["_links"])
+	})
+
+	t.Run("should not add links to plain JSON", func(t *testing.T) {
+		_, body := retrieve(t, "application/json")
+		assert.Equal(t, "sa-hal", body["login"])
+		assert.NotContains(t, body, "_links")
+
+		_, body = retrieve(t, "")
+		assert.NotContains(t, body, "_links")
+	})
+}
+
 func TestServiceAccountsAPI_UpdateServiceAccount(t *testing.T) {
 	store := sqlstore.InitTestDB(t)
 	svcmock := tests.ServiceAccountMock{}
@@ -450,3 +832,1327 @@ func TestServiceAccountsAPI_UpdateServiceAccount(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceAccountsAPI_DisableServiceAccount(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-suspended", IsServiceAccount: true})
+	createTokenforSA(t, saStore, "suspended-token", sa.OrgId, sa.Id, 0)
+
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	var post = func(path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req, err := http.NewRequest(http.MethodPost, path, nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		body := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		return recorder, body
+	}
+
+	// the context handler rejects the tokens of accounts whose signed in user is disabled
+	var isDisabled = func() bool {
+		query := &models.GetSignedInUserQuery{UserId: sa.Id, OrgId: sa.OrgId}
+		require.NoError(t, store.GetSignedInUser(context.Background(), query))
+		return query.Result.IsDisabled
+	}
+
+	t.Run("should disable the service account and keep its tokens", func(t *testing.T) {
+		actual, body := post(fmt.Sprintf(serviceAccountIDPath+"/disable", sa.Id))
+		require.Equal(t, http.StatusOK, actual.Code, body)
+		assert.Equal(t, true, body["isDisabled"])
+		assert.Equal(t, "sa-suspended", body["login"])
+		assert.True(t, isDisabled())
+
+		tokens, err := saStore.ListTokens(context.Background(), sa.OrgId, sa.Id)
+		require.NoError(t, err)
+		assert.Len(t, tokens, 1)
+	})
+
+	t.Run("should enable the service account again", func(t *testing.T) {
+		actual, body := post(fmt.Sprintf(serviceAccountIDPath+"/enable", sa.Id))
+		require.Equal(t, http.StatusOK, actual.Code, body)
+		assert.Equal(t, false, body["isDisabled"])
+		assert.False(t, isDisabled())
+	})
+
+	t.Run("should be not found for an unknown service account", func(t *testing.T) {
+		actual, _ := post(fmt.Sprintf(serviceAccountIDPath+"/disable", sa.Id+100))
+		assert.Equal(t, http.StatusNotFound, actual.Code)
+	})
+}
+
+func TestServiceAccountsAPI_SearchOrgServiceAccountsWithPaging(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+
+	var requestResponse = func(server *web.Mux, httpMethod, requestpath string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(httpMethod, requestpath, nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should return an empty list for an org without service accounts", func(t *testing.T) {
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+		actual := requestResponse(server, http.MethodGet, serviceAccountPath+"search")
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		actualBody := map[string]json.RawMessage{}
+		err := json.Unmarshal(actual.Body.Bytes(), &actualBody)
+		require.NoError(t, err)
+		assert.JSONEq(t, "[]", string(actualBody["serviceAccounts"]))
+		assert.JSONEq(t, "0", string(actualBody["totalCount"]))
+	})
+
+	t.Run("should return one service account per line with an ndjson accept header", func(t *testing.T) {
+		tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-ndjson-1", Name: "ndjson 1", IsServiceAccount: true})
+		tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-ndjson-2", Name: "ndjson 2", IsServiceAccount: true})
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+
+		req, err := http.NewRequest(http.MethodGet, serviceAccountPath+"search", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "application/x-ndjson")
+		actual := httptest.NewRecorder()
+		server.ServeHTTP(actual, req)
+		require.Equal(t, http.StatusOK, actual.Code)
+		assert.Equal(t, "application/x-ndjson", actual.Header().Get("Content-Type"))
+
+		lines := strings.Split(strings.TrimSpace(actual.Body.String()), "\n")
+		require.Len(t, lines, 2)
+		for _, line := range lines {
+			sa := serviceaccounts.ServiceAccountDTO{}
+			require.NoError(t, json.Unmarshal([]byte(line), &sa))
+			assert.Contains(t, sa.Login, "sa-ndjson-")
+		}
+	})
+
+	t.Run("should return only the total count with countOnly", func(t *testing.T) {
+		tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-count-1", Name: "count 1", IsServiceAccount: true})
+		tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-count-2", Name: "count 2", IsServiceAccount: true})
+		tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-other", Name: "other", IsServiceAccount: true})
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+		actual := requestResponse(server, http.MethodGet, serviceAccountPath+"search?countOnly=true&query=sa-count")
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		actualBody := map[string]json.RawMessage{}
+		err := json.Unmarshal(actual.Body.Bytes(), &actualBody)
+		require.NoError(t, err)
+		require.Len(t, actualBody, 1)
+		assert.NotContains(t, actualBody, "serviceAccounts")
+		assert.JSONEq(t, "2", string(actualBody["totalCount"]))
+	})
+}
+
+func TestServiceAccountsAPI_IsServiceAccountNameAvailable(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-taken-name", Name: "Taken Name", IsServiceAccount: true})
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionCreate}}, nil
+		},
+		false,
+	)
+
+	testCases := []struct {
+		desc         string
+		query        string
+		expectedCode int
+		available    bool
+	}{
+		{desc: "should be available for a free name", query: "?name=Free%20Name", expectedCode: http.StatusOK, available: true},
+		{desc: "should not be available for a taken name", query: "?name=Taken%20Name", expectedCode: http.StatusOK, available: false},
+		{desc: "should require a name", query: "", expectedCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+			req, err := http.NewRequest(http.MethodGet, serviceAccountPath+"available"+tc.query, nil)
+			require.NoError(t, err)
+			actual := httptest.NewRecorder()
+			server.ServeHTTP(actual, req)
+			require.Equal(t, tc.expectedCode, actual.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				body := map[string]interface{}{}
+				require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &body))
+				assert.Equal(t, tc.available, body["available"])
+			}
+		})
+	}
+}
+
+func TestServiceAccountsAPI_GetServiceAccountsQuota(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-quota-1", IsServiceAccount: true})
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-quota-2", IsServiceAccount: true})
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "user-quota", IsServiceAccount: false})
+
+	testCases := []struct {
+		desc         string
+		quota        setting.QuotaSettings
+		acmock       *accesscontrolmock.Mock
+		expectedCode int
+		expected     serviceaccounts.ServiceAccountsQuotaDTO
+	}{
+		{
+			desc:  "should report usage against the configured org quota",
+			quota: setting.QuotaSettings{Enabled: true, Org: &setting.OrgQuota{ServiceAccount: 5}},
+			acmock: tests.SetupMockAccesscontrol(
+				t,
+				func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+					return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+				},
+				false,
+			),
+			expectedCode: http.StatusOK,
+			expected:     serviceaccounts.ServiceAccountsQuotaDTO{Used: 2, Limit: 5, Remaining: 3},
+		},
+		{
+			desc:  "should report no limit when quotas are disabled",
+			quota: setting.QuotaSettings{Enabled: false, Org: &setting.OrgQuota{ServiceAccount: 5}},
+			acmock: tests.SetupMockAccesscontrol(
+				t,
+				func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+					return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+				},
+				false,
+			),
+			expectedCode: http.StatusOK,
+			expected:     serviceaccounts.ServiceAccountsQuotaDTO{Used: 2, Limit: -1, Remaining: -1},
+		},
+		{
+			desc:  "should be forbidden without read permission on all service accounts",
+			quota: setting.QuotaSettings{Enabled: true, Org: &setting.OrgQuota{ServiceAccount: 5}},
+			acmock: tests.SetupMockAccesscontrol(
+				t,
+				func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+					return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: "serviceaccounts:id:1"}}, nil
+				},
+				false,
+			),
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server, saAPI := setupTestServer(t, &svcmock, routing.NewRouteRegister(), tc.acmock, store, database.NewServiceAccountsStore(store))
+			saAPI.cfg.Quota = tc.quota
+
+			req, err := http.NewRequest(http.MethodGet, serviceAccountPath+"quota", nil)
+			require.NoError(t, err)
+			actual := httptest.NewRecorder()
+			server.ServeHTTP(actual, req)
+			require.Equal(t, tc.expectedCode, actual.Code)
+
+			if tc.expectedCode == http.StatusOK {
+				quota := serviceaccounts.ServiceAccountsQuotaDTO{}
+				require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &quota))
+				assert.Equal(t, tc.expected, quota)
+			}
+		})
+	}
+}
+
+func TestServiceAccountsAPI_CreateServiceAccountLimit(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-limit-1", IsServiceAccount: true})
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-limit-2", IsServiceAccount: true})
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{
+				{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll},
+				{Action: serviceaccounts.ActionCreate},
+			}, nil
+		},
+		false,
+	)
+	server, saAPI := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+	saAPI.cfg.ServiceAccountsLimit = 2
+
+	req, err := http.NewRequest(http.MethodPost, serviceAccountPath, strings.NewReader(`{"name": "third"}`))
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/json")
+	actual := httptest.NewRecorder()
+	server.ServeHTTP(actual, req)
+	require.Equal(t, http.StatusForbidden, actual.Code, actual.Body.String())
+
+	body := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &body))
+	assert.Contains(t, body["message"], "at most 2 service accounts")
+
+	req, err = http.NewRequest(http.MethodGet, serviceAccountPath+"quota", nil)
+	require.NoError(t, err)
+	actual = httptest.NewRecorder()
+	server.ServeHTTP(actual, req)
+	require.Equal(t, http.StatusOK, actual.Code)
+	quota := serviceaccounts.ServiceAccountsQuotaDTO{}
+	require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &quota))
+	assert.Equal(t, serviceaccounts.ServiceAccountsQuotaDTO{Used: 2, Limit: 2, Remaining: 0}, quota)
+}
+
+func TestServiceAccountsAPI_GetServiceAccountsStats(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-stats-viewer", Role: "Viewer", IsServiceAccount: true})
+	editor := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-stats-editor", Role: "Editor", IsServiceAccount: true})
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-stats-admin", Role: "Admin", IsServiceAccount: true})
+	require.NoError(t, saStore.SetServiceAccountDisabled(context.Background(), editor.OrgId, editor.Id, true))
+
+	testCases := []struct {
+		desc         string
+		permissions  []*accesscontrol.Permission
+		expectedCode int
+	}{
+		{
+			desc:         "should return the counts by role",
+			permissions:  []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "should be forbidden without read permission on all service accounts",
+			permissions:  []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: "serviceaccounts:id:1"}},
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			acmock := tests.SetupMockAccesscontrol(
+				t,
+				func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+					return tc.permissions, nil
+				},
+				false,
+			)
+			server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+			req, err := http.NewRequest(http.MethodGet, serviceAccountPath+"stats", nil)
+			require.NoError(t, err)
+			actual := httptest.NewRecorder()
+			server.ServeHTTP(actual, req)
+			require.Equal(t, tc.expectedCode, actual.Code)
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			stats := serviceaccounts.ServiceAccountsStatsDTO{}
+			require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &stats))
+			assert.Equal(t, serviceaccounts.ServiceAccountsStatsDTO{
+				Total:    3,
+				Disabled: 1,
+				Roles:    map[models.RoleType]int64{models.ROLE_VIEWER: 1, models.ROLE_EDITOR: 1, models.ROLE_ADMIN: 1},
+			}, stats)
+		})
+	}
+}
+
+func TestServiceAccountsAPI_GetServiceAccountActivity(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	var getActivity = func(t *testing.T, saID int64) serviceaccounts.ServiceAccountActivityDTO {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(serviceAccountIDPath+"/activity", saID), nil)
+		require.NoError(t, err)
+		actual := httptest.NewRecorder()
+		server.ServeHTTP(actual, req)
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		activity := serviceaccounts.ServiceAccountActivityDTO{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &activity))
+		return activity
+	}
+
+	t.Run("should summarize tokens of a service account that never authenticated", func(t *testing.T) {
+		sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-activity-1", IsServiceAccount: true})
+		createTokenforSA(t, saStore, "activity-no-expiry", sa.OrgId, sa.Id, 0)
+		createTokenforSA(t, saStore, "activity-expiring", sa.OrgId, sa.Id, 3600)
+		expired := createTokenforSA(t, saStore, "activity-expired", sa.OrgId, sa.Id, 3600)
+		err := store.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Exec("UPDATE api_key SET expires = ? WHERE id = ?", time.Now().Add(-time.Hour).Unix(), expired.Id)
+			return err
+		})
+		require.NoError(t, err)
+
+		activity := getActivity(t, sa.Id)
+		assert.Equal(t, sa.Id, activity.Id)
+		assert.False(t, activity.IsDisabled)
+		assert.Equal(t, int64(3), activity.Tokens)
+		assert.Equal(t, int64(1), activity.ExpiredTokens)
+		assert.Equal(t, int64(1), activity.TokensExpiringSoon)
+		assert.Nil(t, activity.LastSeenAt)
+	})
+
+	t.Run("should report the last authentication", func(t *testing.T) {
+		sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-activity-2", IsServiceAccount: true})
+		err := store.UpdateUserLastSeenAt(context.Background(), &models.UpdateUserLastSeenAtCommand{UserId: sa.Id})
+		require.NoError(t, err)
+
+		activity := getActivity(t, sa.Id)
+		assert.Equal(t, int64(0), activity.Tokens)
+		require.NotNil(t, activity.LastSeenAt)
+		assert.WithinDuration(t, time.Now(), *activity.LastSeenAt, time.Minute)
+	})
+}
+
+func TestServiceAccountsAPI_RetrieveServiceAccountCasing(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-casing", IsServiceAccount: true})
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+
+	var retrieve = func(t *testing.T, query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(serviceAccountIDPath, sa.Id)+query, nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	testCases := []struct {
+		desc        string
+		query       string
+		presentKeys []string
+		absentKeys  []string
+	}{
+		{
+			desc:        "should use camelCase by default",
+			query:       "",
+			presentKeys: []string{"orgId", "isDisabled", "avatarUrl", "createdAt"},
+			absentKeys:  []string{"org_id", "is_disabled", "avatar_url", "created_at"},
+		},
+		{
+			desc:        "should use camelCase when asked",
+			query:       "?casing=camelCase",
+			presentKeys: []string{"orgId", "isDisabled", "avatarUrl", "createdAt"},
+			absentKeys:  []string{"org_id", "is_disabled", "avatar_url", "created_at"},
+		},
+		{
+			desc:        "should use snake_case when asked",
+			query:       "?casing=snake_case",
+			presentKeys: []string{"org_id", "is_disabled", "avatar_url", "created_at"},
+			absentKeys:  []string{"orgId", "isDisabled", "avatarUrl", "createdAt"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			actual := retrieve(t, tc.query)
+			require.Equal(t, http.StatusOK, actual.Code)
+
+			actualBody := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &actualBody))
+			for _, key := range tc.presentKeys {
+				assert.Contains(t, actualBody, key)
+			}
+			for _, key := range tc.absentKeys {
+				assert.NotContains(t, actualBody, key)
+			}
+			assert.Equal(t, "sa-casing", actualBody["login"])
+		})
+	}
+
+	t.Run("should be bad request for an unknown casing", func(t *testing.T) {
+		actual := retrieve(t, "?casing=kebab-case")
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+	})
+}
+
+func TestToSnakeCase(t *testing.T) {
+	for input, expected := range map[string]string{
+		"id":         "id",
+		"orgId":      "org_id",
+		"avatarUrl":  "avatar_url",
+		"totalCount": "total_count",
+		"userID":     "user_id",
+		"HTTPServer": "http_server",
+	} {
+		assert.Equal(t, expected, toSnakeCase(input), input)
+	}
+}
+
+type failingCountStore struct {
+	tests.ServiceAccountsStoreMock
+}
+
+func (s *failingCountStore) CountServiceAccounts(ctx context.Context, orgID int64) (int64, error) {
+	return 0, errors.New("database is locked")
+}
+
+func TestServiceAccountsAPI_DebugErrors(t *testing.T) {
+	env := setting.Env
+	setting.Env = setting.Prod
+	t.Cleanup(func() { setting.Env = env })
+
+	testCases := []struct {
+		desc          string
+		env           string
+		debugHeader   bool
+		grafanaAdmin  bool
+		expectedCause bool
+	}{
+		{desc: "should hide the cause in production", env: setting.Prod},
+		{desc: "should return the cause in development mode", env: setting.Dev, expectedCause: true},
+		{desc: "should return the cause to a Grafana admin sending the debug header", env: setting.Prod, debugHeader: true, grafanaAdmin: true, expectedCause: true},
+		{desc: "should ignore the debug header from other users", env: setting.Prod, debugHeader: true},
+		{desc: "should hide the cause from a Grafana admin without the debug header", env: setting.Prod, grafanaAdmin: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := setting.NewCfg()
+			cfg.Env = tc.env
+			saAPI := NewServiceAccountsAPI(cfg, &tests.ServiceAccountMock{}, nil, routing.NewRouteRegister(), &failingCountStore{})
+
+			req := httptest.NewRequest(http.MethodGet, serviceAccountPath+"quota", nil)
+			if tc.debugHeader {
+				req.Header.Set("X-Grafana-Debug", "true")
+			}
+			c := &models.ReqContext{
+				Context:      &web.Context{Req: req},
+				SignedInUser: &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_ADMIN, IsGrafanaAdmin: tc.grafanaAdmin},
+			}
+
+			resp := saAPI.GetServiceAccountsQuota(c)
+			require.Equal(t, http.StatusInternalServerError, resp.Status())
+
+			body := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(resp.Body(), &body))
+			assert.Equal(t, "Failed to count service accounts", body["message"])
+			if tc.expectedCause {
+				assert.Equal(t, "database is locked", body["error"])
+			} else {
+				assert.NotContains(t, body, "error")
+			}
+		})
+	}
+}
+
+func TestServiceAccountsAPI_ErrorEnvelope(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{}, nil
+		},
+		true,
+	)
+
+	testCases := []struct {
+		desc              string
+		saStore           serviceaccounts.Store
+		path              string
+		expectedCode      int
+		expectedMessage   string
+		expectedMessageID string
+	}{
+		{
+			desc:              "should return a bad request envelope for an invalid ID",
+			saStore:           database.NewServiceAccountsStore(store),
+			path:              serviceAccountPath + "abc",
+			expectedCode:      http.StatusBadRequest,
+			expectedMessage:   "Service Account ID is invalid",
+			expectedMessageID: "serviceaccounts.badRequest",
+		},
+		{
+			desc:              "should return a bad request envelope for an unsupported casing",
+			saStore:           database.NewServiceAccountsStore(store),
+			path:              serviceAccountPath + "search?casing=kebab",
+			expectedCode:      http.StatusBadRequest,
+			expectedMessage:   `Unsupported casing "kebab"`,
+			expectedMessageID: "serviceaccounts.badRequest",
+		},
+		{
+			desc:              "should return a not found envelope for an unknown service account",
+			saStore:           database.NewServiceAccountsStore(store),
+			path:              serviceAccountPath + "999",
+			expectedCode:      http.StatusNotFound,
+			expectedMessage:   "Failed to retrieve service account",
+			expectedMessageID: "serviceaccounts.notFound",
+		},
+		{
+			desc:              "should return an internal error envelope when the store fails",
+			saStore:           &failingCountStore{},
+			path:              serviceAccountPath + "quota",
+			expectedCode:      http.StatusInternalServerError,
+			expectedMessage:   "Failed to count service accounts",
+			expectedMessageID: "serviceaccounts.internalError",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server, _ := setupTestServer(t, &tests.ServiceAccountMock{}, routing.NewRouteRegister(), acmock, store, tc.saStore)
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+			actual := httptest.NewRecorder()
+			server.ServeHTTP(actual, req)
+			require.Equal(t, tc.expectedCode, actual.Code, actual.Body.String())
+
+			body := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &body))
+			assert.Equal(t, float64(tc.expectedCode), body["statusCode"])
+			assert.Equal(t, tc.expectedMessage, body["message"])
+			assert.Equal(t, tc.expectedMessageID, body["messageId"])
+			assert.Contains(t, body, "traceId")
+		})
+	}
+}
+
+func TestServiceAccountsAPI_SearchTokenCounts(t *testing.T) {
+	store := &searchResultStore{serviceAccounts: 3}
+	saAPI := NewServiceAccountsAPI(setting.NewCfg(), &tests.ServiceAccountMock{}, accesscontrolmock.New().WithDisabled(), routing.NewRouteRegister(), store)
+
+	req := httptest.NewRequest(http.MethodGet, serviceAccountPath+"search", nil)
+	c := &models.ReqContext{
+		Context:      &web.Context{Req: req},
+		SignedInUser: &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_ADMIN},
+	}
+
+	resp := saAPI.SearchOrgServiceAccountsWithPaging(c)
+	require.Equal(t, http.StatusOK, resp.Status())
+	// the token counts come with the search, no query is issued per service account
+	assert.Empty(t, store.Calls.ListTokens)
+	assert.Empty(t, store.Calls.ListTokensForAccounts)
+}
+
+func TestServiceAccountsAPI_SearchSort(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		query            string
+		expectedCode     int
+		expectedSortOpts serviceaccounts.SortOpts
+		expectedMessage  string
+	}{
+		{
+			desc:         "should keep the default order without a sort field",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:             "should sort by the given field",
+			query:            "&sort=tokens&direction=desc",
+			expectedCode:     http.StatusOK,
+			expectedSortOpts: serviceaccounts.SortOpts{Field: serviceaccounts.SortByTokens, Descending: true},
+		},
+		{
+			desc:            "should reject an unknown sort field",
+			query:           "&sort=email",
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: `unknown sort field "email", valid fields are name, tokens, lastUsed`,
+		},
+		{
+			desc:            "should reject an unknown direction",
+			query:           "&sort=name&direction=up",
+			expectedCode:    http.StatusBadRequest,
+			expectedMessage: `unknown sort direction "up", valid directions are asc, desc`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			store := &tests.ServiceAccountsStoreMock{}
+			saAPI := NewServiceAccountsAPI(setting.NewCfg(), &tests.ServiceAccountMock{}, accesscontrolmock.New().WithDisabled(), routing.NewRouteRegister(), store)
+
+			// countOnly is enough to see the query passed to the store
+			req := httptest.NewRequest(http.MethodGet, serviceAccountPath+"search?countOnly=true"+tc.query, nil)
+			c := &models.ReqContext{
+				Context:      &web.Context{Req: req},
+				SignedInUser: &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_ADMIN},
+			}
+
+			resp := saAPI.SearchOrgServiceAccountsWithPaging(c)
+			require.Equal(t, tc.expectedCode, resp.Status())
+			if tc.expectedCode != http.StatusOK {
+				body := map[string]interface{}{}
+				require.NoError(t, json.Unmarshal(resp.Body(), &body))
+				assert.Equal(t, tc.expectedMessage, body["message"])
+				assert.Empty(t, store.Calls.CountOrgServiceAccounts)
+				return
+			}
+
+			require.Len(t, store.Calls.CountOrgServiceAccounts, 1)
+			query := store.Calls.CountOrgServiceAccounts[0].([]interface{})[1].(*serviceaccounts.SearchOrgServiceAccountsQuery)
+			assert.Equal(t, tc.expectedSortOpts, query.SortOpts)
+		})
+	}
+}
+
+func TestServiceAccountsAPI_SearchFilter(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		query          string
+		expectedCode   int
+		expectedFilter serviceaccounts.ServiceAccountFilter
+		expectedRole   models.RoleType
+	}{
+		{
+			desc:           "should include all accounts by default",
+			expectedCode:   http.StatusOK,
+			expectedFilter: serviceaccounts.FilterIncludeAll,
+		},
+		{
+			desc:           "should keep the accounts with expired tokens",
+			query:          "&expiredTokens=true",
+			expectedCode:   http.StatusOK,
+			expectedFilter: serviceaccounts.FilterOnlyExpiredTokens,
+		},
+		{
+			desc:           "should keep the disabled accounts matching the query",
+			query:          "&disabled=true&query=build",
+			expectedCode:   http.StatusOK,
+			expectedFilter: serviceaccounts.FilterOnlyDisabled,
+		},
+		{
+			desc:         "should reject combined filters",
+			query:        "&disabled=true&expiredTokens=true",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:           "should keep the accounts with a role matching the query",
+			query:          "&role=Admin&query=build",
+			expectedCode:   http.StatusOK,
+			expectedFilter: serviceaccounts.FilterIncludeAll,
+			expectedRole:   models.ROLE_ADMIN,
+		},
+		{
+			desc:         "should reject an invalid role",
+			query:        "&role=Owner",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			store := &tests.ServiceAccountsStoreMock{}
+			saAPI := NewServiceAccountsAPI(setting.NewCfg(), &tests.ServiceAccountMock{}, accesscontrolmock.New().WithDisabled(), routing.NewRouteRegister(), store)
+
+			req := httptest.NewRequest(http.MethodGet, serviceAccountPath+"search?countOnly=true"+tc.query, nil)
+			c := &models.ReqContext{
+				Context:      &web.Context{Req: req},
+				SignedInUser: &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_ADMIN},
+			}
+
+			resp := saAPI.SearchOrgServiceAccountsWithPaging(c)
+			require.Equal(t, tc.expectedCode, resp.Status())
+			if tc.expectedCode != http.StatusOK {
+				assert.Empty(t, store.Calls.CountOrgServiceAccounts)
+				return
+			}
+
+			require.Len(t, store.Calls.CountOrgServiceAccounts, 1)
+			query := store.Calls.CountOrgServiceAccounts[0].([]interface{})[1].(*serviceaccounts.SearchOrgServiceAccountsQuery)
+			assert.Equal(t, tc.expectedFilter, query.Filter)
+			assert.Equal(t, c.Query("query"), query.Query)
+			assert.Equal(t, tc.expectedRole, query.Role)
+		})
+	}
+}
+
+func TestServiceAccountsAPI_ExportServiceAccounts(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+
+	build := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-build", Name: "build", Role: "Editor", IsServiceAccount: true})
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-deploy", Name: "deploy", Role: "Viewer", IsServiceAccount: true})
+	first := createTokenforSA(t, saStore, "build-1", build.OrgId, build.Id, 0)
+	createTokenforSA(t, saStore, "build-2", build.OrgId, build.Id, 0)
+
+	var export = func(server *web.Mux, query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, serviceAccountPath+"export"+query, nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should export the service accounts with their token metadata as CSV", func(t *testing.T) {
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+		actual := export(server, "?format=csv")
+		require.Equal(t, http.StatusOK, actual.Code, actual.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", actual.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="serviceaccounts-org-1.csv"`, actual.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(actual.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, []string{"id", "name", "role", "disabled", "tokens", "oldest_token_created"}, records[0])
+		assert.Equal(t, []string{fmt.Sprint(build.Id), "build", "Editor", "false", "2",
+			first.Created.UTC().Format(time.RFC3339)}, records[1])
+		assert.Equal(t, []string{"deploy", "Viewer", "false", "0", ""}, records[2][1:])
+	})
+
+	t.Run("should reject other formats", func(t *testing.T) {
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+		actual := export(server, "?format=xlsx")
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+	})
+
+	t.Run("should write every page of service accounts", func(t *testing.T) {
+		pagedStore := &pagedSearchStore{total: exportPageSize + 1}
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, pagedStore)
+		actual := export(server, "")
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		records, err := csv.NewReader(actual.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, exportPageSize+2)
+		assert.Equal(t, fmt.Sprint(exportPageSize+1), records[exportPageSize+1][0])
+		assert.Len(t, pagedStore.Calls.ListTokensForAccounts, 2)
+	})
+}
+
+// pagedSearchStore pages through total service accounts in SearchOrgServiceAccounts.
+type pagedSearchStore struct {
+	tests.ServiceAccountsStoreMock
+	total int
+}
+
+func (s *pagedSearchStore) SearchOrgServiceAccounts(ctx context.Context,
+	query *serviceaccounts.SearchOrgServiceAccountsQuery) (*serviceaccounts.SearchServiceAccountsResult, error) {
+	result := &serviceaccounts.SearchServiceAccountsResult{Page: query.Page, PerPage: query.Limit, TotalCount: int64(s.total)}
+	for i := (query.Page-1)*query.Limit + 1; i <= s.total && i <= query.Page*query.Limit; i++ {
+		result.ServiceAccounts = append(result.ServiceAccounts, &serviceaccounts.ServiceAccountDTO{
+			Id: int64(i), OrgId: query.OrgID, Name: fmt.Sprintf("sa-%d", i),
+		})
+	}
+	return result, nil
+}
+
+func TestServiceAccountsAPI_ProvisionServiceAccount(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{
+				{Action: serviceaccounts.ActionCreate},
+				{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll},
+			}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	var provision = func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, serviceAccountPath+"provision", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should create the service account and return each token secret", func(t *testing.T) {
+		actual := provision(`{
+			"serviceAccount": {"name": "ci", "role": "Editor"},
+			"tokens": [
+				{"name": "ci-deploy", "role": "Editor", "secondsToLive": 3600},
+				{"name": "ci-read", "role": "Viewer"}
+			]
+		}`)
+		require.Equal(t, http.StatusCreated, actual.Code, actual.Body.String())
+
+		result := ProvisionedServiceAccountDTO{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &result))
+		require.NotNil(t, result.ServiceAccount)
+		assert.Equal(t, "ci", result.ServiceAccount.Name)
+		assert.Equal(t, "Editor", result.ServiceAccount.Role)
+		assert.Equal(t, fmt.Sprintf(serviceAccountIDPath, result.ServiceAccount.Id), actual.Header().Get("Location"))
+
+		require.Len(t, result.Tokens, 2)
+		assert.Equal(t, "ci-deploy", result.Tokens[0].Name)
+		assert.NotEmpty(t, result.Tokens[0].Key)
+		assert.NotNil(t, result.Tokens[0].Expiration)
+		assert.Equal(t, "ci-read", result.Tokens[1].Name)
+		assert.NotEmpty(t, result.Tokens[1].Key)
+		assert.Nil(t, result.Tokens[1].Expiration)
+
+		keys, err := saStore.ListTokens(context.Background(), result.ServiceAccount.OrgId, result.ServiceAccount.Id)
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+	})
+
+	t.Run("should create nothing when a token fails", func(t *testing.T) {
+		actual := provision(`{
+			"serviceAccount": {"name": "rollback"},
+			"tokens": [
+				{"name": "rollback-token", "role": "Viewer"},
+				{"name": "rollback-token", "role": "Viewer"}
+			]
+		}`)
+		assert.Equal(t, http.StatusConflict, actual.Code)
+
+		ids, err := saStore.GetServiceAccountIDsByName(context.Background(), 1, "rollback")
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+		query := models.GetApiKeyByNameQuery{KeyName: "rollback-token", OrgId: 1}
+		assert.ErrorIs(t, store.GetApiKeyByName(context.Background(), &query), models.ErrInvalidApiKey)
+	})
+
+	t.Run("should validate the tokens before creating anything", func(t *testing.T) {
+		actual := provision(`{"serviceAccount": {"name": "invalid"}, "tokens": [{"name": "invalid-token", "role": "Owner"}]}`)
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+
+		ids, err := saStore.GetServiceAccountIDsByName(context.Background(), 1, "invalid")
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+}
+
+func TestServiceAccountsAPI_ProvisionServiceAccountDownload(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{
+				{Action: serviceaccounts.ActionCreate},
+				{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll},
+			}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	var provision = func(path, accept, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+	assertDownload := func(t *testing.T, actual *httptest.ResponseRecorder, name string, tokenNames ...string) {
+		t.Helper()
+		require.Equal(t, http.StatusCreated, actual.Code, actual.Body.String())
+		ids, err := saStore.GetServiceAccountIDsByName(context.Background(), 1, name)
+		require.NoError(t, err)
+		require.Len(t, ids, 1)
+
+		assert.Equal(t, "application/octet-stream", actual.Header().Get("Content-Type"))
+		assert.Equal(t, fmt.Sprintf(`attachment; filename="serviceaccount-%d-tokens.txt"`, ids[0]), actual.Header().Get("Content-Disposition"))
+		assert.Equal(t, "no-store", actual.Header().Get("Cache-Control"))
+		assert.Equal(t, fmt.Sprintf(serviceAccountIDPath, ids[0]), actual.Header().Get("Location"))
+
+		keys, err := saStore.ListTokens(context.Background(), 1, ids[0])
+		require.NoError(t, err)
+		hashes := make(map[string]string, len(keys))
+		for _, key := range keys {
+			hashes[key.Name] = key.Key
+		}
+
+		// one secret per line, in the order the tokens were declared
+		secrets := strings.Split(strings.TrimSuffix(actual.Body.String(), "\n"), "\n")
+		require.Len(t, secrets, len(tokenNames))
+		for i, secret := range secrets {
+			decoded, err := apikeygen.Decode(secret)
+			require.NoError(t, err)
+			assert.Equal(t, tokenNames[i], decoded.Name)
+			valid, err := apikeygen.IsValid(decoded, hashes[decoded.Name])
+			require.NoError(t, err)
+			assert.True(t, valid)
+		}
+	}
+
+	t.Run("should return the secret as an attachment with the download parameter", func(t *testing.T) {
+		actual := provision(serviceAccountPath+"provision?download=true", "",
+			`{"serviceAccount": {"name": "dl-param"}, "tokens": [{"name": "dl-param-token", "role": "Viewer"}]}`)
+		assertDownload(t, actual, "dl-param", "dl-param-token")
+	})
+
+	t.Run("should return the secrets as an attachment when an octet stream is accepted", func(t *testing.T) {
+		actual := provision(serviceAccountPath+"provision", "application/octet-stream",
+			`{"serviceAccount": {"name": "dl-accept"}, "tokens": [{"name": "dl-accept-1", "role": "Viewer"}, {"name": "dl-accept-2", "role": "Viewer"}]}`)
+		assertDownload(t, actual, "dl-accept", "dl-accept-1", "dl-accept-2")
+	})
+
+	t.Run("should reject a download without tokens", func(t *testing.T) {
+		actual := provision(serviceAccountPath+"provision?download=true", "", `{"serviceAccount": {"name": "dl-empty"}}`)
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+
+		ids, err := saStore.GetServiceAccountIDsByName(context.Background(), 1, "dl-empty")
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+	})
+
+	t.Run("should keep returning JSON by default", func(t *testing.T) {
+		actual := provision(serviceAccountPath+"provision", "",
+			`{"serviceAccount": {"name": "dl-json"}, "tokens": [{"name": "dl-json-token", "role": "Viewer"}]}`)
+		require.Equal(t, http.StatusCreated, actual.Code)
+		assert.Contains(t, actual.Header().Get("Content-Type"), "application/json")
+		assert.Empty(t, actual.Header().Get("Content-Disposition"))
+	})
+}
+
+func TestServiceAccountsAPI_ValidateName(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{
+				{Action: serviceaccounts.ActionCreate},
+				{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll},
+			}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-validate", Name: "validate", IsServiceAccount: true})
+
+	testCases := []struct {
+		desc         string
+		name         string
+		expectedCode int
+		wantError    string
+	}{
+		{
+			desc:         "should reject an empty name",
+			name:         "",
+			expectedCode: http.StatusBadRequest,
+			wantError:    "invalid name: must not be empty",
+		},
+		{
+			desc:         "should reject a blank name",
+			name:         "   ",
+			expectedCode: http.StatusBadRequest,
+			wantError:    "invalid name: must not be empty",
+		},
+		{
+			desc:         "should reject a name longer than the column",
+			name:         strings.Repeat("a", serviceaccounts.MaxNameLength+1),
+			expectedCode: http.StatusBadRequest,
+			wantError:    "invalid name: must be at most 190 characters long, got 191",
+		},
+		{
+			desc:         "should reject a name with a newline",
+			name:         "first\nsecond",
+			expectedCode: http.StatusBadRequest,
+			wantError:    "invalid name: must not contain control characters, found U+000A",
+		},
+		{
+			desc:         "should reject a name with a control character",
+			name:         "bell\a",
+			expectedCode: http.StatusBadRequest,
+			wantError:    "invalid name: must not contain control characters, found U+0007",
+		},
+		{
+			desc:         "should accept a name of the maximum length in multibyte characters",
+			name:         strings.Repeat("é", serviceaccounts.MaxNameLength),
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	var request = func(method, path string, body map[string]interface{}) (int, map[string]interface{}) {
+		marshalled, err := json.Marshal(body)
+		require.NoError(t, err)
+		req, err := http.NewRequest(method, path, bytes.NewReader(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+
+		result := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+		return recorder.Code, result
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Run("on create", func(t *testing.T) {
+				code, body := request(http.MethodPost, serviceAccountPath, map[string]interface{}{"name": tc.name})
+				if tc.expectedCode == http.StatusOK {
+					require.Equal(t, http.StatusCreated, code, body)
+					return
+				}
+				require.Equal(t, tc.expectedCode, code, body)
+				assert.Equal(t, tc.wantError, body["message"])
+			})
+
+			t.Run("on update", func(t *testing.T) {
+				name := tc.name
+				if tc.expectedCode == http.StatusOK {
+					name = strings.Repeat("ü", serviceaccounts.MaxNameLength-1) + fmt.Sprint(i)
+				}
+				code, body := request(http.MethodPatch, fmt.Sprintf(serviceAccountIDPath, sa.Id), map[string]interface{}{"name": name})
+				require.Equal(t, tc.expectedCode, code, body)
+				if tc.expectedCode != http.StatusOK {
+					assert.Equal(t, tc.wantError, body["message"])
+				}
+			})
+		})
+	}
+}
+
+func TestServiceAccountsAPI_NameDenylist(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{
+				{Action: serviceaccounts.ActionCreate},
+				{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll},
+			}, nil
+		},
+		false,
+	)
+	server, saAPI := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+	saAPI.cfg.ServiceAccountNameDenylist = []string{"admin", "root", "grafana-*"}
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-denylist", Name: "denylist", IsServiceAccount: true})
+
+	testCases := []struct {
+		desc         string
+		name         string
+		expectedCode int
+		wantError    string
+	}{
+		{
+			desc:         "should reject a denied name",
+			name:         "root",
+			expectedCode: http.StatusBadRequest,
+			wantError:    `invalid name: "root" is reserved`,
+		},
+		{
+			desc:         "should reject a denied name in another case",
+			name:         "Admin",
+			expectedCode: http.StatusBadRequest,
+			wantError:    `invalid name: "Admin" is reserved`,
+		},
+		{
+			desc:         "should reject a name with a denied prefix",
+			name:         "Grafana-Internal",
+			expectedCode: http.StatusBadRequest,
+			wantError:    `invalid name: names starting with "grafana-" are reserved`,
+		},
+		{
+			desc:         "should accept a name containing a denied name",
+			name:         "administrator",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "should accept a name containing a denied prefix",
+			name:         "my-grafana-bot",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	var request = func(method, path string, body map[string]interface{}) (int, map[string]interface{}) {
+		marshalled, err := json.Marshal(body)
+		require.NoError(t, err)
+		req, err := http.NewRequest(method, path, bytes.NewReader(marshalled))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+
+		result := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+		return recorder.Code, result
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			t.Run("on create", func(t *testing.T) {
+				code, body := request(http.MethodPost, serviceAccountPath, map[string]interface{}{"name": tc.name})
+				if tc.expectedCode == http.StatusOK {
+					require.Equal(t, http.StatusCreated, code, body)
+					return
+				}
+				require.Equal(t, tc.expectedCode, code, body)
+				assert.Equal(t, tc.wantError, body["message"])
+			})
+
+			t.Run("on update", func(t *testing.T) {
+				name := tc.name
+				if tc.expectedCode == http.StatusOK {
+					name += "-renamed"
+				}
+				code, body := request(http.MethodPatch, fmt.Sprintf(serviceAccountIDPath, sa.Id), map[string]interface{}{"name": name})
+				require.Equal(t, tc.expectedCode, code, body)
+				if tc.expectedCode != http.StatusOK {
+					assert.Equal(t, tc.wantError, body["message"])
+				}
+			})
+
+			t.Run("on provision", func(t *testing.T) {
+				name := tc.name
+				if tc.expectedCode == http.StatusOK {
+					name += "-provisioned"
+				}
+				code, body := request(http.MethodPost, serviceAccountPath+"provision",
+					map[string]interface{}{"serviceAccount": map[string]interface{}{"name": name}})
+				if tc.expectedCode == http.StatusOK {
+					require.Equal(t, http.StatusCreated, code, body)
+					return
+				}
+				require.Equal(t, tc.expectedCode, code, body)
+				assert.Equal(t, tc.wantError, body["message"])
+			})
+		})
+	}
+}
+
+func TestServiceAccountsAPI_ValidateServiceAccounts(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionCreate}}, nil
+		},
+		false,
+	)
+	server, saAPI := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+	saAPI.cfg.ServiceAccountNameDenylist = []string{"root"}
+	tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-taken", Name: "taken", IsServiceAccount: true})
+
+	validate := func(t *testing.T, server *web.Mux, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, serviceAccountPath+"validate", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should return the validation result of every form", func(t *testing.T) {
+		actual := validate(t, server, `[
+			{"name": "build-bot", "role": "Editor"},
+			{"name": "taken"},
+			{"name": "deploy", "role": "Owner"},
+			{"name": "root"},
+			{"name": "Sync Bot"},
+			{"name": "sync-bot"},
+			{"name": ""}
+		]`)
+		require.Equal(t, http.StatusOK, actual.Code, actual.Body.String())
+
+		batch := BatchValidationDTO{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &batch))
+		assert.False(t, batch.Valid)
+		require.Len(t, batch.Results, 7)
+		for i, result := range batch.Results {
+			assert.Equal(t, i, result.Index)
+		}
+
+		assert.True(t, batch.Results[0].Valid)
+		assert.Empty(t, batch.Results[0].Errors)
+
+		assert.False(t, batch.Results[1].Valid)
+		assert.Equal(t, []string{"a service account with that name already exists"}, batch.Results[1].Errors)
+
+		assert.False(t, batch.Results[2].Valid)
+		require.Len(t, batch.Results[2].Errors, 1)
+		assert.Contains(t, batch.Results[2].Errors[0], "invalid role value: Owner")
+
+		assert.False(t, batch.Results[3].Valid)
+		assert.Equal(t, []string{`invalid name: "root" is reserved`}, batch.Results[3].Errors)
+
+		assert.False(t, batch.Results[4].Valid)
+		assert.Equal(t, []string{"the name collides with the name of the service accounts at 5"}, batch.Results[4].Errors)
+		assert.False(t, batch.Results[5].Valid)
+		assert.Equal(t, []string{"the name collides with the name of the service accounts at 4"}, batch.Results[5].Errors)
+
+		assert.False(t, batch.Results[6].Valid)
+		require.Len(t, batch.Results[6].Errors, 1)
+		assert.Contains(t, batch.Results[6].Errors[0], "must not be empty")
+	})
+
+	t.Run("should not create any service account", func(t *testing.T) {
+		available, err := saStore.IsServiceAccountNameAvailable(context.Background(), "build-bot")
+		require.NoError(t, err)
+		assert.True(t, available)
+	})
+
+	t.Run("should be valid when every form is", func(t *testing.T) {
+		actual := validate(t, server, `[{"name": "first"}, {"name": "second", "role": "Admin"}]`)
+		require.Equal(t, http.StatusOK, actual.Code, actual.Body.String())
+
+		batch := BatchValidationDTO{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &batch))
+		assert.True(t, batch.Valid)
+	})
+
+	t.Run("should reject an empty batch", func(t *testing.T) {
+		actual := validate(t, server, `[]`)
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+	})
+
+	t.Run("should be forbidden without the permission to create service accounts", func(t *testing.T) {
+		acmock := tests.SetupMockAccesscontrol(
+			t,
+			func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+				return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+			},
+			false,
+		)
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+		actual := validate(t, server, `[{"name": "first"}]`)
+		assert.Equal(t, http.StatusForbidden, actual.Code)
+	})
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// auditMessage is the message of every audit log entry, so that they can be
+// selected with a single filter.
+const auditMessage = "Service account audit event"
+
+// Audited actions, logged with the action key.
+const (
+	auditCreateServiceAccount = "create"
+	auditUpdateServiceAccount = "update"
+	auditDeleteServiceAccount = "delete"
+	auditCreateToken          = "createToken"
+	auditDeleteToken          = "deleteToken"
+)
+
+// auditLog logs a change to the service account saID made by the signed in user. Extra
+// key/value pairs, like the tokenId of token changes, are appended to the entry.
+func (api *ServiceAccountsAPI) auditLog(c *models.ReqContext, action string, saID int64, ctx ...interface{}) {
+	entry := []interface{}{
+		"action", action,
+		"userId", c.UserId,
+		"orgId", c.OrgId,
+		"serviceAccountId", saID,
+	}
+	api.log.Info(auditMessage, append(entry, ctx...)...)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts/database"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts/tests"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// capturingLogger keeps the context of the Info entries logged with the audit message.
+type capturingLogger struct {
+	log.Logger
+	entries []map[string]interface{}
+}
+
+func (l *capturingLogger) Info(msg string, ctx ...interface{}) {
+	if msg != auditMessage {
+		return
+	}
+	entry := map[string]interface{}{}
+	for i := 0; i+1 < len(ctx); i += 2 {
+		entry[ctx[i].(string)] = ctx[i+1]
+	}
+	l.entries = append(l.entries, entry)
+}
+
+func TestServiceAccountsAPI_AuditLog(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{
+				{Action: serviceaccounts.ActionCreate},
+				{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll},
+				{Action: serviceaccounts.ActionDelete, Scope: serviceaccounts.ScopeAll},
+			}, nil
+		},
+		false,
+	)
+	server, saAPI := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+	logger := &capturingLogger{Logger: saAPI.log}
+	saAPI.log = logger
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Add("Content-Type", "application/json")
+		actual := httptest.NewRecorder()
+		server.ServeHTTP(actual, req)
+		return actual
+	}
+	lastEntry := func(t *testing.T) map[string]interface{} {
+		t.Helper()
+		require.NotEmpty(t, logger.entries)
+		return logger.entries[len(logger.entries)-1]
+	}
+
+	var saID, tokenID int64
+	t.Run("should log the creation of a service account", func(t *testing.T) {
+		actual := request(http.MethodPost, serviceAccountPath, `{"name": "audited"}`)
+		require.Equal(t, http.StatusCreated, actual.Code)
+		created := serviceaccounts.ServiceAccountDTO{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &created))
+		saID = created.Id
+
+		assert.Equal(t, map[string]interface{}{
+			"action":           auditCreateServiceAccount,
+			"userId":           int64(1),
+			"orgId":            int64(1),
+			"serviceAccountId": saID,
+		}, lastEntry(t))
+	})
+
+	t.Run("should log the update of a service account", func(t *testing.T) {
+		actual := request(http.MethodPatch, fmt.Sprintf(serviceAccountIDPath, saID), `{"name": "audited-renamed"}`)
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		entry := lastEntry(t)
+		assert.Equal(t, auditUpdateServiceAccount, entry["action"])
+		assert.Equal(t, saID, entry["serviceAccountId"])
+	})
+
+	t.Run("should log the creation of a token with its ID", func(t *testing.T) {
+		actual := request(http.MethodPost, fmt.Sprintf(serviceaccountIDTokensPath, saID), `{"name": "audited-token", "role": "Viewer"}`)
+		require.Equal(t, http.StatusOK, actual.Code)
+		created := NewTokenDTO{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &created))
+		tokenID = created.ID
+
+		assert.Equal(t, map[string]interface{}{
+			"action":           auditCreateToken,
+			"userId":           int64(1),
+			"orgId":            int64(1),
+			"serviceAccountId": saID,
+			"tokenId":          tokenID,
+		}, lastEntry(t))
+	})
+
+	t.Run("should log the deletion of a token with its ID", func(t *testing.T) {
+		actual := request(http.MethodDelete, fmt.Sprintf(serviceaccountIDTokensPath+"/%d", saID, tokenID), "")
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		entry := lastEntry(t)
+		assert.Equal(t, auditDeleteToken, entry["action"])
+		assert.Equal(t, saID, entry["serviceAccountId"])
+		assert.Equal(t, tokenID, entry["tokenId"])
+	})
+
+	t.Run("should log the deletion of a service account", func(t *testing.T) {
+		actual := request(http.MethodDelete, fmt.Sprintf(serviceAccountIDPath, saID), "")
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		entry := lastEntry(t)
+		assert.Equal(t, auditDeleteServiceAccount, entry["action"])
+		assert.Equal(t, int64(1), entry["userId"])
+		assert.Equal(t, int64(1), entry["orgId"])
+		assert.Equal(t, saID, entry["serviceAccountId"])
+	})
+
+	t.Run("should not log failed changes", func(t *testing.T) {
+		logged := len(logger.entries)
+		actual := request(http.MethodPatch, fmt.Sprintf(serviceAccountIDPath, saID+100), `{"name": "missing"}`)
+		require.Equal(t, http.StatusNotFound, actual.Code)
+		assert.Len(t, logger.entries, logged)
+	})
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	halContentType    = "application/hal+json"
+	// downloadContentType asks for the token secrets of a provisioned service account as a file
+	downloadContentType = "application/octet-stream"
+)
+
+// debugHeader lets a Grafana admin see the cause of internal errors outside of development mode.
+const debugHeader = "X-Grafana-Debug"
+
+const (
+	camelCase = "camelCase"
+	snakeCase = "snake_case"
+)
+
+// accepts reports whether the request's Accept header lists the given media type.
+func accepts(c *models.ReqContext, mediaType string) bool {
+	for _, accept := range strings.Split(c.Req.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(strings.TrimSpace(accept)); err == nil && mt == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// debugErrorsAllowed reports whether error causes may be returned: always in development
+// mode, otherwise only to Grafana admins sending the debug header.
+func (api *ServiceAccountsAPI) debugErrorsAllowed(c *models.ReqContext) bool {
+	if api.cfg.Env == setting.Dev {
+		return true
+	}
+	return c.Req.Header.Get(debugHeader) == "true" && c.SignedInUser != nil && c.SignedInUser.IsGrafanaAdmin
+}
+
+// ndjsonResponse writes each service account as a single JSON object per line.
+func (api *ServiceAccountsAPI) ndjsonResponse(c *models.ReqContext, serviceAccounts []*serviceaccounts.ServiceAccountDTO) response.Response {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, sa := range serviceAccounts {
+		body, ok := casedBody(c, sa)
+		if !ok {
+			return api.unsupportedCasingResponse(c)
+		}
+		if err := enc.Encode(body); err != nil {
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to encode service accounts", err)
+		}
+	}
+	return response.Respond(http.StatusOK, buf.Bytes()).SetHeader("Content-Type", ndjsonContentType)
+}
+
+// halLink is a link of a HAL response.
+type halLink struct {
+	Href string `json:"href"`
+}
+
+// halServiceAccount is a service account with links to its related resources, see
+// https://datatracker.ietf.org/doc/html/draft-kelly-json-hal
+type halServiceAccount struct {
+	*serviceaccounts.ServiceAccountProfileDTO
+	Links map[string]halLink `json:"_links"`
+}
+
+// halResponse renders a service account as HAL, with links to itself, its tokens and its org.
+func (api *ServiceAccountsAPI) halResponse(c *models.ReqContext, serviceAccount *serviceaccounts.ServiceAccountProfileDTO) response.Response {
+	self := fmt.Sprintf("%s/api/serviceaccounts/%d", api.cfg.AppSubURL, serviceAccount.Id)
+	body := halServiceAccount{
+		ServiceAccountProfileDTO: serviceAccount,
+		Links: map[string]halLink{
+			"self":   {Href: self},
+			"tokens": {Href: self + "/tokens"},
+			"org":    {Href: fmt.Sprintf("%s/api/orgs/%d", api.cfg.AppSubURL, serviceAccount.OrgId)},
+		},
+	}
+	return api.jsonResponse(c, http.StatusOK, body).SetHeader("Content-Type", halContentType)
+}
+
+// requestLocation returns the time zone asked for with the tz query parameter
+// or the Accept-Timezone header, defaulting to UTC.
+func requestLocation(c *models.ReqContext) (*time.Location, error) {
+	tz := c.Query("tz")
+	if tz == "" {
+		tz = c.Req.Header.Get("Accept-Timezone")
+	}
+	if tz == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(tz)
+}
+
+// jsonResponse renders v with its JSON field names in the casing requested with
+// the casing query parameter. camelCase is the default; snake_case is opt-in.
+// Every JSON response of the service account API goes through it.
+func (api *ServiceAccountsAPI) jsonResponse(c *models.ReqContext, status int, v interface{}) *response.NormalResponse {
+	body, ok := casedBody(c, v)
+	if !ok {
+		return api.unsupportedCasingResponse(c)
+	}
+	return response.JSON(status, body)
+}
+
+// successResponse is the JSON response of a successful request without other result.
+func (api *ServiceAccountsAPI) successResponse(c *models.ReqContext, message string) *response.NormalResponse {
+	return api.jsonResponse(c, http.StatusOK, util.DynMap{"message": message})
+}
+
+// requireSupportedCasing rejects a request with an unsupported casing before its handler runs,
+// so that it doesn't change anything only to fail when its response is rendered.
+func (api *ServiceAccountsAPI) requireSupportedCasing(c *models.ReqContext) response.Response {
+	if _, ok := casedBody(c, nil); !ok {
+		return api.unsupportedCasingResponse(c)
+	}
+	return nil
+}
+
+func (api *ServiceAccountsAPI) unsupportedCasingResponse(c *models.ReqContext) *response.NormalResponse {
+	return api.errorResponse(c, http.StatusBadRequest, fmt.Sprintf("Unsupported casing %q", c.Query("casing")), nil)
+}
+
+// casedBody wraps v so that it is marshalled in the casing of the request,
+// it returns false when the casing isn't supported.
+func casedBody(c *models.ReqContext, v interface{}) (interface{}, bool) {
+	switch c.Query("casing") {
+	case "", camelCase:
+		return v, true
+	case snakeCase:
+		return snakeCaseJSON{v}, true
+	default:
+		return nil, false
+	}
+}
+
+// snakeCaseJSON marshals the wrapped value with every object key converted to snake_case.
+type snakeCaseJSON struct {
+	v interface{}
+}
+
+func (s snakeCaseJSON) MarshalJSON() ([]byte, error) {
+	raw, err := json.Marshal(s.v)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&decoded); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(snakeCaseKeys(decoded))
+}
+
+func snakeCaseKeys(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			converted[toSnakeCase(k)] = snakeCaseKeys(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range value {
+			value[i] = snakeCaseKeys(item)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+	"github.com/grafana/grafana/pkg/setting"
+	cw "github.com/weaveworks/common/tracing"
+)
+
+// errorEnvelope is the body of every error response of the service account API.
+type errorEnvelope struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+	MessageID  string `json:"messageId"`
+	TraceID    string `json:"traceId"`
+	// Error is the cause of the error, only returned when debugging is allowed.
+	Error string `json:"error,omitempty"`
+}
+
+// errorMessageIDs identifies the kind of error independently of the message,
+// so that clients don't have to match on the human readable text.
+var errorMessageIDs = map[int]string{
+	http.StatusBadRequest:          "serviceaccounts.badRequest",
+	http.StatusForbidden:           "serviceaccounts.forbidden",
+	http.StatusNotFound:            "serviceaccounts.notFound",
+	http.StatusConflict:            "serviceaccounts.conflict",
+	http.StatusInternalServerError: "serviceaccounts.internalError",
+}
+
+// errorResponse creates an error response with the errorEnvelope body. The cause is
+// logged, and returned outside of production or when debugging is allowed for the request.
+func (api *ServiceAccountsAPI) errorResponse(c *models.ReqContext, status int, message string, err error) *response.NormalResponse {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	messageID, ok := errorMessageIDs[status]
+	if !ok {
+		messageID = "serviceaccounts.error"
+	}
+
+	envelope := errorEnvelope{
+		StatusCode: status,
+		Message:    message,
+		MessageID:  messageID,
+	}
+	if traceID, ok := cw.ExtractTraceID(c.Req.Context()); ok {
+		envelope.TraceID = traceID
+	}
+
+	if err != nil {
+		api.log.Error(message, "error", err, "traceID", envelope.TraceID)
+		if setting.Env != setting.Prod || api.debugErrorsAllowed(c) {
+			envelope.Error = err.Error()
+		}
+	}
+
+	// the envelope follows the requested casing too, an unsupported one is the error being reported
+	if body, ok := casedBody(c, envelope); ok {
+		return response.JSON(status, body)
+	}
+	return response.JSON(status, envelope)
+}
+
+// bindErrorResponse is the bad request response of a body that couldn't be bound. Invalid
+// fields are reported in the message, so that clients know which one to fix.
+func (api *ServiceAccountsAPI) bindErrorResponse(c *models.ReqContext, err error) response.Response {
+	var invalidField *serviceaccounts.ErrInvalidField
+	if errors.As(err, &invalidField) {
+		return api.errorResponse(c, http.StatusBadRequest, invalidField.Error(), nil)
+	}
+	return api.errorResponse(c, http.StatusBadRequest, "Bad request data", err)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+)
+
+// exportPageSize is how many service accounts are loaded and written at a time during an export
+const exportPageSize = 500
+
+var exportCSVHeader = []string{"id", "name", "role", "disabled", "tokens", "oldest_token_created"}
+
+// GET /api/serviceaccounts/export
+//
+// ExportServiceAccounts writes every service account of the org as CSV, with its token count and
+// when its oldest token was created. The rows are written a page at a time as they are loaded.
+func (api *ServiceAccountsAPI) ExportServiceAccounts(c *models.ReqContext) response.Response {
+	if format := c.Query("format"); format != "" && format != "csv" {
+		return api.errorResponse(c, http.StatusBadRequest, fmt.Sprintf("Unsupported export format %q", format), nil)
+	}
+
+	query := &serviceaccounts.SearchOrgServiceAccountsQuery{
+		OrgID:        c.OrgId,
+		Filter:       serviceaccounts.FilterIncludeAll,
+		Page:         1,
+		Limit:        exportPageSize,
+		SortOpts:     serviceaccounts.SortOpts{Field: serviceaccounts.SortByName},
+		SignedInUser: c.SignedInUser,
+	}
+	// load the first page up front so that failures can still be reported with an error status
+	rows, err := api.exportPage(c.Req.Context(), query)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to export service accounts", err)
+	}
+
+	return &csvExportResponse{
+		api:      api,
+		query:    query,
+		rows:     rows,
+		filename: fmt.Sprintf("serviceaccounts-org-%d.csv", c.OrgId),
+	}
+}
+
+// exportPage returns the CSV rows of the service accounts on the page of the query.
+func (api *ServiceAccountsAPI) exportPage(ctx context.Context, query *serviceaccounts.SearchOrgServiceAccountsQuery) ([][]string, error) {
+	result, err := api.store.SearchOrgServiceAccounts(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.ServiceAccounts) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, 0, len(result.ServiceAccounts))
+	for _, sa := range result.ServiceAccounts {
+		ids = append(ids, sa.Id)
+	}
+	tokens, err := api.store.ListTokensForServiceAccounts(ctx, query.OrgID, ids)
+	if err != nil {
+		return nil, err
+	}
+	oldest := make(map[int64]time.Time, len(ids))
+	for _, t := range tokens {
+		if t.ServiceAccountId == nil {
+			continue
+		}
+		if created, ok := oldest[*t.ServiceAccountId]; !ok || t.Created.Before(created) {
+			oldest[*t.ServiceAccountId] = t.Created
+		}
+	}
+
+	rows := make([][]string, 0, len(result.ServiceAccounts))
+	for _, sa := range result.ServiceAccounts {
+		oldestCreated := ""
+		if created, ok := oldest[sa.Id]; ok {
+			oldestCreated = created.UTC().Format(time.RFC3339)
+		}
+		rows = append(rows, []string{
+			strconv.FormatInt(sa.Id, 10),
+			sa.Name,
+			sa.Role,
+			strconv.FormatBool(sa.IsDisabled),
+			strconv.FormatInt(sa.Tokens, 10),
+			oldestCreated,
+		})
+	}
+	return rows, nil
+}
+
+// csvExportResponse streams a service account export, loading the pages after the first one
+// while writing. Errors past the first page can only be logged, the export is then cut short.
+type csvExportResponse struct {
+	api      *ServiceAccountsAPI
+	query    *serviceaccounts.SearchOrgServiceAccountsQuery
+	rows     [][]string
+	filename string
+}
+
+func (r *csvExportResponse) Status() int {
+	return http.StatusOK
+}
+
+func (r *csvExportResponse) Body() []byte {
+	return nil
+}
+
+func (r *csvExportResponse) WriteTo(c *models.ReqContext) {
+	header := c.Resp.Header()
+	header.Set("Content-Type", "text/csv; charset=utf-8")
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", r.filename))
+	c.Resp.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(c.Resp)
+	if err := w.Write(exportCSVHeader); err != nil {
+		c.Logger.Error("Error writing to response", "err", err)
+		return
+	}
+
+	rows := r.rows
+	for {
+		if err := w.WriteAll(rows); err != nil {
+			c.Logger.Error("Error writing to response", "err", err)
+			return
+		}
+		c.Resp.Flush()
+		if len(rows) < r.query.Limit {
+			return
+		}
+
+		r.query.Page++
+		var err error
+		if rows, err = r.api.exportPage(c.Req.Context(), r.query); err != nil {
+			c.Logger.Error("Failed to export service accounts", "page", r.query.Page, "error", err)
+			return
+		}
+	}
+}
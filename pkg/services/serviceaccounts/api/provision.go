@@ -0,0 +1,134 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/components/apikeygen"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts/database"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// provisionForm declares a service account along with the tokens to create for it
+type provisionForm struct {
+	ServiceAccount serviceaccounts.CreateServiceAccountForm `json:"serviceAccount"`
+	Tokens         []*models.AddApiKeyCommand               `json:"tokens"`
+}
+
+// ProvisionedServiceAccountDTO is a provisioned service account with the secrets of its tokens.
+// The secrets can't be retrieved again.
+type ProvisionedServiceAccountDTO struct {
+	ServiceAccount *serviceaccounts.ServiceAccountDTO `json:"serviceAccount"`
+	Tokens         []*NewTokenDTO                     `json:"tokens"`
+}
+
+// POST /api/serviceaccounts/provision
+//
+// ProvisionServiceAccount creates a service account and its tokens in a single transaction,
+// so that either all of them are created or none is.
+func (api *ServiceAccountsAPI) ProvisionServiceAccount(c *models.ReqContext) response.Response {
+	form := provisionForm{}
+	if err := web.Bind(c.Req, &form); err != nil {
+		return api.bindErrorResponse(c, err)
+	}
+	if err := serviceaccounts.CheckNameAllowed(api.cfg, form.ServiceAccount.Name); err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	}
+
+	download := c.QueryBool("download") || accepts(c, downloadContentType)
+	if download && len(form.Tokens) == 0 {
+		return api.errorResponse(c, http.StatusBadRequest, "Downloading requires at least one token", nil)
+	}
+
+	if form.ServiceAccount.Role == nil {
+		form.ServiceAccount.Role = api.defaultRole()
+	} else if !form.ServiceAccount.Role.IsValid() {
+		return api.errorResponse(c, http.StatusBadRequest, "Invalid role specified", nil)
+	}
+
+	// tokens of admin service accounts grant full control over the organization,
+	// so they have to be asked for explicitly
+	if *form.ServiceAccount.Role == models.ROLE_ADMIN && len(form.Tokens) > 0 && !c.QueryBool("confirm") {
+		return api.errorResponse(c, http.StatusBadRequest,
+			"Service account has the Admin role, set confirm=true to create tokens for it", nil)
+	}
+
+	if limit := api.cfg.ServiceAccountTokensPerAccountLimit; limit >= 0 && int64(len(form.Tokens)) > limit {
+		return api.errorResponse(c, http.StatusForbidden,
+			fmt.Sprintf("Token limit reached, a service account can have at most %d tokens", limit), nil)
+	}
+
+	secrets := make([]string, len(form.Tokens))
+	for i, cmd := range form.Tokens {
+		if resp := api.validateToken(c, cmd); resp != nil {
+			return resp
+		}
+
+		newKeyInfo, err := apikeygen.New(c.OrgId, cmd.Name)
+		if err != nil {
+			return api.errorResponse(c, http.StatusInternalServerError, "Generating API key failed", err)
+		}
+		cmd.Key = newKeyInfo.HashedKey
+		secrets[i] = newKeyInfo.ClientSecret
+	}
+
+	if resp := api.checkOrgLimit(c); resp != nil {
+		return resp
+	}
+
+	serviceAccount, err := api.store.ProvisionServiceAccount(c.Req.Context(), c.OrgId, &form.ServiceAccount, form.Tokens)
+	switch {
+	case errors.Is(err, serviceaccounts.ErrServiceAccountAlreadyExists):
+		return api.errorResponse(c, http.StatusConflict, "a service account with that name already exists in this organization", err)
+	case errors.As(err, new(*database.ErrSAInvalidLogin)):
+		return api.errorResponse(c, http.StatusBadRequest, err.Error(), nil)
+	case errors.Is(err, models.ErrDuplicateApiKey):
+		return api.errorResponse(c, http.StatusConflict, err.Error(), nil)
+	case err != nil:
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to provision service account", err)
+	}
+	api.auditLog(c, auditCreateServiceAccount, serviceAccount.Id)
+
+	result := &ProvisionedServiceAccountDTO{
+		ServiceAccount: serviceAccount,
+		Tokens:         make([]*NewTokenDTO, 0, len(form.Tokens)),
+	}
+	for i, cmd := range form.Tokens {
+		token := &NewTokenDTO{
+			NewApiKeyResult: dtos.NewApiKeyResult{
+				ID:   cmd.Result.Id,
+				Name: cmd.Result.Name,
+				Key:  secrets[i],
+			},
+			Expiration: tokenExpiration(cmd.Result),
+		}
+		result.Tokens = append(result.Tokens, token)
+		api.auditLog(c, auditCreateToken, serviceAccount.Id, "tokenId", cmd.Result.Id)
+	}
+
+	location := fmt.Sprintf("%s/api/serviceaccounts/%d", api.cfg.AppSubURL, serviceAccount.Id)
+	if download {
+		return tokenDownloadResponse(serviceAccount, result.Tokens).SetHeader("Location", location)
+	}
+	return api.jsonResponse(c, http.StatusCreated, result).SetHeader("Location", location)
+}
+
+// tokenDownloadResponse returns the token secrets as a file attachment, one secret per line
+// in the order the tokens were declared. Like the JSON response it is the only time they're shown.
+func tokenDownloadResponse(serviceAccount *serviceaccounts.ServiceAccountDTO, tokens []*NewTokenDTO) *response.NormalResponse {
+	var buf bytes.Buffer
+	for _, token := range tokens {
+		buf.WriteString(token.Key)
+		buf.WriteByte('\n')
+	}
+	return response.Respond(http.StatusCreated, buf.Bytes()).
+		SetHeader("Content-Type", downloadContentType).
+		SetHeader("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("serviceaccount-%d-tokens.txt", serviceAccount.Id))).
+		SetHeader("Cache-Control", "no-store")
+}
@@ -2,21 +2,28 @@ package api
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/api/response"
 	"github.com/grafana/grafana/pkg/components/apikeygen"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts"
 	"github.com/grafana/grafana/pkg/web"
 )
 
 const failedToDeleteMsg = "Failed to delete API key"
 
-type TokenDTO struct {
+// TokenReadDTO is a token as returned by the endpoints reading tokens. It has no field for the secret,
+// which is only returned when a token is created or renamed, so that reads can't expose it.
+type TokenReadDTO struct {
 	Id                     int64           `json:"id"`
 	Name                   string          `json:"name"`
 	Role                   models.RoleType `json:"role"`
@@ -24,6 +31,40 @@ type TokenDTO struct {
 	Expiration             *time.Time      `json:"expiration"`
 	SecondsUntilExpiration *float64        `json:"secondsUntilExpiration"`
 	HasExpired             bool            `json:"hasExpired"`
+	IsPaused               bool            `json:"isPaused"`
+	LastUsedAt             *time.Time      `json:"lastUsedAt"`
+	IsReadOnly             bool            `json:"isReadOnly"`
+	// OrgId is the org the token can be used in. Tokens are scoped to the org of their service
+	// account, so this is always the owning org; Global is reserved for tokens usable in every
+	// org, which don't exist yet, and is false for all current tokens.
+	OrgId  int64 `json:"orgId"`
+	Global bool  `json:"global"`
+	// IsAccountDisabled is set when listing the tokens of a disabled service account. They are kept
+	// but don't authenticate until the account is enabled again, whether they have expired or not.
+	IsAccountDisabled bool `json:"isAccountDisabled"`
+	// Hash is the one-way hash of the token secret, as stored for authentication. It can be used to
+	// correlate tokens with external records and is only returned to callers that can write the
+	// service account; the secret itself is never returned.
+	Hash string `json:"hash,omitempty"`
+}
+
+// ServiceAccountTokensDTO groups the tokens of one service account
+type ServiceAccountTokensDTO struct {
+	ServiceAccountId int64           `json:"serviceAccountId"`
+	Tokens           []*TokenReadDTO `json:"tokens"`
+}
+
+// createTokenForm is the body of a token creation. The token lifetime is set either
+// in seconds with secondsToLive or as a point in time with expiration.
+type createTokenForm struct {
+	models.AddApiKeyCommand
+	Expiration *time.Time `json:"expiration"`
+}
+
+// NewTokenDTO is a newly created token, with its secret and the time it expires at
+type NewTokenDTO struct {
+	dtos.NewApiKeyResult
+	Expiration *time.Time `json:"expiration"`
 }
 
 func hasExpired(expiration *int64) bool {
@@ -39,38 +80,287 @@ const sevenDaysAhead = 7 * 24 * time.Hour
 func (api *ServiceAccountsAPI) ListTokens(ctx *models.ReqContext) response.Response {
 	saID, err := strconv.ParseInt(web.Params(ctx.Req)[":serviceAccountId"], 10, 64)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "Service Account ID is invalid", err)
+		return api.errorResponse(ctx, http.StatusBadRequest, "Service Account ID is invalid", err)
+	}
+
+	serviceAccount, err := api.store.RetrieveServiceAccount(ctx.Req.Context(), ctx.OrgId, saID)
+	if err != nil {
+		switch {
+		case errors.Is(err, serviceaccounts.ErrServiceAccountNotFound):
+			return api.errorResponse(ctx, http.StatusNotFound, "Failed to retrieve service account", err)
+		default:
+			return api.errorResponse(ctx, http.StatusInternalServerError, "Failed to retrieve service account", err)
+		}
 	}
+	isAccountDisabled := serviceAccount != nil && serviceAccount.IsDisabled
 
 	if saTokens, err := api.store.ListTokens(ctx.Req.Context(), ctx.OrgId, saID); err == nil {
-		result := make([]*TokenDTO, len(saTokens))
+		hashes := api.newTokenHashes(ctx)
+		result := make([]*TokenReadDTO, len(saTokens))
 		for i, t := range saTokens {
-			var expiration *time.Time = nil
-			var secondsUntilExpiration float64 = 0
-
-			isExpired := hasExpired(t.Expires)
-			if t.Expires != nil {
-				v := time.Unix(*t.Expires, 0)
-				expiration = &v
-				if !isExpired && (*expiration).Before(time.Now().Add(sevenDaysAhead)) {
-					secondsUntilExpiration = time.Until(*expiration).Seconds()
-				}
+			result[i] = tokenToDTO(t)
+			result[i].IsAccountDisabled = isAccountDisabled
+			if err := hashes.set(result[i], t); err != nil {
+				return api.errorResponse(ctx, http.StatusInternalServerError, "Failed to evaluate permissions", err)
 			}
+		}
+
+		return api.jsonResponse(ctx, http.StatusOK, result)
+	} else {
+		return api.errorResponse(ctx, http.StatusInternalServerError, "Internal server error", err)
+	}
+}
+
+// GET /api/serviceaccounts/:serviceAccountId/tokens/:tokenId
+//
+// GetToken returns the metadata of a single token, e.g. to check its expiration before rotating it.
+func (api *ServiceAccountsAPI) GetToken(c *models.ReqContext) response.Response {
+	saID, err := strconv.ParseInt(web.Params(c.Req)[":serviceAccountId"], 10, 64)
+	if err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Service Account ID is invalid", err)
+	}
+
+	tokenID, err := strconv.ParseInt(web.Params(c.Req)[":tokenId"], 10, 64)
+	if err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Token ID is invalid", err)
+	}
 
-			result[i] = &TokenDTO{
-				Id:                     t.Id,
-				Name:                   t.Name,
-				Role:                   t.Role,
-				Created:                &t.Created,
-				Expiration:             expiration,
-				SecondsUntilExpiration: &secondsUntilExpiration,
-				HasExpired:             isExpired,
+	saTokens, err := api.store.ListTokens(c.Req.Context(), c.OrgId, saID)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to retrieve API key", err)
+	}
+	for _, t := range saTokens {
+		if t.Id == tokenID {
+			token := tokenToDTO(t)
+			if err := api.newTokenHashes(c).set(token, t); err != nil {
+				return api.errorResponse(c, http.StatusInternalServerError, "Failed to evaluate permissions", err)
 			}
+			return api.jsonResponse(c, http.StatusOK, token)
 		}
+	}
+	return api.errorResponse(c, http.StatusNotFound, "Failed to retrieve API key", models.ErrApiKeyNotFound)
+}
 
-		return response.JSON(http.StatusOK, result)
-	} else {
-		return response.Error(http.StatusInternalServerError, "Internal server error", err)
+// POST /api/serviceaccounts/tokens/list
+func (api *ServiceAccountsAPI) ListTokensForServiceAccounts(c *models.ReqContext) response.Response {
+	type listTokensForm struct {
+		ServiceAccountIds []int64 `json:"serviceAccountIds"`
+	}
+	form := listTokensForm{}
+	if err := web.Bind(c.Req, &form); err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Bad request data", err)
+	}
+
+	if !api.accesscontrol.IsDisabled() {
+		for _, saID := range form.ServiceAccountIds {
+			scope := accesscontrol.Scope("serviceaccounts", "id", strconv.FormatInt(saID, 10))
+			hasAccess, err := api.accesscontrol.Evaluate(c.Req.Context(), c.SignedInUser,
+				accesscontrol.EvalPermission(serviceaccounts.ActionRead, scope))
+			if err != nil {
+				return api.errorResponse(c, http.StatusInternalServerError, "Failed to evaluate permissions", err)
+			}
+			if !hasAccess {
+				return api.errorResponse(c, http.StatusForbidden, fmt.Sprintf("Not allowed to read tokens of service account %d", saID), nil)
+			}
+		}
+	}
+
+	saTokens, err := api.store.ListTokensForServiceAccounts(c.Req.Context(), c.OrgId, form.ServiceAccountIds)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Internal server error", err)
+	}
+
+	hashes := api.newTokenHashes(c)
+	tokensByAccount := make(map[int64][]*TokenReadDTO, len(form.ServiceAccountIds))
+	for _, t := range saTokens {
+		if t.ServiceAccountId == nil {
+			continue
+		}
+		token := tokenToDTO(t)
+		if err := hashes.set(token, t); err != nil {
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to evaluate permissions", err)
+		}
+		tokensByAccount[*t.ServiceAccountId] = append(tokensByAccount[*t.ServiceAccountId], token)
+	}
+
+	result := make([]*ServiceAccountTokensDTO, 0, len(form.ServiceAccountIds))
+	for _, saID := range form.ServiceAccountIds {
+		tokens := tokensByAccount[saID]
+		if tokens == nil {
+			tokens = []*TokenReadDTO{}
+		}
+		result = append(result, &ServiceAccountTokensDTO{ServiceAccountId: saID, Tokens: tokens})
+	}
+
+	return api.jsonResponse(c, http.StatusOK, result)
+}
+
+// ExpiringTokenDTO is a token along with the service account it belongs to
+type ExpiringTokenDTO struct {
+	*TokenReadDTO
+	ServiceAccountId    int64  `json:"serviceAccountId"`
+	ServiceAccountName  string `json:"serviceAccountName"`
+	ServiceAccountLogin string `json:"serviceAccountLogin"`
+}
+
+const (
+	defaultExpiringTokensLimit = 5
+	maxExpiringTokensLimit     = 100
+)
+
+// GET /api/serviceaccounts/tokens/expiringSoon
+//
+// ListExpiringTokens returns the tokens of the org that expire next, limit (5 by default)
+// of them. Tokens that never expire are left out.
+func (api *ServiceAccountsAPI) ListExpiringTokens(c *models.ReqContext) response.Response {
+	limit := c.QueryInt("limit")
+	if limit <= 0 {
+		limit = defaultExpiringTokensLimit
+	} else if limit > maxExpiringTokensLimit {
+		limit = maxExpiringTokensLimit
+	}
+
+	tokens, err := api.store.ListExpiringTokens(c.Req.Context(), c.OrgId, time.Now(), limit)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Internal server error", err)
+	}
+
+	hashes := api.newTokenHashes(c)
+	result := make([]*ExpiringTokenDTO, 0, len(tokens))
+	for _, t := range tokens {
+		dto := &ExpiringTokenDTO{
+			TokenReadDTO:        tokenToDTO(&t.ApiKey),
+			ServiceAccountName:  t.ServiceAccountName,
+			ServiceAccountLogin: t.ServiceAccountLogin,
+		}
+		if err := hashes.set(dto.TokenReadDTO, &t.ApiKey); err != nil {
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to evaluate permissions", err)
+		}
+		if t.ServiceAccountId != nil {
+			dto.ServiceAccountId = *t.ServiceAccountId
+		}
+		result = append(result, dto)
+	}
+
+	return api.jsonResponse(c, http.StatusOK, result)
+}
+
+// DormantTokenDTO is a token that would be revoked for not being used within the dormancy window
+type DormantTokenDTO struct {
+	*TokenReadDTO
+	ServiceAccountId int64 `json:"serviceAccountId"`
+}
+
+// GET /api/serviceaccounts/tokens/dormant
+//
+// ListDormantTokens is a dry run of the dormant token revocation: it returns the tokens of
+// the org that haven't been used within the dormancy window, without revoking them. The window
+// parameter, a duration like 720h, replaces the configured window.
+func (api *ServiceAccountsAPI) ListDormantTokens(c *models.ReqContext) response.Response {
+	window := api.cfg.ServiceAccountTokenDormancyWindow
+	if param := c.Query("window"); param != "" {
+		var err error
+		if window, err = time.ParseDuration(param); err != nil {
+			return api.errorResponse(c, http.StatusBadRequest, "Invalid dormancy window", err)
+		}
+	}
+	if window <= 0 {
+		return api.errorResponse(c, http.StatusBadRequest, "No dormancy window configured, set the window parameter", nil)
+	}
+
+	tokens, err := api.store.ListDormantTokens(c.Req.Context(), c.OrgId, time.Now().Add(-window))
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Internal server error", err)
+	}
+
+	hashes := api.newTokenHashes(c)
+	result := make([]*DormantTokenDTO, 0, len(tokens))
+	for _, t := range tokens {
+		dto := &DormantTokenDTO{TokenReadDTO: tokenToDTO(t)}
+		if err := hashes.set(dto.TokenReadDTO, t); err != nil {
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to evaluate permissions", err)
+		}
+		if t.ServiceAccountId != nil {
+			dto.ServiceAccountId = *t.ServiceAccountId
+		}
+		result = append(result, dto)
+	}
+
+	return api.jsonResponse(c, http.StatusOK, result)
+}
+
+// tokenHashes sets the hashes of the tokens in a response. Only callers that can write a service
+// account see the hashes of its tokens, callers that can only read it get the tokens without them.
+type tokenHashes struct {
+	api      *ServiceAccountsAPI
+	c        *models.ReqContext
+	writable map[int64]bool
+}
+
+func (api *ServiceAccountsAPI) newTokenHashes(c *models.ReqContext) *tokenHashes {
+	return &tokenHashes{api: api, c: c, writable: map[int64]bool{}}
+}
+
+// set fills the hash of token from t when the caller can write the service account of t
+func (h *tokenHashes) set(token *TokenReadDTO, t *models.ApiKey) error {
+	if t.ServiceAccountId == nil {
+		return nil
+	}
+	saID := *t.ServiceAccountId
+	writable, ok := h.writable[saID]
+	if !ok {
+		writable = true
+		if !h.api.accesscontrol.IsDisabled() {
+			scope := accesscontrol.Scope("serviceaccounts", "id", strconv.FormatInt(saID, 10))
+			var err error
+			writable, err = h.api.accesscontrol.Evaluate(h.c.Req.Context(), h.c.SignedInUser,
+				accesscontrol.EvalPermission(serviceaccounts.ActionWrite, scope))
+			if err != nil {
+				return err
+			}
+		}
+		h.writable[saID] = writable
+	}
+	if writable {
+		token.Hash = t.Key
+	}
+	return nil
+}
+
+// tokenExpiration returns when a token expires, nil if it never does
+func tokenExpiration(t *models.ApiKey) *time.Time {
+	if t.Expires == nil {
+		return nil
+	}
+	expiration := time.Unix(*t.Expires, 0)
+	return &expiration
+}
+
+func tokenToDTO(t *models.ApiKey) *TokenReadDTO {
+	var expiration *time.Time = nil
+	var secondsUntilExpiration float64 = 0
+
+	isExpired := hasExpired(t.Expires)
+	if t.Expires != nil {
+		v := time.Unix(*t.Expires, 0)
+		expiration = &v
+		if !isExpired && (*expiration).Before(time.Now().Add(sevenDaysAhead)) {
+			secondsUntilExpiration = time.Until(*expiration).Seconds()
+		}
+	}
+
+	return &TokenReadDTO{
+		Id:                     t.Id,
+		Name:                   t.Name,
+		Role:                   t.Role,
+		Created:                &t.Created,
+		Expiration:             expiration,
+		SecondsUntilExpiration: &secondsUntilExpiration,
+		HasExpired:             isExpired,
+		IsPaused:               t.IsPaused,
+		LastUsedAt:             t.LastUsedAt,
+		IsReadOnly:             t.IsReadOnly,
+		OrgId:                  t.OrgId,
 	}
 }
 
@@ -78,86 +368,158 @@ func (api *ServiceAccountsAPI) ListTokens(ctx *models.ReqContext) response.Respo
 func (api *ServiceAccountsAPI) CreateToken(c *models.ReqContext) response.Response {
 	saID, err := strconv.ParseInt(web.Params(c.Req)[":serviceAccountId"], 10, 64)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "Service Account ID is invalid", err)
+		return api.errorResponse(c, http.StatusBadRequest, "Service Account ID is invalid", err)
 	}
 
 	// confirm service account exists
-	if _, err := api.store.RetrieveServiceAccount(c.Req.Context(), c.OrgId, saID); err != nil {
+	serviceAccount, err := api.store.RetrieveServiceAccount(c.Req.Context(), c.OrgId, saID)
+	if err != nil {
 		switch {
 		case errors.Is(err, serviceaccounts.ErrServiceAccountNotFound):
-			return response.Error(http.StatusNotFound, "Failed to retrieve service account", err)
+			return api.errorResponse(c, http.StatusNotFound, "Failed to retrieve service account", err)
 		default:
-			return response.Error(http.StatusInternalServerError, "Failed to retrieve service account", err)
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to retrieve service account", err)
 		}
 	}
 
-	cmd := models.AddApiKeyCommand{}
-	if err := web.Bind(c.Req, &cmd); err != nil {
-		return response.Error(http.StatusBadRequest, "Bad request data", err)
+	// tokens of admin service accounts grant full control over the organization,
+	// so they have to be asked for explicitly
+	if serviceAccount != nil && serviceAccount.Role == string(models.ROLE_ADMIN) && !c.QueryBool("confirm") {
+		return api.errorResponse(c, http.StatusBadRequest,
+			"Service account has the Admin role, set confirm=true to create a token for it", nil)
+	}
+
+	form := createTokenForm{}
+	if err := web.Bind(c.Req, &form); err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Bad request data", err)
 	}
+	cmd := form.AddApiKeyCommand
 
 	// Force affected service account to be the one referenced in the URL
 	cmd.OrgId = c.OrgId
 
-	if !cmd.Role.IsValid() {
-		return response.Error(http.StatusBadRequest, "Invalid role specified", nil)
-	}
-
-	if api.cfg.ApiKeyMaxSecondsToLive != -1 {
-		if cmd.SecondsToLive == 0 {
-			return response.Error(http.StatusBadRequest, "Number of seconds before expiration should be set", nil)
+	if form.Expiration != nil {
+		if cmd.SecondsToLive != 0 {
+			return api.errorResponse(c, http.StatusBadRequest, "Only one of secondsToLive and expiration can be set", nil)
 		}
-		if cmd.SecondsToLive > api.cfg.ApiKeyMaxSecondsToLive {
-			return response.Error(http.StatusBadRequest, "Number of seconds before expiration is greater than the global limit", nil)
+		secondsToLive := int64(math.Ceil(time.Until(*form.Expiration).Seconds()))
+		if secondsToLive <= 0 {
+			return api.errorResponse(c, http.StatusBadRequest, "Expiration should be in the future", nil)
 		}
+		cmd.SecondsToLive = secondsToLive
+	}
+
+	if resp := api.validateToken(c, &cmd); resp != nil {
+		return resp
+	}
+
+	if resp := api.checkTokenLimit(c, saID); resp != nil {
+		return resp
 	}
 
 	newKeyInfo, err := apikeygen.New(cmd.OrgId, cmd.Name)
 	if err != nil {
-		return response.Error(http.StatusInternalServerError, "Generating API key failed", err)
+		return api.errorResponse(c, http.StatusInternalServerError, "Generating API key failed", err)
 	}
 
 	cmd.Key = newKeyInfo.HashedKey
 
 	if err := api.store.AddServiceAccountToken(c.Req.Context(), saID, &cmd); err != nil {
 		if errors.Is(err, models.ErrInvalidApiKeyExpiration) {
-			return response.Error(http.StatusBadRequest, err.Error(), nil)
+			return api.errorResponse(c, http.StatusBadRequest, err.Error(), nil)
 		}
 		if errors.Is(err, models.ErrDuplicateApiKey) {
-			return response.Error(http.StatusConflict, err.Error(), nil)
+			return api.errorResponse(c, http.StatusConflict, err.Error(), nil)
 		}
-		return response.Error(http.StatusInternalServerError, "Failed to add API Key", err)
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to add API Key", err)
 	}
+	api.auditLog(c, auditCreateToken, saID, "tokenId", cmd.Result.Id)
 
-	result := &dtos.NewApiKeyResult{
-		ID:   cmd.Result.Id,
-		Name: cmd.Result.Name,
-		Key:  newKeyInfo.ClientSecret,
+	result := &NewTokenDTO{
+		NewApiKeyResult: dtos.NewApiKeyResult{
+			ID:   cmd.Result.Id,
+			Name: cmd.Result.Name,
+			Key:  newKeyInfo.ClientSecret,
+		},
+		Expiration: tokenExpiration(cmd.Result),
+	}
+
+	return api.jsonResponse(c, http.StatusOK, result).
+		SetHeader("Location", fmt.Sprintf("%s/api/serviceaccounts/%d/tokens/%d", api.cfg.AppSubURL, saID, result.ID))
+}
+
+// checkTokenLimit returns a forbidden response when the service account has as many tokens as it may have,
+// and nil when another one can be added. Expired tokens count until they're deleted.
+func (api *ServiceAccountsAPI) checkTokenLimit(c *models.ReqContext, saID int64) response.Response {
+	limit := api.cfg.ServiceAccountTokensPerAccountLimit
+	if limit < 0 {
+		return nil
+	}
+	counts, err := api.store.CountTokensByServiceAccounts(c.Req.Context(), c.OrgId, []int64{saID})
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to count service account tokens", err)
+	}
+	if counts[saID] >= limit {
+		return api.errorResponse(c, http.StatusForbidden, fmt.Sprintf(
+			"Token limit reached, a service account can have at most %d tokens. "+
+				"Delete unused or expired tokens to add new ones.", limit), nil)
+	}
+	return nil
+}
+
+// validateToken checks the role, IP allowlist and lifetime of a token to create, it returns
+// a bad request response when one of them is invalid and nil otherwise.
+func (api *ServiceAccountsAPI) validateToken(c *models.ReqContext, cmd *models.AddApiKeyCommand) response.Response {
+	if !cmd.Role.IsValid() {
+		return api.errorResponse(c, http.StatusBadRequest, "Invalid role specified", nil)
+	}
+
+	for _, cidr := range cmd.IpAllowlist {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return api.errorResponse(c, http.StatusBadRequest, fmt.Sprintf("Invalid CIDR %q in IP allowlist", cidr), err)
+		}
 	}
 
-	return response.JSON(http.StatusOK, result)
+	return api.validateTokenLifetime(c, cmd.SecondsToLive)
+}
+
+// validateTokenLifetime returns a bad request response when secondsToLive is negative or, when a
+// maximum lifetime is configured, unset or above it. It returns nil otherwise.
+func (api *ServiceAccountsAPI) validateTokenLifetime(c *models.ReqContext, secondsToLive int64) response.Response {
+	if secondsToLive < 0 {
+		return api.errorResponse(c, http.StatusBadRequest, "Number of seconds before expiration should be positive", nil)
+	}
+	if api.cfg.ApiKeyMaxSecondsToLive != -1 {
+		if secondsToLive == 0 {
+			return api.errorResponse(c, http.StatusBadRequest, "Number of seconds before expiration should be set", nil)
+		}
+		if secondsToLive > api.cfg.ApiKeyMaxSecondsToLive {
+			return api.errorResponse(c, http.StatusBadRequest, "Number of seconds before expiration is greater than the global limit", nil)
+		}
+	}
+	return nil
 }
 
 // DeleteToken deletes service account tokens
 func (api *ServiceAccountsAPI) DeleteToken(c *models.ReqContext) response.Response {
 	saID, err := strconv.ParseInt(web.Params(c.Req)[":serviceAccountId"], 10, 64)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "Service Account ID is invalid", err)
+		return api.errorResponse(c, http.StatusBadRequest, "Service Account ID is invalid", err)
 	}
 
 	// confirm service account exists
 	if _, err := api.store.RetrieveServiceAccount(c.Req.Context(), c.OrgId, saID); err != nil {
 		switch {
 		case errors.Is(err, serviceaccounts.ErrServiceAccountNotFound):
-			return response.Error(http.StatusNotFound, "Failed to retrieve service account", err)
+			return api.errorResponse(c, http.StatusNotFound, "Failed to retrieve service account", err)
 		default:
-			return response.Error(http.StatusInternalServerError, "Failed to retrieve service account", err)
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to retrieve service account", err)
 		}
 	}
 
 	tokenID, err := strconv.ParseInt(web.Params(c.Req)[":tokenId"], 10, 64)
 	if err != nil {
-		return response.Error(http.StatusBadRequest, "Token ID is invalid", err)
+		return api.errorResponse(c, http.StatusBadRequest, "Token ID is invalid", err)
 	}
 
 	if err = api.store.DeleteServiceAccountToken(c.Req.Context(), c.OrgId, saID, tokenID); err != nil {
@@ -168,8 +530,180 @@ func (api *ServiceAccountsAPI) DeleteToken(c *models.ReqContext) response.Respon
 			err = models.ErrApiKeyNotFound
 		}
 
-		return response.Error(status, failedToDeleteMsg, err)
+		return api.errorResponse(c, status, failedToDeleteMsg, err)
+	}
+	api.auditLog(c, auditDeleteToken, saID, "tokenId", tokenID)
+
+	return api.successResponse(c, "API key deleted")
+}
+
+// PATCH /api/serviceaccounts/:serviceAccountId/tokens/:tokenId
+//
+// UpdateToken pauses, resumes, renames a token or changes its expiration, keeping its ID. The secret
+// of a token is hashed with its name, so renaming it issues a new secret and the old one stops working.
+func (api *ServiceAccountsAPI) UpdateToken(c *models.ReqContext) response.Response {
+	saID, err := strconv.ParseInt(web.Params(c.Req)[":serviceAccountId"], 10, 64)
+	if err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Service Account ID is invalid", err)
 	}
 
-	return response.Success("API key deleted")
+	tokenID, err := strconv.ParseInt(web.Params(c.Req)[":tokenId"], 10, 64)
+	if err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Token ID is invalid", err)
+	}
+
+	type updateTokenForm struct {
+		Paused        *bool   `json:"paused"`
+		Name          *string `json:"name"`
+		SecondsToLive *int64  `json:"secondsToLive"`
+	}
+	form := updateTokenForm{}
+	if err := web.Bind(c.Req, &form); err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Bad request data", err)
+	}
+	if form.Paused == nil && form.Name == nil && form.SecondsToLive == nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Nothing to update", nil)
+	}
+
+	var updated *UpdatedTokenDTO
+	if form.Name != nil || form.SecondsToLive != nil {
+		var resp response.Response
+		if updated, resp = api.updateTokenDetails(c, saID, tokenID, form.Name, form.SecondsToLive); resp != nil {
+			return resp
+		}
+	}
+
+	if form.Paused != nil {
+		if err := api.store.SetServiceAccountTokenPaused(c.Req.Context(), c.OrgId, saID, tokenID, *form.Paused); err != nil {
+			if errors.Is(err, models.ErrApiKeyNotFound) {
+				return api.errorResponse(c, http.StatusNotFound, "Failed to update API key", err)
+			}
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to update API key", err)
+		}
+	}
+
+	switch {
+	case updated != nil:
+		if form.Paused != nil {
+			updated.IsPaused = *form.Paused
+		}
+		return api.jsonResponse(c, http.StatusOK, updated)
+	case *form.Paused:
+		return api.successResponse(c, "API key paused")
+	default:
+		return api.successResponse(c, "API key resumed")
+	}
+}
+
+// UpdatedTokenDTO is a token changed by an update. Key is the new secret of a renamed token,
+// it is only returned once.
+type UpdatedTokenDTO struct {
+	*TokenReadDTO
+	Key string `json:"key,omitempty"`
+}
+
+// updateTokenDetails renames a token or changes its expiration and returns the updated token, or
+// an error response when the change is invalid or fails.
+func (api *ServiceAccountsAPI) updateTokenDetails(c *models.ReqContext, saID, tokenID int64, name *string, secondsToLive *int64) (*UpdatedTokenDTO, response.Response) {
+	if name != nil && strings.TrimSpace(*name) == "" {
+		return nil, api.errorResponse(c, http.StatusBadRequest, "Token name should not be empty", nil)
+	}
+	if secondsToLive != nil {
+		if resp := api.validateTokenLifetime(c, *secondsToLive); resp != nil {
+			return nil, resp
+		}
+	}
+
+	saTokens, err := api.store.ListTokens(c.Req.Context(), c.OrgId, saID)
+	if err != nil {
+		return nil, api.errorResponse(c, http.StatusInternalServerError, "Failed to update API key", err)
+	}
+	var token *models.ApiKey
+	for _, t := range saTokens {
+		if t.Id == tokenID {
+			token = t
+		} else if name != nil && t.Name == *name {
+			return nil, api.errorResponse(c, http.StatusConflict, fmt.Sprintf("The service account already has a token named %q", *name), nil)
+		}
+	}
+	if token == nil {
+		return nil, api.errorResponse(c, http.StatusNotFound, "Failed to update API key", models.ErrApiKeyNotFound)
+	}
+
+	cmd := serviceaccounts.UpdateTokenCommand{SecondsToLive: secondsToLive}
+	var secret string
+	if name != nil && *name != token.Name {
+		newKeyInfo, err := apikeygen.New(c.OrgId, *name)
+		if err != nil {
+			return nil, api.errorResponse(c, http.StatusInternalServerError, "Generating API key failed", err)
+		}
+		cmd.Name = name
+		cmd.HashedKey = newKeyInfo.HashedKey
+		secret = newKeyInfo.ClientSecret
+	}
+
+	if err := api.store.UpdateServiceAccountToken(c.Req.Context(), c.OrgId, saID, tokenID, &cmd); err != nil {
+		switch {
+		case errors.Is(err, models.ErrApiKeyNotFound):
+			return nil, api.errorResponse(c, http.StatusNotFound, "Failed to update API key", err)
+		case errors.Is(err, models.ErrDuplicateApiKey):
+			return nil, api.errorResponse(c, http.StatusConflict, err.Error(), nil)
+		case errors.Is(err, models.ErrInvalidApiKeyExpiration):
+			return nil, api.errorResponse(c, http.StatusBadRequest, "Number of seconds before expiration should be positive", nil)
+		default:
+			return nil, api.errorResponse(c, http.StatusInternalServerError, "Failed to update API key", err)
+		}
+	}
+
+	return &UpdatedTokenDTO{TokenReadDTO: tokenToDTO(cmd.Result), Key: secret}, nil
+}
+
+// POST /api/serviceaccounts/:serviceAccountId/tokens/:tokenId/rotate
+//
+// RotateToken generates a new secret for a token and returns it, the old secret stops
+// working immediately. The token keeps its ID, name, expiration and creation date.
+func (api *ServiceAccountsAPI) RotateToken(c *models.ReqContext) response.Response {
+	saID, err := strconv.ParseInt(web.Params(c.Req)[":serviceAccountId"], 10, 64)
+	if err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Service Account ID is invalid", err)
+	}
+
+	tokenID, err := strconv.ParseInt(web.Params(c.Req)[":tokenId"], 10, 64)
+	if err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Token ID is invalid", err)
+	}
+
+	// the secret is hashed with the token name, so look the token up within the service account first
+	saTokens, err := api.store.ListTokens(c.Req.Context(), c.OrgId, saID)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to rotate API key", err)
+	}
+	var token *models.ApiKey
+	for _, t := range saTokens {
+		if t.Id == tokenID {
+			token = t
+			break
+		}
+	}
+	if token == nil {
+		return api.errorResponse(c, http.StatusNotFound, "Failed to rotate API key", models.ErrApiKeyNotFound)
+	}
+
+	newKeyInfo, err := apikeygen.New(c.OrgId, token.Name)
+	if err != nil {
+		return api.errorResponse(c, http.StatusInternalServerError, "Generating API key failed", err)
+	}
+
+	if err := api.store.RotateServiceAccountToken(c.Req.Context(), c.OrgId, saID, tokenID, newKeyInfo.HashedKey); err != nil {
+		if errors.Is(err, models.ErrApiKeyNotFound) {
+			return api.errorResponse(c, http.StatusNotFound, "Failed to rotate API key", err)
+		}
+		return api.errorResponse(c, http.StatusInternalServerError, "Failed to rotate API key", err)
+	}
+
+	return api.jsonResponse(c, http.StatusOK, &dtos.NewApiKeyResult{
+		ID:   token.Id,
+		Name: token.Name,
+		Key:  newKeyInfo.ClientSecret,
+	})
 }
@@ -7,10 +7,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/api/routing"
 	"github.com/grafana/grafana/pkg/components/apikeygen"
 	"github.com/grafana/grafana/pkg/models"
@@ -20,6 +22,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/serviceaccounts/database"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts/tests"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/util"
 	"github.com/grafana/grafana/pkg/web"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -109,6 +112,18 @@ func TestServiceAccountsAPI_CreateToken(t *testing.T) {
 			body:         map[string]interface{}{"name": "Test4", "role": "Viewer"},
 			expectedCode: http.StatusForbidden,
 		},
+		{
+			desc: "should be bad request to create serviceaccount token with an invalid IP allowlist",
+			acmock: tests.SetupMockAccesscontrol(
+				t,
+				func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+					return []*accesscontrol.Permission{{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll}}, nil
+				},
+				false,
+			),
+			body:         map[string]interface{}{"name": "Test5", "role": "Viewer", "ipAllowlist": []string{"10.0.0.0/33"}},
+			expectedCode: http.StatusBadRequest,
+		},
 	}
 
 	var requestResponse = func(server *web.Mux, httpMethod, requestpath string, requestBody io.Reader) *httptest.ResponseRecorder {
@@ -142,6 +157,7 @@ func TestServiceAccountsAPI_CreateToken(t *testing.T) {
 
 			if actualCode == http.StatusOK {
 				assert.Equal(t, tc.body["name"], actualBody["name"])
+				assert.Equal(t, fmt.Sprintf(serviceaccountIDTokensDetailPath, sa.Id, actualBody["id"]), actual.Header().Get("Location"))
 
 				query := models.GetApiKeyByNameQuery{KeyName: tc.body["name"].(string), OrgId: sa.OrgId}
 				err = store.GetApiKeyByName(context.Background(), &query)
@@ -154,6 +170,70 @@ func TestServiceAccountsAPI_CreateToken(t *testing.T) {
 	}
 }
 
+func TestServiceAccountsAPI_CreateTokenAdminConfirmation(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	admin := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-admin", IsServiceAccount: true, Role: string(models.ROLE_ADMIN)})
+	viewer := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-viewer", IsServiceAccount: true})
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+
+	testCases := []struct {
+		desc         string
+		saID         int64
+		query        string
+		tokenName    string
+		expectedCode int
+	}{
+		{
+			desc:         "should require a confirmation for an admin service account",
+			saID:         admin.Id,
+			tokenName:    "admin-unconfirmed",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			desc:         "should create a token for a confirmed admin service account",
+			saID:         admin.Id,
+			query:        "?confirm=true",
+			tokenName:    "admin-confirmed",
+			expectedCode: http.StatusOK,
+		},
+		{
+			desc:         "should not require a confirmation for other service accounts",
+			saID:         viewer.Id,
+			tokenName:    "viewer",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+			body := fmt.Sprintf(`{"name": %q, "role": "Viewer"}`, tc.tokenName)
+			req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(serviceaccountIDTokensPath, tc.saID)+tc.query, strings.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Add("Content-Type", "application/json")
+			actual := httptest.NewRecorder()
+			server.ServeHTTP(actual, req)
+			require.Equal(t, tc.expectedCode, actual.Code, actual.Body.String())
+
+			query := models.GetApiKeyByNameQuery{KeyName: tc.tokenName, OrgId: admin.OrgId}
+			err = store.GetApiKeyByName(context.Background(), &query)
+			if tc.expectedCode == http.StatusOK {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, models.ErrInvalidApiKey)
+			}
+		})
+	}
+}
+
 func TestServiceAccountsAPI_DeleteToken(t *testing.T) {
 	store := sqlstore.InitTestDB(t)
 	svcMock := &tests.ServiceAccountMock{}
@@ -359,3 +439,907 @@ func TestServiceAccountsAPI_ListTokens(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenReadDTO_HasNoSecret(t *testing.T) {
+	t.Run("should have no field that can carry a secret", func(t *testing.T) {
+		dtoType := reflect.TypeOf(TokenReadDTO{})
+		for i := 0; i < dtoType.NumField(); i++ {
+			field := dtoType.Field(i)
+			name := strings.ToLower(field.Name + " " + field.Tag.Get("json"))
+			assert.NotContains(t, name, "key", field.Name)
+			assert.NotContains(t, name, "secret", field.Name)
+		}
+	})
+
+	t.Run("should never serialize the secret of a token", func(t *testing.T) {
+		newKey, err := apikeygen.New(1, "Test1")
+		require.NoError(t, err)
+		token := &models.ApiKey{Id: 1, OrgId: 1, Name: "Test1", Role: models.ROLE_VIEWER, Key: newKey.HashedKey}
+
+		serialized, err := json.Marshal(tokenToDTO(token))
+		require.NoError(t, err)
+		assert.NotContains(t, string(serialized), newKey.ClientSecret)
+		fields := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(serialized, &fields))
+		assert.NotContains(t, fields, "key")
+		assert.NotContains(t, fields, "secret")
+
+		serialized, err = json.Marshal(token)
+		require.NoError(t, err)
+		assert.NotContains(t, string(serialized), newKey.HashedKey)
+		assert.NotContains(t, string(serialized), newKey.ClientSecret)
+	})
+}
+
+func TestServiceAccountsAPI_ListTokensReturnsHash(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+	token := createTokenforSA(t, saStore, "Test1", sa.OrgId, sa.Id, 0)
+
+	listTokens := func(t *testing.T, permissions []*accesscontrol.Permission) []map[string]interface{} {
+		acmock := tests.SetupMockAccesscontrol(
+			t,
+			func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+				return permissions, nil
+			},
+			false,
+		)
+
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(serviceaccountIDTokensPath, sa.Id), nil)
+		require.NoError(t, err)
+		actual := httptest.NewRecorder()
+		server.ServeHTTP(actual, req)
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		actualBody := []map[string]interface{}{}
+		err = json.Unmarshal(actual.Body.Bytes(), &actualBody)
+		require.NoError(t, err)
+		require.Len(t, actualBody, 1)
+		return actualBody
+	}
+
+	t.Run("should return the stored hash to callers that can write the service account", func(t *testing.T) {
+		tokens := listTokens(t, []*accesscontrol.Permission{
+			{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll},
+			{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll},
+		})
+		assert.NotEmpty(t, tokens[0]["hash"])
+		assert.Equal(t, token.Key, tokens[0]["hash"])
+	})
+
+	t.Run("should leave the hash out for callers that can only read the service account", func(t *testing.T) {
+		tokens := listTokens(t, []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}})
+		assert.NotContains(t, tokens[0], "hash")
+	})
+}
+
+func TestServiceAccountsAPI_ListTokensReturnsLastUsedAt(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+	used := createTokenforSA(t, saStore, "used", sa.OrgId, sa.Id, 0)
+	createTokenforSA(t, saStore, "unused", sa.OrgId, sa.Id, 0)
+
+	lastUsedAt := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	err := store.UpdateApiKeyLastUsed(context.Background(), &models.UpdateApiKeyLastUsedCommand{Id: used.Id, LastUsedAt: lastUsedAt})
+	require.NoError(t, err)
+
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(serviceaccountIDTokensPath, sa.Id), nil)
+	require.NoError(t, err)
+	actual := httptest.NewRecorder()
+	server.ServeHTTP(actual, req)
+	require.Equal(t, http.StatusOK, actual.Code)
+
+	actualBody := []map[string]interface{}{}
+	err = json.Unmarshal(actual.Body.Bytes(), &actualBody)
+	require.NoError(t, err)
+	require.Len(t, actualBody, 2)
+
+	tokens := map[string]map[string]interface{}{}
+	for _, token := range actualBody {
+		tokens[token["name"].(string)] = token
+	}
+	require.Contains(t, tokens["unused"], "lastUsedAt")
+	assert.Nil(t, tokens["unused"]["lastUsedAt"])
+
+	actualLastUsedAt, err := time.Parse(time.RFC3339, tokens["used"]["lastUsedAt"].(string))
+	require.NoError(t, err)
+	assert.True(t, lastUsedAt.Equal(actualLastUsedAt))
+}
+
+func TestServiceAccountsAPI_ListTokensOfDisabledAccount(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+	createTokenforSA(t, saStore, "Test1", sa.OrgId, sa.Id, 0)
+	createTokenforSA(t, saStore, "Test2", sa.OrgId, sa.Id, 3600)
+
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	listTokens := func(t *testing.T, saID int64) ([]TokenReadDTO, int) {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(serviceaccountIDTokensPath, saID), nil)
+		require.NoError(t, err)
+		actual := httptest.NewRecorder()
+		server.ServeHTTP(actual, req)
+		tokens := []TokenReadDTO{}
+		_ = json.Unmarshal(actual.Body.Bytes(), &tokens)
+		return tokens, actual.Code
+	}
+
+	t.Run("should not flag the tokens of an enabled account", func(t *testing.T) {
+		tokens, code := listTokens(t, sa.Id)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, tokens, 2)
+		for _, token := range tokens {
+			assert.False(t, token.IsAccountDisabled, token.Name)
+		}
+	})
+
+	t.Run("should flag the tokens of a disabled account", func(t *testing.T) {
+		require.NoError(t, saStore.SetServiceAccountDisabled(context.Background(), sa.OrgId, sa.Id, true))
+
+		tokens, code := listTokens(t, sa.Id)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, tokens, 2)
+		for _, token := range tokens {
+			assert.True(t, token.IsAccountDisabled, token.Name)
+			assert.False(t, token.HasExpired, token.Name)
+		}
+	})
+
+	t.Run("should return 404 for a missing service account", func(t *testing.T) {
+		_, code := listTokens(t, sa.Id+100)
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
+
+func TestServiceAccountsAPI_GetToken(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+	other := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "other", IsServiceAccount: true})
+	token := createTokenforSA(t, saStore, "Test1", sa.OrgId, sa.Id, 3600)
+	otherToken := createTokenforSA(t, saStore, "Test2", other.OrgId, other.Id, 0)
+
+	getToken := func(t *testing.T, acmock *accesscontrolmock.Mock, saID, tokenID int64) *httptest.ResponseRecorder {
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(serviceaccountIDTokensPath+"/%d", saID, tokenID), nil)
+		require.NoError(t, err)
+		actual := httptest.NewRecorder()
+		server.ServeHTTP(actual, req)
+		return actual
+	}
+	readAll := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+
+	t.Run("should return the token without its secret", func(t *testing.T) {
+		actual := getToken(t, readAll, sa.Id, token.Id)
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		actualBody := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &actualBody))
+		assert.Equal(t, float64(token.Id), actualBody["id"])
+		assert.Equal(t, "Test1", actualBody["name"])
+		assert.NotNil(t, actualBody["expiration"])
+		assert.Nil(t, actualBody["lastUsedAt"])
+		assert.NotContains(t, actualBody, "key")
+	})
+
+	t.Run("should return the org the token can be used in", func(t *testing.T) {
+		actual := getToken(t, readAll, sa.Id, token.Id)
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		dto := TokenReadDTO{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &dto))
+		assert.Equal(t, sa.OrgId, dto.OrgId)
+		assert.False(t, dto.Global)
+	})
+
+	t.Run("should return 404 for a token of another service account", func(t *testing.T) {
+		actual := getToken(t, readAll, sa.Id, otherToken.Id)
+		assert.Equal(t, http.StatusNotFound, actual.Code)
+	})
+
+	t.Run("should return 404 for a missing token", func(t *testing.T) {
+		actual := getToken(t, readAll, sa.Id, otherToken.Id+100)
+		assert.Equal(t, http.StatusNotFound, actual.Code)
+	})
+
+	t.Run("should be forbidden without access to the service account", func(t *testing.T) {
+		acmock := tests.SetupMockAccesscontrol(
+			t,
+			func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+				return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: fmt.Sprintf("serviceaccounts:id:%d", other.Id)}}, nil
+			},
+			false,
+		)
+		actual := getToken(t, acmock, sa.Id, token.Id)
+		assert.Equal(t, http.StatusForbidden, actual.Code)
+	})
+}
+
+func TestServiceAccountsAPI_ListTokensForServiceAccounts(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa1 := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-1", IsServiceAccount: true})
+	sa2 := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-2", IsServiceAccount: true})
+	sa3 := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-3", IsServiceAccount: true})
+	createTokenforSA(t, saStore, "sa1-a", sa1.OrgId, sa1.Id, 0)
+	createTokenforSA(t, saStore, "sa1-b", sa1.OrgId, sa1.Id, 0)
+	createTokenforSA(t, saStore, "sa2-a", sa2.OrgId, sa2.Id, 0)
+
+	var listTokens = func(server *web.Mux, ids ...int64) *httptest.ResponseRecorder {
+		body, err := json.Marshal(map[string]interface{}{"serviceAccountIds": ids})
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, "/api/serviceaccounts/tokens/list", strings.NewReader(string(body)))
+		require.NoError(t, err)
+		req.Header.Add("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should group tokens by service account", func(t *testing.T) {
+		acmock := tests.SetupMockAccesscontrol(
+			t,
+			func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+				return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+			},
+			false,
+		)
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+		actual := listTokens(server, sa1.Id, sa2.Id, sa3.Id)
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		groups := []ServiceAccountTokensDTO{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &groups))
+		require.Len(t, groups, 3)
+
+		assert.Equal(t, sa1.Id, groups[0].ServiceAccountId)
+		require.Len(t, groups[0].Tokens, 2)
+		assert.Equal(t, "sa1-a", groups[0].Tokens[0].Name)
+		assert.Equal(t, "sa1-b", groups[0].Tokens[1].Name)
+
+		assert.Equal(t, sa2.Id, groups[1].ServiceAccountId)
+		require.Len(t, groups[1].Tokens, 1)
+		assert.Equal(t, "sa2-a", groups[1].Tokens[0].Name)
+
+		assert.Equal(t, sa3.Id, groups[2].ServiceAccountId)
+		assert.Empty(t, groups[2].Tokens)
+	})
+
+	t.Run("should be forbidden without read permission on every service account", func(t *testing.T) {
+		acmock := tests.SetupMockAccesscontrol(
+			t,
+			func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+				return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: fmt.Sprintf("serviceaccounts:id:%d", sa1.Id)}}, nil
+			},
+			false,
+		)
+		server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+		actual := listTokens(server, sa1.Id, sa2.Id)
+		require.Equal(t, http.StatusForbidden, actual.Code)
+	})
+}
+
+func TestServiceAccountsAPI_UpdateToken(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+	token := createTokenforSA(t, saStore, "Test1", sa.OrgId, sa.Id, 0)
+
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	var patchToken = func(tokenID int64, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf(serviceaccountIDTokensDetailPath, sa.Id, tokenID), strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Add("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	var isPaused = func() bool {
+		keys, err := saStore.ListTokens(context.Background(), sa.OrgId, sa.Id)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		return keys[0].IsPaused
+	}
+
+	t.Run("should pause and resume a token", func(t *testing.T) {
+		actual := patchToken(token.Id, `{"paused": true}`)
+		require.Equal(t, http.StatusOK, actual.Code)
+		assert.True(t, isPaused())
+
+		actual = patchToken(token.Id, `{"paused": false}`)
+		require.Equal(t, http.StatusOK, actual.Code)
+		assert.False(t, isPaused())
+	})
+
+	t.Run("should be bad request without a paused flag", func(t *testing.T) {
+		actual := patchToken(token.Id, `{}`)
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+	})
+
+	t.Run("should be not found for an unknown token", func(t *testing.T) {
+		actual := patchToken(token.Id+100, `{"paused": true}`)
+		assert.Equal(t, http.StatusNotFound, actual.Code)
+	})
+}
+
+func TestServiceAccountsAPI_UpdateTokenDetails(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+	token := createTokenforSA(t, saStore, "Test1", sa.OrgId, sa.Id, 3600)
+	createTokenforSA(t, saStore, "Test2", sa.OrgId, sa.Id, 0)
+
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	server, saAPI := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	var patchToken = func(tokenID int64, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPatch, fmt.Sprintf(serviceaccountIDTokensDetailPath, sa.Id, tokenID), strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Add("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+	var storedToken = func(t *testing.T) *models.ApiKey {
+		keys, err := saStore.ListTokens(context.Background(), sa.OrgId, sa.Id)
+		require.NoError(t, err)
+		for _, key := range keys {
+			if key.Id == token.Id {
+				return key
+			}
+		}
+		require.FailNow(t, "token not found")
+		return nil
+	}
+
+	t.Run("should rename a token and return a new secret", func(t *testing.T) {
+		expires := storedToken(t).Expires
+
+		actual := patchToken(token.Id, `{"name": "Renamed"}`)
+		require.Equal(t, http.StatusOK, actual.Code, actual.Body.String())
+		updated := UpdatedTokenDTO{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &updated))
+		assert.Equal(t, token.Id, updated.Id)
+		assert.Equal(t, "Renamed", updated.Name)
+		require.NotEmpty(t, updated.Key)
+
+		stored := storedToken(t)
+		assert.Equal(t, "Renamed", stored.Name)
+		assert.Equal(t, expires, stored.Expires)
+		decoded, err := apikeygen.Decode(updated.Key)
+		require.NoError(t, err)
+		valid, err := apikeygen.IsValid(decoded, stored.Key)
+		require.NoError(t, err)
+		assert.True(t, valid)
+	})
+
+	t.Run("should change the expiration without a new secret", func(t *testing.T) {
+		hash := storedToken(t).Key
+
+		actual := patchToken(token.Id, `{"secondsToLive": 7200}`)
+		require.Equal(t, http.StatusOK, actual.Code, actual.Body.String())
+		updated := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &updated))
+		assert.Equal(t, "Renamed", updated["name"])
+		assert.NotContains(t, updated, "key")
+		assert.InDelta(t, 7200, updated["secondsUntilExpiration"], 5)
+
+		stored := storedToken(t)
+		assert.Equal(t, hash, stored.Key)
+		require.NotNil(t, stored.Expires)
+		assert.InDelta(t, time.Now().Add(2*time.Hour).Unix(), *stored.Expires, 5)
+	})
+
+	t.Run("should reject a name used by another token of the account", func(t *testing.T) {
+		actual := patchToken(token.Id, `{"name": "Test2"}`)
+		assert.Equal(t, http.StatusConflict, actual.Code)
+		assert.Equal(t, "Renamed", storedToken(t).Name)
+	})
+
+	t.Run("should reject an empty name", func(t *testing.T) {
+		actual := patchToken(token.Id, `{"name": " "}`)
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+	})
+
+	t.Run("should reject an expiration beyond the maximum lifetime", func(t *testing.T) {
+		saAPI.cfg.ApiKeyMaxSecondsToLive = 3600
+		t.Cleanup(func() { saAPI.cfg.ApiKeyMaxSecondsToLive = -1 })
+		expires := storedToken(t).Expires
+
+		actual := patchToken(token.Id, `{"secondsToLive": 3601}`)
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+		actual = patchToken(token.Id, `{"secondsToLive": 0}`)
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+		assert.Equal(t, expires, storedToken(t).Expires)
+
+		actual = patchToken(token.Id, `{"secondsToLive": 3600}`)
+		assert.Equal(t, http.StatusOK, actual.Code)
+	})
+
+	t.Run("should be not found for an unknown token", func(t *testing.T) {
+		actual := patchToken(token.Id+100, `{"name": "Unknown"}`)
+		assert.Equal(t, http.StatusNotFound, actual.Code)
+	})
+}
+
+func TestServiceAccountsAPI_RotateToken(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+	other := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "other", IsServiceAccount: true})
+	token := createTokenforSA(t, saStore, "Test1", sa.OrgId, sa.Id, 3600)
+
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	var rotateToken = func(saID, tokenID int64) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(serviceaccountIDTokensDetailPath+"/rotate", saID, tokenID), nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should replace the secret of the token", func(t *testing.T) {
+		actual := rotateToken(sa.Id, token.Id)
+		require.Equal(t, http.StatusOK, actual.Code)
+
+		result := dtos.NewApiKeyResult{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &result))
+		assert.Equal(t, token.Id, result.ID)
+		assert.Equal(t, token.Name, result.Name)
+
+		decoded, err := apikeygen.Decode(result.Key)
+		require.NoError(t, err)
+		hashed, err := util.EncodePassword(decoded.Key, decoded.Name)
+		require.NoError(t, err)
+
+		keys, err := saStore.ListTokens(context.Background(), sa.OrgId, sa.Id)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, token.Id, keys[0].Id)
+		assert.Equal(t, hashed, keys[0].Key)
+		assert.NotEqual(t, token.Key, keys[0].Key)
+		assert.Equal(t, token.Expires, keys[0].Expires)
+	})
+
+	t.Run("should be not found for a token of another service account", func(t *testing.T) {
+		actual := rotateToken(other.Id, token.Id)
+		assert.Equal(t, http.StatusNotFound, actual.Code)
+	})
+
+	t.Run("should be not found for an unknown token", func(t *testing.T) {
+		actual := rotateToken(sa.Id, token.Id+100)
+		assert.Equal(t, http.StatusNotFound, actual.Code)
+	})
+}
+
+func TestServiceAccountsAPI_ListExpiringTokens(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, store)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	build := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-build", Name: "build", IsServiceAccount: true})
+	deploy := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa-deploy", Name: "deploy", IsServiceAccount: true})
+
+	createTokenforSA(t, saStore, "build-3d", build.OrgId, build.Id, 3*24*3600)
+	createTokenforSA(t, saStore, "build-never", build.OrgId, build.Id, 0)
+	createTokenforSA(t, saStore, "deploy-1h", deploy.OrgId, deploy.Id, 3600)
+	createTokenforSA(t, saStore, "deploy-1d", deploy.OrgId, deploy.Id, 24*3600)
+	createTokenforSA(t, saStore, "build-1m", build.OrgId, build.Id, 60)
+
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	var listExpiring = func(query string) []ExpiringTokenDTO {
+		req, err := http.NewRequest(http.MethodGet, "/api/serviceaccounts/tokens/expiringSoon"+query, nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+		result := []ExpiringTokenDTO{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+		return result
+	}
+
+	t.Run("should return the soonest expiring tokens across accounts", func(t *testing.T) {
+		result := listExpiring("?limit=3")
+		require.Len(t, result, 3)
+
+		assert.Equal(t, "build-1m", result[0].Name)
+		assert.Equal(t, build.Id, result[0].ServiceAccountId)
+		assert.Equal(t, "build", result[0].ServiceAccountName)
+		assert.Equal(t, "sa-build", result[0].ServiceAccountLogin)
+
+		assert.Equal(t, "deploy-1h", result[1].Name)
+		assert.Equal(t, deploy.Id, result[1].ServiceAccountId)
+		assert.Equal(t, "deploy-1d", result[2].Name)
+	})
+
+	t.Run("should leave out tokens that never expire", func(t *testing.T) {
+		result := listExpiring("?limit=10")
+		require.Len(t, result, 4)
+		assert.Equal(t, "build-3d", result[3].Name)
+	})
+}
+
+func TestServiceAccountsAPI_ListDormantTokens(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+
+	stale := createTokenforSA(t, saStore, "stale", sa.OrgId, sa.Id, 0)
+	fresh := createTokenforSA(t, saStore, "fresh", sa.OrgId, sa.Id, 0)
+	createTokenforSA(t, saStore, "new", sa.OrgId, sa.Id, 0)
+	for id, lastUsedAt := range map[int64]time.Time{stale.Id: time.Now().Add(-48 * time.Hour), fresh.Id: time.Now()} {
+		err := store.UpdateApiKeyLastUsed(context.Background(), &models.UpdateApiKeyLastUsedCommand{Id: id, LastUsedAt: lastUsedAt})
+		require.NoError(t, err)
+	}
+
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	var listDormant = func(query string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/api/serviceaccounts/tokens/dormant"+query, nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("should list the tokens unused within the window without revoking them", func(t *testing.T) {
+		actual := listDormant("?window=24h")
+		require.Equal(t, http.StatusOK, actual.Code, actual.Body.String())
+
+		result := []DormantTokenDTO{}
+		require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &result))
+		require.Len(t, result, 1)
+		assert.Equal(t, "stale", result[0].Name)
+		assert.Equal(t, sa.Id, result[0].ServiceAccountId)
+
+		keys, err := saStore.ListTokens(context.Background(), sa.OrgId, sa.Id)
+		require.NoError(t, err)
+		assert.Len(t, keys, 3)
+	})
+
+	t.Run("should require a window when none is configured", func(t *testing.T) {
+		actual := listDormant("")
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+	})
+
+	t.Run("should reject an invalid window", func(t *testing.T) {
+		actual := listDormant("?window=month")
+		assert.Equal(t, http.StatusBadRequest, actual.Code)
+	})
+}
+
+func TestServiceAccountsAPI_CreateTokenExpiration(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	expiration := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+
+	testCases := []struct {
+		desc               string
+		body               map[string]interface{}
+		maxSecondsToLive   int64
+		expectedCode       int
+		expectedExpiration *time.Time
+	}{
+		{
+			desc:               "should expire after secondsToLive",
+			body:               map[string]interface{}{"secondsToLive": 7200},
+			maxSecondsToLive:   -1,
+			expectedCode:       http.StatusOK,
+			expectedExpiration: &expiration,
+		},
+		{
+			desc:               "should expire at the expiration",
+			body:               map[string]interface{}{"expiration": expiration},
+			maxSecondsToLive:   -1,
+			expectedCode:       http.StatusOK,
+			expectedExpiration: &expiration,
+		},
+		{
+			desc:             "should never expire without a lifetime",
+			body:             map[string]interface{}{},
+			maxSecondsToLive: -1,
+			expectedCode:     http.StatusOK,
+		},
+		{
+			desc:             "should reject a negative secondsToLive",
+			body:             map[string]interface{}{"secondsToLive": -10},
+			maxSecondsToLive: -1,
+			expectedCode:     http.StatusBadRequest,
+		},
+		{
+			desc:             "should reject an expiration in the past",
+			body:             map[string]interface{}{"expiration": time.Now().Add(-time.Hour)},
+			maxSecondsToLive: -1,
+			expectedCode:     http.StatusBadRequest,
+		},
+		{
+			desc:             "should reject both secondsToLive and expiration",
+			body:             map[string]interface{}{"secondsToLive": 7200, "expiration": expiration},
+			maxSecondsToLive: -1,
+			expectedCode:     http.StatusBadRequest,
+		},
+		{
+			desc:             "should reject an expiration past the max lifetime",
+			body:             map[string]interface{}{"expiration": expiration},
+			maxSecondsToLive: 3600,
+			expectedCode:     http.StatusBadRequest,
+		},
+		{
+			desc:               "should accept an expiration within the max lifetime",
+			body:               map[string]interface{}{"expiration": expiration},
+			maxSecondsToLive:   3 * 3600,
+			expectedCode:       http.StatusOK,
+			expectedExpiration: &expiration,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server, saAPI := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, database.NewServiceAccountsStore(store))
+			saAPI.cfg.ApiKeyMaxSecondsToLive = tc.maxSecondsToLive
+
+			tc.body["name"] = fmt.Sprintf("token-%d", i)
+			tc.body["role"] = "Viewer"
+			body, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(serviceaccountIDTokensPath, sa.Id), strings.NewReader(string(body)))
+			require.NoError(t, err)
+			req.Header.Add("Content-Type", "application/json")
+			actual := httptest.NewRecorder()
+			server.ServeHTTP(actual, req)
+			require.Equal(t, tc.expectedCode, actual.Code, actual.Body.String())
+			if tc.expectedCode != http.StatusOK {
+				return
+			}
+
+			result := NewTokenDTO{}
+			require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &result))
+			if tc.expectedExpiration == nil {
+				assert.Nil(t, result.Expiration)
+				return
+			}
+			require.NotNil(t, result.Expiration)
+			assert.WithinDuration(t, *tc.expectedExpiration, *result.Expiration, 2*time.Second)
+
+			query := models.GetApiKeyByIdQuery{ApiKeyId: result.ID}
+			require.NoError(t, store.GetApiKeyById(context.Background(), &query))
+			require.NotNil(t, query.Result.Expires)
+			assert.Equal(t, result.Expiration.Unix(), *query.Result.Expires)
+		})
+	}
+}
+
+func TestServiceAccountsAPI_CreateReadOnlyToken(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true, Role: "Editor"})
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{
+				{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll},
+				{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll},
+			}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	body := strings.NewReader(`{"name": "read-only", "role": "Editor", "readOnly": true}`)
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(serviceaccountIDTokensPath, sa.Id), body)
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/json")
+	actual := httptest.NewRecorder()
+	server.ServeHTTP(actual, req)
+	require.Equal(t, http.StatusOK, actual.Code, actual.Body.String())
+
+	created := NewTokenDTO{}
+	require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &created))
+	query := models.GetApiKeyByIdQuery{ApiKeyId: created.ID}
+	require.NoError(t, store.GetApiKeyById(context.Background(), &query))
+	assert.True(t, query.Result.IsReadOnly)
+
+	req, err = http.NewRequest(http.MethodGet, fmt.Sprintf(serviceaccountIDTokensPath, sa.Id), nil)
+	require.NoError(t, err)
+	actual = httptest.NewRecorder()
+	server.ServeHTTP(actual, req)
+	require.Equal(t, http.StatusOK, actual.Code)
+
+	tokens := []TokenReadDTO{}
+	require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &tokens))
+	require.Len(t, tokens, 1)
+	assert.True(t, tokens[0].IsReadOnly)
+}
+
+func TestServiceAccountsAPI_CreateTokenReturnsID(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{
+				{Action: serviceaccounts.ActionRead, Scope: serviceaccounts.ScopeAll},
+				{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll},
+			}, nil
+		},
+		false,
+	)
+	server, _ := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+
+	body := strings.NewReader(`{"name": "automation", "role": "Viewer", "secondsToLive": 3600}`)
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(serviceaccountIDTokensPath, sa.Id), body)
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/json")
+	actual := httptest.NewRecorder()
+	server.ServeHTTP(actual, req)
+	require.Equal(t, http.StatusOK, actual.Code, actual.Body.String())
+
+	created := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &created))
+	assert.ElementsMatch(t, []string{"id", "name", "key", "expiration"}, mapKeys(created))
+	id, ok := created["id"].(float64)
+	require.True(t, ok)
+	assert.NotZero(t, id)
+	assert.Equal(t, "automation", created["name"])
+	assert.NotEmpty(t, created["key"])
+	assert.NotNil(t, created["expiration"])
+
+	req, err = http.NewRequest(http.MethodGet, fmt.Sprintf(serviceaccountIDTokensPath, sa.Id), nil)
+	require.NoError(t, err)
+	actual = httptest.NewRecorder()
+	server.ServeHTTP(actual, req)
+	require.Equal(t, http.StatusOK, actual.Code)
+
+	tokens := []TokenReadDTO{}
+	require.NoError(t, json.Unmarshal(actual.Body.Bytes(), &tokens))
+	require.Len(t, tokens, 1)
+	assert.Equal(t, int64(id), tokens[0].Id)
+	assert.Equal(t, "automation", tokens[0].Name)
+	// the secret is only returned on creation
+	assert.NotContains(t, actual.Body.String(), created["key"])
+}
+
+func mapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestServiceAccountsAPI_CreateTokenLimit(t *testing.T) {
+	store := sqlstore.InitTestDB(t)
+	svcmock := tests.ServiceAccountMock{}
+	saStore := database.NewServiceAccountsStore(store)
+	sa := tests.SetupUserServiceAccount(t, store, tests.TestUser{Login: "sa", IsServiceAccount: true})
+	acmock := tests.SetupMockAccesscontrol(
+		t,
+		func(c context.Context, siu *models.SignedInUser, _ accesscontrol.Options) ([]*accesscontrol.Permission, error) {
+			return []*accesscontrol.Permission{{Action: serviceaccounts.ActionWrite, Scope: serviceaccounts.ScopeAll}}, nil
+		},
+		false,
+	)
+	server, saAPI := setupTestServer(t, &svcmock, routing.NewRouteRegister(), acmock, store, saStore)
+	saAPI.cfg.ServiceAccountTokensPerAccountLimit = 2
+
+	createToken := func(name string) *httptest.ResponseRecorder {
+		body := strings.NewReader(fmt.Sprintf(`{"name": %q, "role": "Viewer"}`, name))
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(serviceaccountIDTokensPath, sa.Id), body)
+		require.NoError(t, err)
+		req.Header.Add("Content-Type", "application/json")
+		actual := httptest.NewRecorder()
+		server.ServeHTTP(actual, req)
+		return actual
+	}
+
+	require.Equal(t, http.StatusOK, createToken("first").Code)
+	second := createToken("second")
+	require.Equal(t, http.StatusOK, second.Code)
+
+	actual := createToken("third")
+	require.Equal(t, http.StatusForbidden, actual.Code)
+	assert.Contains(t, actual.Body.String(), "at most 2 tokens")
+
+	// expired tokens still count until they're deleted
+	created := NewTokenDTO{}
+	require.NoError(t, json.Unmarshal(second.Body.Bytes(), &created))
+	err := store.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Exec("UPDATE api_key SET expires = ? WHERE id = ?", time.Now().Add(-time.Hour).Unix(), created.ID)
+		return err
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, createToken("third").Code)
+
+	require.NoError(t, saStore.DeleteServiceAccountToken(context.Background(), sa.OrgId, sa.Id, created.ID))
+	require.Equal(t, http.StatusOK, createToken("third").Code)
+}
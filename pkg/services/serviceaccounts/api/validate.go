@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+	"github.com/grafana/grafana/pkg/web"
+)
+
+// validationBatch is the body of a batch validation request. Its forms are decoded and validated one
+// by one by the handler, so that an invalid form is reported in the results instead of failing the request.
+type validationBatch []json.RawMessage
+
+func (validationBatch) Validate() error {
+	return nil
+}
+
+// FormValidationDTO is the validation result of one form of a batch, in the order of the request
+type FormValidationDTO struct {
+	Index  int      `json:"index"`
+	Name   string   `json:"name"`
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors"`
+}
+
+// BatchValidationDTO is the validation result of a batch, it is valid when all its forms are
+type BatchValidationDTO struct {
+	Valid   bool                 `json:"valid"`
+	Results []*FormValidationDTO `json:"results"`
+}
+
+// POST /api/serviceaccounts/validate
+//
+// ValidateServiceAccounts checks a batch of service accounts before they are created, without creating
+// anything. Besides the checks of a single creation, it reports names that collide within the batch.
+func (api *ServiceAccountsAPI) ValidateServiceAccounts(c *models.ReqContext) response.Response {
+	forms := validationBatch{}
+	if err := web.Bind(c.Req, &forms); err != nil {
+		return api.errorResponse(c, http.StatusBadRequest, "Bad request data", err)
+	}
+	if len(forms) == 0 {
+		return api.errorResponse(c, http.StatusBadRequest, "At least one service account is required", nil)
+	}
+
+	results := make([]*FormValidationDTO, len(forms))
+	// forms by the login generated from their name, names that generate the same login collide
+	formsByLogin := map[string][]int{}
+	for i, raw := range forms {
+		result := &FormValidationDTO{Index: i, Errors: []string{}}
+		results[i] = result
+
+		// decoding fails for unknown roles
+		form := serviceaccounts.CreateServiceAccountForm{}
+		if err := json.Unmarshal(raw, &form); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		result.Name = form.Name
+
+		if err := form.Validate(); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		if err := serviceaccounts.CheckNameAllowed(api.cfg, form.Name); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+
+		available, err := api.store.IsServiceAccountNameAvailable(c.Req.Context(), form.Name)
+		if err != nil {
+			return api.errorResponse(c, http.StatusInternalServerError, "Failed to check service account name", err)
+		}
+		if !available {
+			result.Errors = append(result.Errors, "a service account with that name already exists")
+		}
+
+		login := strings.ReplaceAll(strings.ToLower(form.Name), " ", "-")
+		formsByLogin[login] = append(formsByLogin[login], i)
+	}
+
+	for _, indices := range formsByLogin {
+		if len(indices) < 2 {
+			continue
+		}
+		for _, i := range indices {
+			others := make([]string, 0, len(indices)-1)
+			for _, j := range indices {
+				if j != i {
+					others = append(others, fmt.Sprint(j))
+				}
+			}
+			results[i].Errors = append(results[i].Errors,
+				fmt.Sprintf("the name collides with the name of the service accounts at %s", strings.Join(others, ", ")))
+		}
+	}
+
+	batch := BatchValidationDTO{Valid: true, Results: results}
+	for _, result := range results {
+		result.Valid = len(result.Errors) == 0
+		batch.Valid = batch.Valid && result.Valid
+	}
+
+	return api.jsonResponse(c, http.StatusOK, batch)
+}
@@ -28,11 +28,18 @@ func NewServiceAccountsStore(store *sqlstore.SQLStore) *ServiceAccountsStoreImpl
 	}
 }
 
-func (s *ServiceAccountsStoreImpl) CreateServiceAccount(ctx context.Context, orgID int64, name string) (saDTO *serviceaccounts.ServiceAccountDTO, err error) {
-	generatedLogin := "sa-" + strings.ToLower(name)
-	generatedLogin = strings.ReplaceAll(generatedLogin, " ", "-")
+func (s *ServiceAccountsStoreImpl) CreateServiceAccount(ctx context.Context, orgID int64, saForm *serviceaccounts.CreateServiceAccountForm) (saDTO *serviceaccounts.ServiceAccountDTO, err error) {
+	name := saForm.Name
+	prefix := s.loginPrefix()
+	login := serviceAccountLogin(prefix, name)
+	if saForm.Login != "" {
+		if !strings.HasPrefix(saForm.Login, prefix) || saForm.Login == prefix {
+			return nil, &ErrSAInvalidLogin{prefix: prefix}
+		}
+		login = saForm.Login
+	}
 	cmd := models.CreateUserCommand{
-		Login:            generatedLogin,
+		Login:            login,
 		OrgId:            orgID,
 		Name:             name,
 		IsServiceAccount: true,
@@ -46,14 +53,106 @@ func (s *ServiceAccountsStoreImpl) CreateServiceAccount(ctx context.Context, org
 		return nil, fmt.Errorf("failed to create service account: %w", err)
 	}
 
+	role := ""
+	if saForm.Role != nil {
+		// set the role explicitly, CreateUser only applies it when orgs are auto assigned
+		err := s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+			_, err := sess.Where("org_id = ? AND user_id = ?", orgID, newuser.Id).Cols("role").Update(&models.OrgUser{Role: *saForm.Role})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to set service account role: %w", err)
+		}
+		role = string(*saForm.Role)
+	}
+
+	if saForm.ExpiresAt != nil {
+		err := s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+			_, err := sess.ID(newuser.Id).Cols("expires_at").Update(&models.User{ExpiresAt: saForm.ExpiresAt})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to set service account expiration: %w", err)
+		}
+	}
+
 	return &serviceaccounts.ServiceAccountDTO{
-		Id:     newuser.Id,
-		Name:   newuser.Name,
-		Login:  newuser.Login,
-		OrgId:  newuser.OrgId,
-		Tokens: 0,
+		Id:        newuser.Id,
+		Name:      newuser.Name,
+		Login:     newuser.Login,
+		OrgId:     newuser.OrgId,
+		ExpiresAt: saForm.ExpiresAt,
+		Role:      role,
+		Tokens:    0,
 	}, nil
 }
+
+// ProvisionServiceAccount creates a service account along with its tokens in a single transaction.
+// Nothing is created when any of the tokens fails, and the Result of every token is set otherwise.
+func (s *ServiceAccountsStoreImpl) ProvisionServiceAccount(ctx context.Context, orgID int64,
+	saForm *serviceaccounts.CreateServiceAccountForm, tokens []*models.AddApiKeyCommand) (*serviceaccounts.ServiceAccountDTO, error) {
+	var sa *serviceaccounts.ServiceAccountDTO
+	err := s.sqlStore.InTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		if sa, err = s.CreateServiceAccount(ctx, orgID, saForm); err != nil {
+			return err
+		}
+		for _, cmd := range tokens {
+			cmd.OrgId = orgID
+			if err := s.AddServiceAccountToken(ctx, sa.Id, cmd); err != nil {
+				return err
+			}
+		}
+		sa.Tokens = int64(len(tokens))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sa, nil
+}
+
+// defaultLoginPrefix is used when no service account login prefix is configured.
+const defaultLoginPrefix = "sa-"
+
+// loginPrefix returns the configured prefix of service account logins
+func (s *ServiceAccountsStoreImpl) loginPrefix() string {
+	if s.sqlStore.Cfg == nil || s.sqlStore.Cfg.ServiceAccountLoginPrefix == "" {
+		return defaultLoginPrefix
+	}
+	return s.sqlStore.Cfg.ServiceAccountLoginPrefix
+}
+
+// serviceAccountLogin generates the login of a service account from its name
+func serviceAccountLogin(prefix, name string) string {
+	login := prefix + strings.ToLower(name)
+	return strings.ReplaceAll(login, " ", "-")
+}
+
+// IsServiceAccountNameAvailable reports whether a service account can be created with name.
+// Logins are unique across all orgs, so a name taken in another org is not available either.
+func (s *ServiceAccountsStoreImpl) IsServiceAccountNameAvailable(ctx context.Context, name string) (bool, error) {
+	login := serviceAccountLogin(s.loginPrefix(), name)
+	var exists bool
+	err := s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		exists, err = sess.Where("email=? OR login=?", login, login).Get(&models.User{})
+		return err
+	})
+	return !exists, err
+}
+
+// GetServiceAccountIDsByName returns the IDs of the service accounts in an org with the given name
+func (s *ServiceAccountsStoreImpl) GetServiceAccountIDsByName(ctx context.Context, orgID int64, name string) ([]int64, error) {
+	ids := make([]int64, 0)
+	err := s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		return sess.Table("user").Cols("id").
+			Where("org_id = ? AND name = ? AND is_service_account = ?", orgID, name, s.sqlStore.Dialect.BooleanStr(true)).
+			Find(&ids)
+	})
+	return ids, err
+}
+
 func ServiceAccountDeletions() []string {
 	deletes := []string{
 		"DELETE FROM api_key WHERE service_account_id = ?",
@@ -89,8 +188,7 @@ func (s *ServiceAccountsStoreImpl) UpgradeServiceAccounts(ctx context.Context) e
 		s.log.Info("Launching background thread to upgrade API keys to service accounts", "numberKeys", len(basicKeys))
 		go func() {
 			for _, key := range basicKeys {
-				err := s.CreateServiceAccountFromApikey(ctx, key)
-				if err != nil {
+				if _, err := s.CreateServiceAccountFromApikey(ctx, key); err != nil {
 					s.log.Error("migating to service accounts failed with error", err)
 				}
 			}
@@ -99,31 +197,37 @@ func (s *ServiceAccountsStoreImpl) UpgradeServiceAccounts(ctx context.Context) e
 	return nil
 }
 
-func (s *ServiceAccountsStoreImpl) ConvertToServiceAccounts(ctx context.Context, keys []int64) error {
+// ConvertToServiceAccounts creates a service account for each of the given API keys.
+// It returns the ID of the new service account of every converted key, by key ID.
+func (s *ServiceAccountsStoreImpl) ConvertToServiceAccounts(ctx context.Context, keys []int64) (map[int64]int64, error) {
+	converted := make(map[int64]int64)
 	basicKeys := s.sqlStore.GetAllOrgsAPIKeys(ctx)
 	if len(basicKeys) == 0 {
-		return nil
+		return converted, nil
 	}
 	if len(basicKeys) != len(keys) {
-		return fmt.Errorf("one of the keys already has a serviceaccount")
+		return nil, fmt.Errorf("one of the keys already has a serviceaccount")
 	}
 	for _, key := range basicKeys {
 		if !contains(keys, key.Id) {
 			s.log.Error("convert service accounts stopped for keyId %d as it is not part of the query to convert or already has a service account", key.Id)
 			continue
 		}
-		err := s.CreateServiceAccountFromApikey(ctx, key)
+		serviceAccountID, err := s.CreateServiceAccountFromApikey(ctx, key)
 		if err != nil {
 			s.log.Error("converting to service accounts failed with error", err)
+			continue
 		}
+		converted[key.Id] = serviceAccountID
 	}
-	return nil
+	return converted, nil
 }
 
-func (s *ServiceAccountsStoreImpl) CreateServiceAccountFromApikey(ctx context.Context, key *models.ApiKey) error {
+// CreateServiceAccountFromApikey creates a service account for an API key and returns its ID
+func (s *ServiceAccountsStoreImpl) CreateServiceAccountFromApikey(ctx context.Context, key *models.ApiKey) (int64, error) {
 	prefix := "sa-autogen-"
 	cmd := models.CreateUserCommand{
-		Login:            fmt.Sprintf("%v-%v-%v", prefix, key.OrgId, key.Name),
+		Login:            fmt.Sprintf("%vautogen--%v-%v", s.loginPrefix(), key.OrgId, key.Name),
 		Name:             prefix + key.Name,
 		OrgId:            key.OrgId,
 		DefaultOrgRole:   string(key.Role),
@@ -132,11 +236,11 @@ func (s *ServiceAccountsStoreImpl) CreateServiceAccountFromApikey(ctx context.Co
 
 	newSA, errCreateSA := s.sqlStore.CreateUser(ctx, cmd)
 	if errCreateSA != nil {
-		return fmt.Errorf("failed to create service account: %w", errCreateSA)
+		return 0, fmt.Errorf("failed to create service account: %w", errCreateSA)
 	}
 
 	if errUpdateKey := s.assignApiKeyToServiceAccount(ctx, key.Id, newSA.Id); errUpdateKey != nil {
-		return fmt.Errorf(
+		return 0, fmt.Errorf(
 			"failed to attach new service account to API key for keyId: %d and newServiceAccountId: %d with error: %w",
 			key.Id, newSA.Id, errUpdateKey,
 		)
@@ -144,7 +248,7 @@ func (s *ServiceAccountsStoreImpl) CreateServiceAccountFromApikey(ctx context.Co
 
 	s.log.Debug("Updated basic api key", "keyId", key.Id, "newServiceAccountId", newSA.Id)
 
-	return nil
+	return newSA.Id, nil
 }
 
 //nolint:gosimple
@@ -164,6 +268,112 @@ func (s *ServiceAccountsStoreImpl) ListTokens(ctx context.Context, orgID int64,
 	return result, err
 }
 
+// ListTokensForServiceAccounts returns the tokens of several service accounts in one query
+func (s *ServiceAccountsStoreImpl) ListTokensForServiceAccounts(ctx context.Context, orgID int64, serviceAccountIDs []int64) ([]*models.ApiKey, error) {
+	result := make([]*models.ApiKey, 0)
+	if len(serviceAccountIDs) == 0 {
+		return result, nil
+	}
+
+	err := s.sqlStore.WithDbSession(ctx, func(dbSession *sqlstore.DBSession) error {
+		quotedUser := s.sqlStore.Dialect.Quote("user")
+		return dbSession.
+			Join("inner", quotedUser, quotedUser+".id = api_key.service_account_id").
+			Where(quotedUser+".org_id=?", orgID).
+			In(quotedUser+".id", serviceAccountIDs).
+			Asc("name").
+			Find(&result)
+	})
+	return result, err
+}
+
+// CountTokensByServiceAccounts returns how many tokens each of the service accounts has, accounts
+// without tokens are left out. Expired tokens are counted until they're deleted.
+func (s *ServiceAccountsStoreImpl) CountTokensByServiceAccounts(ctx context.Context, orgID int64, serviceAccountIDs []int64) (map[int64]int64, error) {
+	counts := make(map[int64]int64, len(serviceAccountIDs))
+	if len(serviceAccountIDs) == 0 {
+		return counts, nil
+	}
+
+	type tokenCount struct {
+		ServiceAccountId int64
+		Tokens           int64
+	}
+	rows := make([]*tokenCount, 0)
+	err := s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		return sess.Table("api_key").
+			Select("service_account_id, COUNT(*) AS tokens").
+			Where("org_id = ?", orgID).
+			In("service_account_id", serviceAccountIDs).
+			GroupBy("service_account_id").
+			Find(&rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		counts[r.ServiceAccountId] = r.Tokens
+	}
+	return counts, nil
+}
+
+// CountTokensByExpiration counts the tokens of each of the service accounts with a single grouped query,
+// split into those expired at now and those expiring after now but before soon. Accounts without tokens
+// are left out.
+func (s *ServiceAccountsStoreImpl) CountTokensByExpiration(ctx context.Context, orgID int64, serviceAccountIDs []int64,
+	now, soon time.Time) (map[int64]*serviceaccounts.TokenCounts, error) {
+	counts := make(map[int64]*serviceaccounts.TokenCounts, len(serviceAccountIDs))
+	if len(serviceAccountIDs) == 0 {
+		return counts, nil
+	}
+
+	type tokenCount struct {
+		ServiceAccountId   int64
+		Tokens             int64
+		ExpiredTokens      int64
+		ExpiringSoonTokens int64
+	}
+	rows := make([]*tokenCount, 0)
+	err := s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		return sess.Table("api_key").
+			Select("service_account_id, COUNT(*) AS tokens"+
+				fmt.Sprintf(", SUM(CASE WHEN expires IS NOT NULL AND expires <= %d THEN 1 ELSE 0 END) AS expired_tokens", now.Unix())+
+				fmt.Sprintf(", SUM(CASE WHEN expires > %d AND expires < %d THEN 1 ELSE 0 END) AS expiring_soon_tokens", now.Unix(), soon.Unix())).
+			Where("org_id = ?", orgID).
+			In("service_account_id", serviceAccountIDs).
+			GroupBy("service_account_id").
+			Find(&rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		counts[r.ServiceAccountId] = &serviceaccounts.TokenCounts{
+			Tokens:       r.Tokens,
+			Expired:      r.ExpiredTokens,
+			ExpiringSoon: r.ExpiringSoonTokens,
+		}
+	}
+	return counts, nil
+}
+
+// ListExpiringTokens returns at most limit tokens of the org that haven't expired yet,
+// ordered by expiration. Tokens that never expire are left out.
+func (s *ServiceAccountsStoreImpl) ListExpiringTokens(ctx context.Context, orgID int64, now time.Time, limit int) ([]*serviceaccounts.ExpiringToken, error) {
+	result := make([]*serviceaccounts.ExpiringToken, 0)
+	err := s.sqlStore.WithDbSession(ctx, func(dbSession *sqlstore.DBSession) error {
+		quotedUser := s.sqlStore.Dialect.Quote("user")
+		return dbSession.Table("api_key").
+			Select("api_key.*, "+quotedUser+".name AS service_account_name, "+quotedUser+".login AS service_account_login").
+			Join("inner", quotedUser, quotedUser+".id = api_key.service_account_id").
+			Where(quotedUser+".org_id=? AND api_key.expires IS NOT NULL AND api_key.expires > ?", orgID, now.Unix()).
+			Asc("api_key.expires", "api_key.id").
+			Limit(limit).
+			Find(&result)
+	})
+	return result, err
+}
+
 // RetrieveServiceAccountByID returns a service account by its ID
 func (s *ServiceAccountsStoreImpl) RetrieveServiceAccount(ctx context.Context, orgID, serviceAccountID int64) (*serviceaccounts.ServiceAccountProfileDTO, error) {
 	serviceAccount := &serviceaccounts.ServiceAccountProfileDTO{}
@@ -198,7 +408,9 @@ func (s *ServiceAccountsStoreImpl) RetrieveServiceAccount(ctx context.Context, o
 			"user.login",
 			"user.created",
 			"user.updated",
+			"user.last_seen_at",
 			"user.is_disabled",
+			"user.expires_at",
 		)
 
 		if ok, err := sess.Get(serviceAccount); err != nil {
@@ -243,7 +455,7 @@ func (s *ServiceAccountsStoreImpl) UpdateServiceAccount(ctx context.Context,
 			return err
 		}
 
-		if saForm.Name == nil && saForm.Role == nil && saForm.IsDisabled == nil {
+		if saForm.Name == nil && saForm.Role == nil && saForm.IsDisabled == nil && saForm.ExpiresAt == nil {
 			return nil
 		}
 
@@ -260,7 +472,7 @@ func (s *ServiceAccountsStoreImpl) UpdateServiceAccount(ctx context.Context,
 			updatedUser.Role = string(*saForm.Role)
 		}
 
-		if saForm.Name != nil || saForm.IsDisabled != nil {
+		if saForm.Name != nil || saForm.IsDisabled != nil || saForm.ExpiresAt != nil {
 			user := models.User{
 				Updated: updateTime,
 			}
@@ -276,6 +488,11 @@ func (s *ServiceAccountsStoreImpl) UpdateServiceAccount(ctx context.Context,
 				updatedUser.Name = *saForm.Name
 			}
 
+			if saForm.ExpiresAt != nil {
+				user.ExpiresAt = saForm.ExpiresAt
+				updatedUser.ExpiresAt = saForm.ExpiresAt
+			}
+
 			if _, err := sess.ID(serviceAccountID).Update(&user); err != nil {
 				return err
 			}
@@ -287,66 +504,70 @@ func (s *ServiceAccountsStoreImpl) UpdateServiceAccount(ctx context.Context,
 	return updatedUser, err
 }
 
+// SetServiceAccountDisabled disables or enables a service account. The tokens of a
+// disabled account are rejected when authenticating, they are kept for when it is enabled again.
+func (s *ServiceAccountsStoreImpl) SetServiceAccountDisabled(ctx context.Context, orgID, serviceAccountID int64, disabled bool) error {
+	return s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		user := models.User{IsDisabled: disabled, Updated: time.Now()}
+		n, err := sess.
+			Where(fmt.Sprintf("id = ? AND is_service_account = %s AND id IN (SELECT user_id FROM org_user WHERE org_id = ?)",
+				s.sqlStore.Dialect.BooleanStr(true)), serviceAccountID, orgID).
+			Cols("is_disabled", "updated").
+			Update(&user)
+		if err != nil {
+			return err
+		} else if n == 0 {
+			return serviceaccounts.ErrServiceAccountNotFound
+		}
+		return nil
+	})
+}
+
+// SearchOrgServiceAccounts returns a page of the service accounts matching the search,
+// each with its number of tokens
 func (s *ServiceAccountsStoreImpl) SearchOrgServiceAccounts(
-	ctx context.Context, orgID int64, query string, page int, limit int,
-	signedInUser *models.SignedInUser,
+	ctx context.Context, query *serviceaccounts.SearchOrgServiceAccountsQuery,
 ) (*serviceaccounts.SearchServiceAccountsResult, error) {
 	searchResult := &serviceaccounts.SearchServiceAccountsResult{
 		TotalCount:      0,
 		ServiceAccounts: make([]*serviceaccounts.ServiceAccountDTO, 0),
-		Page:            page,
-		PerPage:         limit,
+		Page:            query.Page,
+		PerPage:         query.Limit,
 	}
 
 	err := s.sqlStore.WithDbSession(ctx, func(dbSession *sqlstore.DBSession) error {
+		user := s.sqlStore.Dialect.Quote("user")
 		sess := dbSession.Table("org_user")
-		sess.Join("INNER", s.sqlStore.Dialect.Quote("user"), fmt.Sprintf("org_user.user_id=%s.id", s.sqlStore.Dialect.Quote("user")))
-
-		whereConditions := make([]string, 0)
-		whereParams := make([]interface{}, 0)
-
-		whereConditions = append(whereConditions, "org_user.org_id = ?")
-		whereParams = append(whereParams, orgID)
-
-		whereConditions = append(whereConditions,
-			fmt.Sprintf("%s.is_service_account = %s",
-				s.sqlStore.Dialect.Quote("user"),
-				s.sqlStore.Dialect.BooleanStr(true)))
+		sess.Join("INNER", user, fmt.Sprintf("org_user.user_id=%s.id", user))
+		// count the tokens in the same query rather than once per service account
+		sess.Join("LEFT", "api_key", "api_key.service_account_id=org_user.user_id")
 
-		if s.sqlStore.Cfg.IsFeatureToggleEnabled(featuremgmt.FlagAccesscontrol) {
-			acFilter, err := accesscontrol.Filter(signedInUser, "org_user.user_id", "serviceaccounts", serviceaccounts.ActionRead)
-			if err != nil {
-				return err
-			}
-			whereConditions = append(whereConditions, acFilter.Where)
-			whereParams = append(whereParams, acFilter.Args...)
+		whereConditions, whereParams, err := s.searchConditions(query)
+		if err != nil {
+			return err
 		}
-
-		if query != "" {
-			queryWithWildcards := "%" + query + "%"
-			whereConditions = append(whereConditions, "(email "+s.sqlStore.Dialect.LikeStr()+" ? OR name "+s.sqlStore.Dialect.LikeStr()+" ? OR login "+s.sqlStore.Dialect.LikeStr()+" ?)")
-			whereParams = append(whereParams, queryWithWildcards, queryWithWildcards, queryWithWildcards)
+		orderBy, err := s.searchOrder(query.SortOpts)
+		if err != nil {
+			return err
 		}
 
 		if len(whereConditions) > 0 {
 			sess.Where(strings.Join(whereConditions, " AND "), whereParams...)
 		}
-		if limit > 0 {
-			offset := limit * (page - 1)
-			sess.Limit(limit, offset)
+		if query.Limit > 0 {
+			offset := query.Limit * (query.Page - 1)
+			sess.Limit(query.Limit, offset)
 		}
 
-		sess.Cols(
-			"org_user.user_id",
-			"org_user.org_id",
-			"org_user.role",
-			"user.email",
-			"user.name",
-			"user.login",
-			"user.last_seen_at",
-			"user.is_disabled",
-		)
-		sess.Asc("user.email", "user.login")
+		cols := fmt.Sprintf("org_user.user_id, org_user.org_id, org_user.role, %[1]s.email, %[1]s.name, %[1]s.login, "+
+			"%[1]s.last_seen_at, %[1]s.is_disabled, %[1]s.expires_at", user)
+		// the tokens are split in the same grouped query, tokens without expiration are active
+		now := time.Now().Unix()
+		sess.Select(cols + ", COUNT(api_key.id) AS tokens" +
+			fmt.Sprintf(", SUM(CASE WHEN api_key.expires IS NOT NULL AND api_key.expires <= %d THEN 1 ELSE 0 END) AS expired_tokens", now) +
+			fmt.Sprintf(", SUM(CASE WHEN api_key.id IS NOT NULL AND (api_key.expires IS NULL OR api_key.expires > %d) THEN 1 ELSE 0 END) AS active_tokens", now))
+		sess.GroupBy(cols)
+		sess.OrderBy(orderBy)
 		if err := sess.Find(&searchResult.ServiceAccounts); err != nil {
 			return err
 		}
@@ -374,6 +595,173 @@ func (s *ServiceAccountsStoreImpl) SearchOrgServiceAccounts(
 	return searchResult, nil
 }
 
+// DisableExpiredServiceAccounts disables every service account whose expiration is at or before now
+func (s *ServiceAccountsStoreImpl) DisableExpiredServiceAccounts(ctx context.Context, now time.Time) (int64, error) {
+	var disabled int64
+	err := s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		user := models.User{IsDisabled: true, Updated: now}
+		var err error
+		disabled, err = sess.
+			Where(fmt.Sprintf("is_service_account = %s AND is_disabled = %s AND expires_at IS NOT NULL AND expires_at <= ?",
+				s.sqlStore.Dialect.BooleanStr(true), s.sqlStore.Dialect.BooleanStr(false)), now).
+			Cols("is_disabled", "updated").
+			Update(&user)
+		return err
+	})
+	return disabled, err
+}
+
+// GetServiceAccountsStats counts the service accounts of an org by role and disabled state,
+// with a single grouped query.
+func (s *ServiceAccountsStoreImpl) GetServiceAccountsStats(ctx context.Context, orgID int64) (*serviceaccounts.ServiceAccountsStatsDTO, error) {
+	type roleCount struct {
+		Role       models.RoleType
+		IsDisabled bool
+		Accounts   int64
+	}
+	counts := make([]*roleCount, 0)
+	err := s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		quotedUser := s.sqlStore.Dialect.Quote("user")
+		return sess.Table("org_user").
+			Select("org_user.role AS role, "+quotedUser+".is_disabled AS is_disabled, COUNT(*) AS accounts").
+			Join("INNER", quotedUser, "org_user.user_id="+quotedUser+".id").
+			Where("org_user.org_id = ? AND "+quotedUser+".is_service_account = "+s.sqlStore.Dialect.BooleanStr(true), orgID).
+			GroupBy("org_user.role, " + quotedUser + ".is_disabled").
+			Find(&counts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &serviceaccounts.ServiceAccountsStatsDTO{
+		Roles: map[models.RoleType]int64{models.ROLE_VIEWER: 0, models.ROLE_EDITOR: 0, models.ROLE_ADMIN: 0},
+	}
+	for _, c := range counts {
+		stats.Total += c.Accounts
+		stats.Roles[c.Role] += c.Accounts
+		if c.IsDisabled {
+			stats.Disabled += c.Accounts
+		}
+	}
+	return stats, nil
+}
+
+// CountServiceAccounts returns the number of service accounts in an org
+func (s *ServiceAccountsStoreImpl) CountServiceAccounts(ctx context.Context, orgID int64) (int64, error) {
+	var count int64
+	err := s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		serviceaccount := serviceaccounts.ServiceAccountDTO{}
+		var err error
+		count, err = sess.Table("org_user").
+			Join("INNER", s.sqlStore.Dialect.Quote("user"), fmt.Sprintf("org_user.user_id=%s.id", s.sqlStore.Dialect.Quote("user"))).
+			Where(fmt.Sprintf("org_user.org_id = ? AND %s.is_service_account = %s",
+				s.sqlStore.Dialect.Quote("user"), s.sqlStore.Dialect.BooleanStr(true)), orgID).
+			Count(&serviceaccount)
+		return err
+	})
+	return count, err
+}
+
+// CountOrgServiceAccounts returns how many service accounts a search would return, without fetching them
+func (s *ServiceAccountsStoreImpl) CountOrgServiceAccounts(ctx context.Context, query *serviceaccounts.SearchOrgServiceAccountsQuery) (int64, error) {
+	var count int64
+	err := s.sqlStore.WithDbSession(ctx, func(dbSession *sqlstore.DBSession) error {
+		whereConditions, whereParams, err := s.searchConditions(query)
+		if err != nil {
+			return err
+		}
+
+		serviceaccount := serviceaccounts.ServiceAccountDTO{}
+		count, err = dbSession.Table("org_user").
+			Join("INNER", s.sqlStore.Dialect.Quote("user"), fmt.Sprintf("org_user.user_id=%s.id", s.sqlStore.Dialect.Quote("user"))).
+			Where(strings.Join(whereConditions, " AND "), whereParams...).
+			Count(&serviceaccount)
+		return err
+	})
+	return count, err
+}
+
+// searchConditions builds the filters shared by searching and counting service accounts
+func (s *ServiceAccountsStoreImpl) searchConditions(query *serviceaccounts.SearchOrgServiceAccountsQuery) ([]string, []interface{}, error) {
+	whereConditions := make([]string, 0)
+	whereParams := make([]interface{}, 0)
+
+	whereConditions = append(whereConditions, "org_user.org_id = ?")
+	whereParams = append(whereParams, query.OrgID)
+
+	whereConditions = append(whereConditions,
+		fmt.Sprintf("%s.is_service_account = %s",
+			s.sqlStore.Dialect.Quote("user"),
+			s.sqlStore.Dialect.BooleanStr(true)))
+
+	if s.sqlStore.Cfg.IsFeatureToggleEnabled(featuremgmt.FlagAccesscontrol) {
+		acFilter, err := accesscontrol.Filter(query.SignedInUser, "org_user.user_id", "serviceaccounts", serviceaccounts.ActionRead)
+		if err != nil {
+			return nil, nil, err
+		}
+		whereConditions = append(whereConditions, acFilter.Where)
+		whereParams = append(whereParams, acFilter.Args...)
+	}
+
+	if query.Query != "" {
+		queryWithWildcards := "%" + query.Query + "%"
+		user := s.sqlStore.Dialect.Quote("user")
+		whereConditions = append(whereConditions, fmt.Sprintf("(%[1]s.email %[2]s ? OR %[1]s.name %[2]s ? OR %[1]s.login %[2]s ?)",
+			user, s.sqlStore.Dialect.LikeStr()))
+		whereParams = append(whereParams, queryWithWildcards, queryWithWildcards, queryWithWildcards)
+	}
+
+	if query.Role != "" {
+		whereConditions = append(whereConditions, "org_user.role = ?")
+		whereParams = append(whereParams, query.Role)
+	}
+
+	switch query.Filter {
+	case serviceaccounts.FilterOnlyExpiredTokens:
+		// aggregate the tokens per account and keep those where every token has expired
+		whereConditions = append(whereConditions, "org_user.user_id IN ("+
+			"SELECT api_key.service_account_id FROM api_key"+
+			" WHERE api_key.service_account_id IS NOT NULL"+
+			" GROUP BY api_key.service_account_id"+
+			" HAVING COUNT(*) = SUM(CASE WHEN api_key.expires IS NOT NULL AND api_key.expires <= ? THEN 1 ELSE 0 END))")
+		whereParams = append(whereParams, time.Now().Unix())
+	case serviceaccounts.FilterOnlyDisabled:
+		whereConditions = append(whereConditions, fmt.Sprintf("%s.is_disabled = %s",
+			s.sqlStore.Dialect.Quote("user"),
+			s.sqlStore.Dialect.BooleanStr(true)))
+	case serviceaccounts.FilterIncludeAll, "":
+	default:
+		return nil, nil, fmt.Errorf("unknown service account filter %q", query.Filter)
+	}
+
+	return whereConditions, whereParams, nil
+}
+
+// searchOrder builds the ORDER BY clause of a search, ties are broken by login
+func (s *ServiceAccountsStoreImpl) searchOrder(sortOpts serviceaccounts.SortOpts) (string, error) {
+	user := s.sqlStore.Dialect.Quote("user")
+
+	var column string
+	switch sortOpts.Field {
+	case "":
+		return fmt.Sprintf("%[1]s.email ASC, %[1]s.login ASC", user), nil
+	case serviceaccounts.SortByName:
+		column = user + ".name"
+	case serviceaccounts.SortByTokens:
+		column = "tokens"
+	case serviceaccounts.SortByLastUsed:
+		column = user + ".last_seen_at"
+	default:
+		return "", fmt.Errorf("unknown service account sort field %q", sortOpts.Field)
+	}
+
+	direction := "ASC"
+	if sortOpts.Descending {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("%s %s, %s.login ASC", column, direction, user), nil
+}
+
 func contains(s []int64, e int64) bool {
 	for _, a := range s {
 		if a == e {
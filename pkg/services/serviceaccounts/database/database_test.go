@@ -2,7 +2,10 @@ package database
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts"
@@ -15,7 +18,7 @@ import (
 func TestStore_CreateServiceAccount(t *testing.T) {
 	_, store := setupTestDatabase(t)
 	t.Run("create service account", func(t *testing.T) {
-		saDTO, err := store.CreateServiceAccount(context.Background(), 1, "new Service Account")
+		saDTO, err := store.CreateServiceAccount(context.Background(), 1, &serviceaccounts.CreateServiceAccountForm{Name: "new Service Account"})
 		require.NoError(t, err)
 		assert.Equal(t, "sa-new-service-account", saDTO.Login)
 		assert.Equal(t, "new Service Account", saDTO.Name)
@@ -27,6 +30,148 @@ func TestStore_CreateServiceAccount(t *testing.T) {
 		assert.Equal(t, "new Service Account", retrieved.Name)
 		assert.Equal(t, 1, int(retrieved.OrgId))
 	})
+
+	t.Run("create service account with a duplicate name", func(t *testing.T) {
+		_, err := store.CreateServiceAccount(context.Background(), 1, &serviceaccounts.CreateServiceAccountForm{Name: "new Service Account"})
+		require.ErrorIs(t, err, serviceaccounts.ErrServiceAccountAlreadyExists)
+	})
+}
+
+func TestStore_ProvisionServiceAccount(t *testing.T) {
+	_, store := setupTestDatabase(t)
+	role := models.ROLE_EDITOR
+
+	t.Run("should create the service account and its tokens", func(t *testing.T) {
+		tokens := []*models.AddApiKeyCommand{
+			{Name: "deploy", Role: models.ROLE_EDITOR, Key: "hashed-deploy", SecondsToLive: 3600},
+			{Name: "read", Role: models.ROLE_VIEWER, Key: "hashed-read"},
+		}
+		saDTO, err := store.ProvisionServiceAccount(context.Background(), 1,
+			&serviceaccounts.CreateServiceAccountForm{Name: "provisioned", Role: &role}, tokens)
+		require.NoError(t, err)
+		assert.Equal(t, "sa-provisioned", saDTO.Login)
+		assert.Equal(t, int64(2), saDTO.Tokens)
+
+		for _, cmd := range tokens {
+			require.NotNil(t, cmd.Result)
+			assert.Equal(t, saDTO.Id, *cmd.Result.ServiceAccountId)
+			assert.Equal(t, int64(1), cmd.Result.OrgId)
+		}
+		require.NotNil(t, tokens[0].Result.Expires)
+		assert.Nil(t, tokens[1].Result.Expires)
+
+		keys, err := store.ListTokens(context.Background(), 1, saDTO.Id)
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+	})
+
+	t.Run("should roll everything back when a token fails", func(t *testing.T) {
+		tokens := []*models.AddApiKeyCommand{
+			{Name: "rollback", Role: models.ROLE_VIEWER, Key: "hashed-rollback-1"},
+			{Name: "rollback", Role: models.ROLE_VIEWER, Key: "hashed-rollback-2"},
+		}
+		_, err := store.ProvisionServiceAccount(context.Background(), 1,
+			&serviceaccounts.CreateServiceAccountForm{Name: "rolled back", Role: &role}, tokens)
+		require.ErrorIs(t, err, models.ErrDuplicateApiKey)
+
+		available, err := store.IsServiceAccountNameAvailable(context.Background(), "rolled back")
+		require.NoError(t, err)
+		assert.True(t, available)
+
+		ids, err := store.GetServiceAccountIDsByName(context.Background(), 1, "rolled back")
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		query := models.GetApiKeyByNameQuery{KeyName: "rollback", OrgId: 1}
+		require.ErrorIs(t, store.sqlStore.GetApiKeyByName(context.Background(), &query), models.ErrInvalidApiKey)
+	})
+}
+
+func TestStore_CreateServiceAccountLoginPrefix(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	prefix := db.Cfg.ServiceAccountLoginPrefix
+	db.Cfg.ServiceAccountLoginPrefix = "svc-"
+	t.Cleanup(func() { db.Cfg.ServiceAccountLoginPrefix = prefix })
+
+	t.Run("should prefix the generated login", func(t *testing.T) {
+		saDTO, err := store.CreateServiceAccount(context.Background(), 1, &serviceaccounts.CreateServiceAccountForm{Name: "Generated Login"})
+		require.NoError(t, err)
+		assert.Equal(t, "svc-generated-login", saDTO.Login)
+	})
+
+	t.Run("should accept an explicit login with the prefix", func(t *testing.T) {
+		saDTO, err := store.CreateServiceAccount(context.Background(), 1,
+			&serviceaccounts.CreateServiceAccountForm{Name: "Explicit Login", Login: "svc-ldap-sync"})
+		require.NoError(t, err)
+		assert.Equal(t, "svc-ldap-sync", saDTO.Login)
+	})
+
+	t.Run("should reject an explicit login without the prefix", func(t *testing.T) {
+		for _, login := range []string{"sa-ldap-sync", "ldap-sync", "svc-"} {
+			_, err := store.CreateServiceAccount(context.Background(), 1,
+				&serviceaccounts.CreateServiceAccountForm{Name: "Invalid Login " + login, Login: login})
+			var invalidLogin *ErrSAInvalidLogin
+			require.ErrorAs(t, err, &invalidLogin, login)
+		}
+	})
+}
+
+func TestStore_CreateServiceAccountWithExpiration(t *testing.T) {
+	_, store := setupTestDatabase(t)
+	expiresAt := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	saDTO, err := store.CreateServiceAccount(context.Background(), 1,
+		&serviceaccounts.CreateServiceAccountForm{Name: "expiring", ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+	require.NotNil(t, saDTO.ExpiresAt)
+
+	retrieved, err := store.RetrieveServiceAccount(context.Background(), 1, saDTO.Id)
+	require.NoError(t, err)
+	require.NotNil(t, retrieved.ExpiresAt)
+	assert.Equal(t, expiresAt.Unix(), retrieved.ExpiresAt.Unix())
+
+	later := expiresAt.Add(24 * time.Hour)
+	updated, err := store.UpdateServiceAccount(context.Background(), 1, saDTO.Id,
+		&serviceaccounts.UpdateServiceAccountForm{ExpiresAt: &later})
+	require.NoError(t, err)
+	assert.Equal(t, later.Unix(), updated.ExpiresAt.Unix())
+
+	retrieved, err = store.RetrieveServiceAccount(context.Background(), 1, saDTO.Id)
+	require.NoError(t, err)
+	require.NotNil(t, retrieved.ExpiresAt)
+	assert.Equal(t, later.Unix(), retrieved.ExpiresAt.Unix())
+}
+
+func TestStore_DisableExpiredServiceAccounts(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	tests.SetupMainOrg(t, db)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	expired, err := store.CreateServiceAccount(context.Background(), 1,
+		&serviceaccounts.CreateServiceAccountForm{Name: "expired", ExpiresAt: &past})
+	require.NoError(t, err)
+	notExpired, err := store.CreateServiceAccount(context.Background(), 1,
+		&serviceaccounts.CreateServiceAccountForm{Name: "not expired", ExpiresAt: &future})
+	require.NoError(t, err)
+	noExpiration, err := store.CreateServiceAccount(context.Background(), 1,
+		&serviceaccounts.CreateServiceAccountForm{Name: "no expiration"})
+	require.NoError(t, err)
+
+	disabled, err := store.DisableExpiredServiceAccounts(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), disabled)
+
+	for id, expectDisabled := range map[int64]bool{expired.Id: true, notExpired.Id: false, noExpiration.Id: false} {
+		sa, err := store.RetrieveServiceAccount(context.Background(), 1, id)
+		require.NoError(t, err)
+		assert.Equal(t, expectDisabled, sa.IsDisabled, sa.Name)
+	}
+
+	// already disabled accounts aren't counted again
+	disabled, err = store.DisableExpiredServiceAccounts(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), disabled)
 }
 
 func TestStore_DeleteServiceAccount(t *testing.T) {
@@ -117,3 +262,347 @@ func TestStore_RetrieveServiceAccountWithTeams(t *testing.T) {
 	require.Len(t, dto.Teams, 1)
 	require.Equal(t, "serviceTeam", dto.Teams[0])
 }
+
+func TestStore_SearchOrgServiceAccountsOnlyExpiredTokens(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	tests.SetupMainOrg(t, db)
+	now := time.Now()
+	expired := now.Add(-time.Hour).Unix()
+	active := now.Add(time.Hour).Unix()
+
+	addTokens := func(saID int64, expires ...*int64) {
+		err := db.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			for i, exp := range expires {
+				_, err := sess.Insert(&models.ApiKey{
+					OrgId:            1,
+					Name:             fmt.Sprintf("token-%d-%d", saID, i),
+					Key:              fmt.Sprintf("key-%d-%d", saID, i),
+					Role:             models.ROLE_VIEWER,
+					Created:          now,
+					Updated:          now,
+					Expires:          exp,
+					ServiceAccountId: &saID,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	allExpired := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-all-expired", IsServiceAccount: true})
+	addTokens(allExpired.Id, &expired, &expired)
+	oneExpired := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-one-expired", IsServiceAccount: true})
+	addTokens(oneExpired.Id, &expired)
+	mixed := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-mixed", IsServiceAccount: true})
+	addTokens(mixed.Id, &expired, &active)
+	neverExpires := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-never-expires", IsServiceAccount: true})
+	addTokens(neverExpires.Id, &expired, nil)
+	onlyActive := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-active", IsServiceAccount: true})
+	addTokens(onlyActive.Id, &active)
+	tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-no-tokens", IsServiceAccount: true})
+
+	user := &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_ADMIN}
+	result, err := store.SearchOrgServiceAccounts(context.Background(), &serviceaccounts.SearchOrgServiceAccountsQuery{
+		OrgID: 1, Filter: serviceaccounts.FilterOnlyExpiredTokens, Page: 1, Limit: 100, SignedInUser: user,
+	})
+	require.NoError(t, err)
+
+	logins := make([]string, 0, len(result.ServiceAccounts))
+	for _, sa := range result.ServiceAccounts {
+		logins = append(logins, sa.Login)
+	}
+	assert.ElementsMatch(t, []string{"sa-all-expired", "sa-one-expired"}, logins)
+	assert.Equal(t, int64(2), result.TotalCount)
+
+	count, err := store.CountOrgServiceAccounts(context.Background(), &serviceaccounts.SearchOrgServiceAccountsQuery{
+		OrgID: 1, Filter: serviceaccounts.FilterOnlyExpiredTokens, SignedInUser: user,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	all, err := store.SearchOrgServiceAccounts(context.Background(), &serviceaccounts.SearchOrgServiceAccountsQuery{
+		OrgID: 1, Filter: serviceaccounts.FilterIncludeAll, Page: 1, Limit: 100, SignedInUser: user,
+	})
+	require.NoError(t, err)
+	assert.Len(t, all.ServiceAccounts, 6)
+}
+
+func TestStore_SearchOrgServiceAccountsOnlyDisabled(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	disabled := true
+
+	for _, login := range []string{"sa-build-disabled", "sa-deploy-disabled", "sa-build-enabled"} {
+		sa := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: login, IsServiceAccount: true})
+		if strings.HasSuffix(login, "-disabled") {
+			_, err := store.UpdateServiceAccount(context.Background(), sa.OrgId, sa.Id,
+				&serviceaccounts.UpdateServiceAccountForm{IsDisabled: &disabled})
+			require.NoError(t, err)
+		}
+	}
+
+	user := &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_ADMIN}
+	search := func(text string) []string {
+		result, err := store.SearchOrgServiceAccounts(context.Background(), &serviceaccounts.SearchOrgServiceAccountsQuery{
+			OrgID: 1, Query: text, Filter: serviceaccounts.FilterOnlyDisabled, Page: 1, Limit: 100, SignedInUser: user,
+		})
+		require.NoError(t, err)
+		logins := make([]string, 0, len(result.ServiceAccounts))
+		for _, sa := range result.ServiceAccounts {
+			logins = append(logins, sa.Login)
+		}
+		assert.Equal(t, int64(len(logins)), result.TotalCount)
+		return logins
+	}
+
+	assert.ElementsMatch(t, []string{"sa-build-disabled", "sa-deploy-disabled"}, search(""))
+	assert.ElementsMatch(t, []string{"sa-build-disabled"}, search("build"))
+}
+
+func TestStore_SearchOrgServiceAccountsByRole(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	tests.SetupMainOrg(t, db)
+
+	for login, role := range map[string]models.RoleType{
+		"sa-build-admin":  models.ROLE_ADMIN,
+		"sa-deploy-admin": models.ROLE_ADMIN,
+		"sa-other-admin":  models.ROLE_ADMIN,
+		"sa-build-editor": models.ROLE_EDITOR,
+		"sa-build-viewer": models.ROLE_VIEWER,
+	} {
+		tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: login, Role: string(role), IsServiceAccount: true})
+	}
+
+	user := &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_ADMIN}
+	search := func(text string, role models.RoleType, page, limit int) ([]string, int64) {
+		result, err := store.SearchOrgServiceAccounts(context.Background(), &serviceaccounts.SearchOrgServiceAccountsQuery{
+			OrgID: 1, Query: text, Role: role, Page: page, Limit: limit, SignedInUser: user,
+		})
+		require.NoError(t, err)
+		logins := make([]string, 0, len(result.ServiceAccounts))
+		for _, sa := range result.ServiceAccounts {
+			logins = append(logins, sa.Login)
+		}
+		return logins, result.TotalCount
+	}
+
+	logins, total := search("", models.ROLE_ADMIN, 1, 100)
+	assert.Equal(t, []string{"sa-build-admin", "sa-deploy-admin", "sa-other-admin"}, logins)
+	assert.Equal(t, int64(3), total)
+
+	logins, total = search("build", models.ROLE_ADMIN, 1, 100)
+	assert.Equal(t, []string{"sa-build-admin"}, logins)
+	assert.Equal(t, int64(1), total)
+
+	logins, total = search("", models.ROLE_ADMIN, 2, 2)
+	assert.Equal(t, []string{"sa-other-admin"}, logins)
+	assert.Equal(t, int64(3), total)
+
+	logins, total = search("build", "", 1, 100)
+	assert.Len(t, logins, 3)
+	assert.Equal(t, int64(3), total)
+}
+
+func TestStore_SearchOrgServiceAccountsTokenCounts(t *testing.T) {
+	db, store := setupTestDatabase(t)
+
+	tokenCounts := map[string]int{"sa-no-tokens": 0, "sa-one-token": 1, "sa-three-tokens": 3}
+	for login, tokens := range tokenCounts {
+		sa := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: login, IsServiceAccount: true})
+		for i := 0; i < tokens; i++ {
+			err := store.AddServiceAccountToken(context.Background(), sa.Id, &models.AddApiKeyCommand{
+				Name:  fmt.Sprintf("%s-%d", login, i),
+				Role:  models.ROLE_VIEWER,
+				OrgId: sa.OrgId,
+				Key:   fmt.Sprintf("key-%s-%d", login, i),
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	user := &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_ADMIN}
+	result, err := store.SearchOrgServiceAccounts(context.Background(), &serviceaccounts.SearchOrgServiceAccountsQuery{
+		OrgID: 1, Filter: serviceaccounts.FilterIncludeAll, Page: 1, Limit: 100, SignedInUser: user,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.ServiceAccounts, len(tokenCounts))
+	assert.Equal(t, int64(len(tokenCounts)), result.TotalCount)
+
+	for _, sa := range result.ServiceAccounts {
+		tokens, err := store.ListTokens(context.Background(), sa.OrgId, sa.Id)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(tokens)), sa.Tokens, sa.Login)
+		assert.Equal(t, int64(tokenCounts[sa.Login]), sa.Tokens, sa.Login)
+	}
+
+	t.Run("should count the tokens of the matching service accounts", func(t *testing.T) {
+		result, err := store.SearchOrgServiceAccounts(context.Background(), &serviceaccounts.SearchOrgServiceAccountsQuery{
+			OrgID: 1, Query: "three", Filter: serviceaccounts.FilterIncludeAll, Page: 1, Limit: 100, SignedInUser: user,
+		})
+		require.NoError(t, err)
+		require.Len(t, result.ServiceAccounts, 1)
+		assert.Equal(t, int64(3), result.ServiceAccounts[0].Tokens)
+	})
+}
+
+func TestStore_SearchOrgServiceAccountsExpiredTokenCounts(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	tests.SetupMainOrg(t, db)
+	now := time.Now()
+	expired := now.Add(-time.Hour).Unix()
+	active := now.Add(time.Hour).Unix()
+
+	addTokens := func(saID int64, expires ...*int64) {
+		err := db.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			for i, exp := range expires {
+				_, err := sess.Insert(&models.ApiKey{
+					OrgId:            1,
+					Name:             fmt.Sprintf("token-%d-%d", saID, i),
+					Key:              fmt.Sprintf("key-%d-%d", saID, i),
+					Role:             models.ROLE_VIEWER,
+					Created:          now,
+					Updated:          now,
+					Expires:          exp,
+					ServiceAccountId: &saID,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	mixed := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-mixed", IsServiceAccount: true})
+	addTokens(mixed.Id, &expired, &expired, &active, nil)
+	allExpired := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-all-expired", IsServiceAccount: true})
+	addTokens(allExpired.Id, &expired)
+	neverExpires := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-never-expires", IsServiceAccount: true})
+	addTokens(neverExpires.Id, nil, nil)
+	tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-no-tokens", IsServiceAccount: true})
+
+	expected := map[string]struct{ tokens, expired, active int64 }{
+		"sa-mixed":         {tokens: 4, expired: 2, active: 2},
+		"sa-all-expired":   {tokens: 1, expired: 1, active: 0},
+		"sa-never-expires": {tokens: 2, expired: 0, active: 2},
+		"sa-no-tokens":     {tokens: 0, expired: 0, active: 0},
+	}
+
+	user := &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_ADMIN}
+	result, err := store.SearchOrgServiceAccounts(context.Background(), &serviceaccounts.SearchOrgServiceAccountsQuery{
+		OrgID: 1, Filter: serviceaccounts.FilterIncludeAll, Page: 1, Limit: 100, SignedInUser: user,
+	})
+	require.NoError(t, err)
+	require.Len(t, result.ServiceAccounts, len(expected))
+
+	for _, sa := range result.ServiceAccounts {
+		counts, ok := expected[sa.Login]
+		require.True(t, ok, sa.Login)
+		assert.Equal(t, counts.tokens, sa.Tokens, sa.Login)
+		assert.Equal(t, counts.expired, sa.ExpiredTokens, sa.Login)
+		assert.Equal(t, counts.active, sa.ActiveTokens, sa.Login)
+	}
+}
+
+func TestStore_SearchOrgServiceAccountsSort(t *testing.T) {
+	db, store := setupTestDatabase(t)
+
+	for login, tokens := range map[string]int{"sa-b": 2, "sa-c": 0, "sa-a": 1} {
+		sa := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: login, Name: login, IsServiceAccount: true})
+		for i := 0; i < tokens; i++ {
+			err := store.AddServiceAccountToken(context.Background(), sa.Id, &models.AddApiKeyCommand{
+				Name:  fmt.Sprintf("%s-%d", login, i),
+				Role:  models.ROLE_VIEWER,
+				OrgId: sa.OrgId,
+				Key:   fmt.Sprintf("key-%s-%d", login, i),
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	testCases := []struct {
+		desc     string
+		sortOpts serviceaccounts.SortOpts
+		expected []string
+	}{
+		{
+			desc:     "should sort by name",
+			sortOpts: serviceaccounts.SortOpts{Field: serviceaccounts.SortByName},
+			expected: []string{"sa-a", "sa-b", "sa-c"},
+		},
+		{
+			desc:     "should sort by name descending",
+			sortOpts: serviceaccounts.SortOpts{Field: serviceaccounts.SortByName, Descending: true},
+			expected: []string{"sa-c", "sa-b", "sa-a"},
+		},
+		{
+			desc:     "should sort by number of tokens",
+			sortOpts: serviceaccounts.SortOpts{Field: serviceaccounts.SortByTokens, Descending: true},
+			expected: []string{"sa-b", "sa-a", "sa-c"},
+		},
+	}
+
+	user := &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_ADMIN}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			result, err := store.SearchOrgServiceAccounts(context.Background(), &serviceaccounts.SearchOrgServiceAccountsQuery{
+				OrgID: 1, Page: 1, Limit: 100, SortOpts: tc.sortOpts, SignedInUser: user,
+			})
+			require.NoError(t, err)
+
+			logins := make([]string, 0, len(result.ServiceAccounts))
+			for _, sa := range result.ServiceAccounts {
+				logins = append(logins, sa.Login)
+			}
+			assert.Equal(t, tc.expected, logins)
+		})
+	}
+
+	t.Run("should reject an unknown sort field", func(t *testing.T) {
+		_, err := store.SearchOrgServiceAccounts(context.Background(), &serviceaccounts.SearchOrgServiceAccountsQuery{
+			OrgID: 1, Page: 1, Limit: 100, SortOpts: serviceaccounts.SortOpts{Field: "email"}, SignedInUser: user,
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestStore_GetServiceAccountsStats(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	tests.SetupMainOrg(t, db)
+	accounts := []struct {
+		user     tests.TestUser
+		disabled bool
+	}{
+		{user: tests.TestUser{Login: "sa-viewer-1", Role: "Viewer", IsServiceAccount: true}},
+		{user: tests.TestUser{Login: "sa-viewer-2", Role: "Viewer", IsServiceAccount: true}, disabled: true},
+		{user: tests.TestUser{Login: "sa-editor-1", Role: "Editor", IsServiceAccount: true}, disabled: true},
+		{user: tests.TestUser{Login: "sa-editor-2", Role: "Editor", IsServiceAccount: true}, disabled: true},
+		{user: tests.TestUser{Login: "sa-editor-3", Role: "Editor", IsServiceAccount: true}},
+		// users aren't service accounts
+		{user: tests.TestUser{Login: "user-admin", Role: "Admin", IsServiceAccount: false}},
+	}
+	for _, a := range accounts {
+		user := tests.SetupUserServiceAccount(t, db, a.user)
+		if a.disabled {
+			require.NoError(t, store.SetServiceAccountDisabled(context.Background(), user.OrgId, user.Id, true))
+		}
+	}
+
+	stats, err := store.GetServiceAccountsStats(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, &serviceaccounts.ServiceAccountsStatsDTO{
+		Total:    5,
+		Disabled: 3,
+		Roles:    map[models.RoleType]int64{models.ROLE_VIEWER: 2, models.ROLE_EDITOR: 3, models.ROLE_ADMIN: 0},
+	}, stats)
+
+	t.Run("should count nothing in another org", func(t *testing.T) {
+		stats, err := store.GetServiceAccountsStats(context.Background(), 2)
+		require.NoError(t, err)
+		assert.Zero(t, stats.Total)
+		assert.Zero(t, stats.Disabled)
+	})
+}
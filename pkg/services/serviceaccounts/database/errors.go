@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
 )
 
 type ErrSAInvalidName struct {
@@ -14,7 +15,15 @@ func (e *ErrSAInvalidName) Error() string {
 }
 
 func (e *ErrSAInvalidName) Unwrap() error {
-	return models.ErrUserAlreadyExists
+	return serviceaccounts.ErrServiceAccountAlreadyExists
+}
+
+type ErrSAInvalidLogin struct {
+	prefix string
+}
+
+func (e *ErrSAInvalidLogin) Error() string {
+	return fmt.Sprintf("service account login must start with %q", e.prefix)
 }
 
 type ErrMisingSAToken struct {
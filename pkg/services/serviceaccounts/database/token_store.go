@@ -2,9 +2,11 @@ package database
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 )
 
@@ -34,6 +36,8 @@ func (s *ServiceAccountsStoreImpl) AddServiceAccountToken(ctx context.Context, s
 			Updated:          updated,
 			Expires:          expires,
 			ServiceAccountId: &saID,
+			IpAllowlist:      strings.Join(cmd.IpAllowlist, ","),
+			IsReadOnly:       cmd.ReadOnly,
 		}
 
 		if _, err := sess.Insert(&t); err != nil {
@@ -62,6 +66,97 @@ func (s *ServiceAccountsStoreImpl) DeleteServiceAccountToken(ctx context.Context
 	})
 }
 
+// SetServiceAccountTokenPaused pauses or resumes a service account token
+func (s *ServiceAccountsStoreImpl) SetServiceAccountTokenPaused(ctx context.Context, orgID, serviceAccountID, tokenID int64, paused bool) error {
+	return s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		key := models.ApiKey{IsPaused: paused, Updated: time.Now()}
+		n, err := sess.Where("id=? and org_id=? and service_account_id=?", tokenID, orgID, serviceAccountID).
+			UseBool("is_paused").
+			Update(&key)
+		if err != nil {
+			return err
+		} else if n == 0 {
+			return &ErrMisingSAToken{}
+		}
+		return nil
+	})
+}
+
+// RotateServiceAccountToken replaces the hashed secret of a service account token, the old secret
+// stops working as soon as the update is committed. The other columns of the token are kept.
+func (s *ServiceAccountsStoreImpl) RotateServiceAccountToken(ctx context.Context, orgID, serviceAccountID, tokenID int64, hashedKey string) error {
+	return s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		key := models.ApiKey{Key: hashedKey, Updated: time.Now()}
+		n, err := sess.Where("id=? and org_id=? and service_account_id=?", tokenID, orgID, serviceAccountID).
+			Cols("key", "updated").
+			Update(&key)
+		if err != nil {
+			return err
+		} else if n == 0 {
+			return &ErrMisingSAToken{}
+		}
+		return nil
+	})
+}
+
+// UpdateServiceAccountToken renames a service account token or changes its expiration. The new name has
+// to be unused in the org, like the names of new tokens.
+func (s *ServiceAccountsStoreImpl) UpdateServiceAccountToken(ctx context.Context, orgID, serviceAccountID, tokenID int64, cmd *serviceaccounts.UpdateTokenCommand) error {
+	return s.sqlStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		key := models.ApiKey{}
+		exists, err := sess.Where("id=? and org_id=? and service_account_id=?", tokenID, orgID, serviceAccountID).Get(&key)
+		if err != nil {
+			return err
+		} else if !exists {
+			return &ErrMisingSAToken{}
+		}
+
+		updated := time.Now()
+		if cmd.Name != nil && *cmd.Name != key.Name {
+			exists, err := sess.Get(&models.ApiKey{OrgId: orgID, Name: *cmd.Name})
+			if err != nil {
+				return err
+			} else if exists {
+				return &ErrDuplicateSAToken{*cmd.Name}
+			}
+			key.Name = *cmd.Name
+			key.Key = cmd.HashedKey
+		}
+		if cmd.SecondsToLive != nil {
+			switch {
+			case *cmd.SecondsToLive > 0:
+				v := updated.Add(time.Second * time.Duration(*cmd.SecondsToLive)).Unix()
+				key.Expires = &v
+			case *cmd.SecondsToLive == 0:
+				key.Expires = nil
+			default:
+				return &ErrInvalidExpirationSAToken{}
+			}
+		}
+
+		key.Updated = updated
+		if _, err := sess.ID(key.Id).AllCols().Update(&key); err != nil {
+			return err
+		}
+		cmd.Result = &key
+		return nil
+	})
+}
+
+// ListDormantTokens returns the service account tokens that haven't been used since usedBefore.
+// Tokens that were never used count from their creation.
+func (s *ServiceAccountsStoreImpl) ListDormantTokens(ctx context.Context, orgID int64, usedBefore time.Time) ([]*models.ApiKey, error) {
+	result := make([]*models.ApiKey, 0)
+	err := s.sqlStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		sess.Where("service_account_id IS NOT NULL AND (last_used_at < ? OR (last_used_at IS NULL AND created < ?))", usedBefore, usedBefore)
+		if orgID > 0 {
+			sess.And("org_id = ?", orgID)
+		}
+		return sess.Asc("id").Find(&result)
+	})
+	return result, err
+}
+
 // assignApiKeyToServiceAccount sets the API key service account ID
 func (s *ServiceAccountsStoreImpl) assignApiKeyToServiceAccount(ctx context.Context, apikeyId int64, saccountId int64) error {
 	return s.sqlStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
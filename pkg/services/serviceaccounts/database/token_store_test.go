@@ -2,11 +2,16 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana/pkg/components/apikeygen"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts/tests"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -113,3 +118,255 @@ func TestStore_DeleteServiceAccountToken(t *testing.T) {
 		}
 	}
 }
+
+func TestStore_SetServiceAccountTokenPaused(t *testing.T) {
+	userToCreate := tests.TestUser{Login: "servicetestwithTeam@admin", IsServiceAccount: true}
+	db, store := setupTestDatabase(t)
+	user := tests.SetupUserServiceAccount(t, db, userToCreate)
+
+	keyName := t.Name()
+	key, err := apikeygen.New(user.OrgId, keyName)
+	require.NoError(t, err)
+
+	cmd := models.AddApiKeyCommand{
+		Name:   keyName,
+		Role:   "Viewer",
+		OrgId:  user.OrgId,
+		Key:    key.HashedKey,
+		Result: &models.ApiKey{},
+	}
+	err = store.AddServiceAccountToken(context.Background(), user.Id, &cmd)
+	require.NoError(t, err)
+
+	isPaused := func() bool {
+		keys, err := store.ListTokens(context.Background(), user.OrgId, user.Id)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		return keys[0].IsPaused
+	}
+
+	require.False(t, isPaused())
+
+	err = store.SetServiceAccountTokenPaused(context.Background(), user.OrgId, user.Id, cmd.Result.Id, true)
+	require.NoError(t, err)
+	require.True(t, isPaused())
+
+	err = store.SetServiceAccountTokenPaused(context.Background(), user.OrgId, user.Id, cmd.Result.Id, false)
+	require.NoError(t, err)
+	require.False(t, isPaused())
+
+	err = store.SetServiceAccountTokenPaused(context.Background(), user.OrgId, user.Id, cmd.Result.Id+1, true)
+	require.ErrorIs(t, err, models.ErrApiKeyNotFound)
+}
+
+func TestStore_RotateServiceAccountToken(t *testing.T) {
+	userToCreate := tests.TestUser{Login: "servicetestwithTeam@admin", IsServiceAccount: true}
+	db, store := setupTestDatabase(t)
+	user := tests.SetupUserServiceAccount(t, db, userToCreate)
+
+	keyName := t.Name()
+	key, err := apikeygen.New(user.OrgId, keyName)
+	require.NoError(t, err)
+
+	cmd := models.AddApiKeyCommand{
+		Name:          keyName,
+		Role:          "Viewer",
+		OrgId:         user.OrgId,
+		Key:           key.HashedKey,
+		SecondsToLive: 3600,
+		Result:        &models.ApiKey{},
+	}
+	err = store.AddServiceAccountToken(context.Background(), user.Id, &cmd)
+	require.NoError(t, err)
+
+	newKey, err := apikeygen.New(user.OrgId, keyName)
+	require.NoError(t, err)
+	err = store.RotateServiceAccountToken(context.Background(), user.OrgId, user.Id, cmd.Result.Id, newKey.HashedKey)
+	require.NoError(t, err)
+
+	keys, err := store.ListTokens(context.Background(), user.OrgId, user.Id)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, cmd.Result.Id, keys[0].Id)
+	assert.Equal(t, newKey.HashedKey, keys[0].Key)
+	assert.Equal(t, keyName, keys[0].Name)
+	assert.Equal(t, cmd.Result.Expires, keys[0].Expires)
+	assert.Equal(t, cmd.Result.Created.Unix(), keys[0].Created.Unix())
+
+	err = store.RotateServiceAccountToken(context.Background(), user.OrgId, user.Id+1, cmd.Result.Id, newKey.HashedKey)
+	require.ErrorIs(t, err, models.ErrApiKeyNotFound)
+}
+
+func TestStore_UpdateServiceAccountToken(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	user := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-update-token", IsServiceAccount: true})
+
+	addToken := func(t *testing.T, name string, secondsToLive int64) *models.ApiKey {
+		key, err := apikeygen.New(user.OrgId, name)
+		require.NoError(t, err)
+		cmd := models.AddApiKeyCommand{Name: name, Role: "Viewer", OrgId: user.OrgId, Key: key.HashedKey, SecondsToLive: secondsToLive}
+		require.NoError(t, store.AddServiceAccountToken(context.Background(), user.Id, &cmd))
+		return cmd.Result
+	}
+	token := addToken(t, "before", 0)
+	addToken(t, "taken", 0)
+
+	t.Run("should rename a token and replace its hash", func(t *testing.T) {
+		newKey, err := apikeygen.New(user.OrgId, "after")
+		require.NoError(t, err)
+		name := "after"
+		cmd := serviceaccounts.UpdateTokenCommand{Name: &name, HashedKey: newKey.HashedKey}
+		require.NoError(t, store.UpdateServiceAccountToken(context.Background(), user.OrgId, user.Id, token.Id, &cmd))
+
+		assert.Equal(t, token.Id, cmd.Result.Id)
+		assert.Equal(t, "after", cmd.Result.Name)
+		assert.Equal(t, newKey.HashedKey, cmd.Result.Key)
+		assert.Nil(t, cmd.Result.Expires)
+	})
+
+	t.Run("should set and remove the expiration", func(t *testing.T) {
+		secondsToLive := int64(3600)
+		cmd := serviceaccounts.UpdateTokenCommand{SecondsToLive: &secondsToLive}
+		require.NoError(t, store.UpdateServiceAccountToken(context.Background(), user.OrgId, user.Id, token.Id, &cmd))
+		require.NotNil(t, cmd.Result.Expires)
+		assert.InDelta(t, time.Now().Add(time.Hour).Unix(), *cmd.Result.Expires, 5)
+		assert.Equal(t, "after", cmd.Result.Name)
+
+		secondsToLive = 0
+		require.NoError(t, store.UpdateServiceAccountToken(context.Background(), user.OrgId, user.Id, token.Id, &cmd))
+		keys, err := store.ListTokens(context.Background(), user.OrgId, user.Id)
+		require.NoError(t, err)
+		for _, key := range keys {
+			if key.Id == token.Id {
+				assert.Nil(t, key.Expires)
+			}
+		}
+	})
+
+	t.Run("should reject a name used by another token", func(t *testing.T) {
+		name := "taken"
+		cmd := serviceaccounts.UpdateTokenCommand{Name: &name, HashedKey: "hash"}
+		err := store.UpdateServiceAccountToken(context.Background(), user.OrgId, user.Id, token.Id, &cmd)
+		require.ErrorIs(t, err, models.ErrDuplicateApiKey)
+	})
+
+	t.Run("should return not found for a token of another service account", func(t *testing.T) {
+		secondsToLive := int64(60)
+		cmd := serviceaccounts.UpdateTokenCommand{SecondsToLive: &secondsToLive}
+		err := store.UpdateServiceAccountToken(context.Background(), user.OrgId, user.Id+1, token.Id, &cmd)
+		require.ErrorIs(t, err, models.ErrApiKeyNotFound)
+	})
+}
+
+func TestStore_ListDormantTokens(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	sa := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-dormant", IsServiceAccount: true})
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	recent := now.Add(-time.Hour)
+
+	addToken := func(name string, orgID int64, created time.Time, lastUsedAt *time.Time) {
+		err := db.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Insert(&models.ApiKey{
+				OrgId:            orgID,
+				Name:             name,
+				Key:              name,
+				Role:             models.ROLE_VIEWER,
+				Created:          created,
+				Updated:          created,
+				LastUsedAt:       lastUsedAt,
+				ServiceAccountId: &sa.Id,
+			})
+			return err
+		})
+		require.NoError(t, err)
+	}
+
+	addToken("used-long-ago", sa.OrgId, old, &old)
+	addToken("used-recently", sa.OrgId, old, &recent)
+	addToken("never-used-old", sa.OrgId, old, nil)
+	addToken("never-used-new", sa.OrgId, recent, nil)
+	addToken("other-org", sa.OrgId+1, old, nil)
+
+	names := func(keys []*models.ApiKey) []string {
+		result := make([]string, 0, len(keys))
+		for _, key := range keys {
+			result = append(result, key.Name)
+		}
+		return result
+	}
+
+	t.Run("should list tokens unused since the cutoff in the org", func(t *testing.T) {
+		keys, err := store.ListDormantTokens(context.Background(), sa.OrgId, now.Add(-24*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"used-long-ago", "never-used-old"}, names(keys))
+	})
+
+	t.Run("should list tokens of every org without an org", func(t *testing.T) {
+		keys, err := store.ListDormantTokens(context.Background(), 0, now.Add(-24*time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"used-long-ago", "never-used-old", "other-org"}, names(keys))
+	})
+
+	t.Run("should include recently used tokens with a later cutoff", func(t *testing.T) {
+		keys, err := store.ListDormantTokens(context.Background(), sa.OrgId, now)
+		require.NoError(t, err)
+		assert.Len(t, keys, 4)
+	})
+}
+
+func TestStore_CountTokensByServiceAccounts(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	tests.SetupMainOrg(t, db)
+	withTokens := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-with-tokens", IsServiceAccount: true})
+	withoutTokens := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-without-tokens", IsServiceAccount: true})
+
+	for i, secondsToLive := range []int64{0, 3600} {
+		keyName := fmt.Sprintf("token-%d", i)
+		key, err := apikeygen.New(withTokens.OrgId, keyName)
+		require.NoError(t, err)
+		cmd := models.AddApiKeyCommand{Name: keyName, Role: "Viewer", OrgId: withTokens.OrgId, Key: key.HashedKey, SecondsToLive: secondsToLive}
+		require.NoError(t, store.AddServiceAccountToken(context.Background(), withTokens.Id, &cmd))
+	}
+
+	counts, err := store.CountTokensByServiceAccounts(context.Background(), 1, []int64{withTokens.Id, withoutTokens.Id})
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]int64{withTokens.Id: 2}, counts)
+
+	counts, err = store.CountTokensByServiceAccounts(context.Background(), 2, []int64{withTokens.Id})
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+}
+
+func TestStore_CountTokensByExpiration(t *testing.T) {
+	db, store := setupTestDatabase(t)
+	tests.SetupMainOrg(t, db)
+	withTokens := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-with-tokens", IsServiceAccount: true})
+	withoutTokens := tests.SetupUserServiceAccount(t, db, tests.TestUser{Login: "sa-without-tokens", IsServiceAccount: true})
+
+	now := time.Now()
+	// 0 never expires
+	for i, expires := range []time.Duration{0, -time.Hour, time.Hour, 30 * 24 * time.Hour} {
+		keyName := fmt.Sprintf("token-%d", i)
+		key, err := apikeygen.New(withTokens.OrgId, keyName)
+		require.NoError(t, err)
+		cmd := models.AddApiKeyCommand{Name: keyName, Role: "Viewer", OrgId: withTokens.OrgId, Key: key.HashedKey}
+		require.NoError(t, store.AddServiceAccountToken(context.Background(), withTokens.Id, &cmd))
+		if expires == 0 {
+			continue
+		}
+		err = db.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Exec("UPDATE api_key SET expires = ? WHERE id = ?", now.Add(expires).Unix(), cmd.Result.Id)
+			return err
+		})
+		require.NoError(t, err)
+	}
+
+	counts, err := store.CountTokensByExpiration(context.Background(), 1, []int64{withTokens.Id, withoutTokens.Id}, now, now.Add(7*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]*serviceaccounts.TokenCounts{withTokens.Id: {Tokens: 4, Expired: 1, ExpiringSoon: 1}}, counts)
+
+	counts, err = store.CountTokensByExpiration(context.Background(), 2, []int64{withTokens.Id}, now, now.Add(7*24*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+}
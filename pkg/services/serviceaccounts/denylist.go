@@ -0,0 +1,25 @@
+package serviceaccounts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// CheckNameAllowed returns an ErrInvalidField when the name is on the configured denylist.
+// Names are compared case-insensitively and entries ending with * deny every name with that prefix.
+func CheckNameAllowed(cfg *setting.Cfg, name string) error {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	for _, denied := range cfg.ServiceAccountNameDenylist {
+		denied = strings.ToLower(denied)
+		if prefix := strings.TrimSuffix(denied, "*"); prefix != denied {
+			if strings.HasPrefix(lower, prefix) {
+				return &ErrInvalidField{Field: "name", Reason: fmt.Sprintf("names starting with %q are reserved", prefix)}
+			}
+		} else if lower == denied {
+			return &ErrInvalidField{Field: "name", Reason: fmt.Sprintf("%q is reserved", name)}
+		}
+	}
+	return nil
+}
@@ -1,7 +1,22 @@
 package serviceaccounts
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-	ErrServiceAccountNotFound = errors.New("Service account not found")
+	ErrServiceAccountNotFound      = errors.New("Service account not found")
+	ErrServiceAccountAlreadyExists = errors.New("Service account already exists")
+	ErrServiceAccountQuotaReached  = errors.New("Service account quota reached")
 )
+
+// ErrInvalidField is returned when a field of a service account form has an invalid value
+type ErrInvalidField struct {
+	Field  string
+	Reason string
+}
+
+func (e *ErrInvalidField) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
@@ -2,8 +2,12 @@ package manager
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
@@ -18,10 +22,21 @@ var (
 	ServiceAccountFeatureToggleNotFound = "FeatureToggle service-accounts not found, try adding it to your custom.ini"
 )
 
+const (
+	// expiredServiceAccountsInterval is how often service accounts past their expiration get disabled.
+	// Their tokens are rejected at authentication as soon as they expire, disabling them is a cleanup.
+	expiredServiceAccountsInterval = time.Minute
+	// dormantTokensInterval is how often tokens unused for longer than the dormancy window get revoked
+	dormantTokensInterval = time.Hour
+)
+
 type ServiceAccountsService struct {
+	cfg      *setting.Cfg
 	store    serviceaccounts.Store
 	features featuremgmt.FeatureToggles
 	log      log.Logger
+	// dormancyWindow is how long a token may go unused before it is revoked, 0 disables the revocation
+	dormancyWindow time.Duration
 }
 
 func ProvideServiceAccountsService(
@@ -32,9 +47,11 @@ func ProvideServiceAccountsService(
 	routeRegister routing.RouteRegister,
 ) (*ServiceAccountsService, error) {
 	s := &ServiceAccountsService{
-		features: features,
-		store:    database.NewServiceAccountsStore(store),
-		log:      log.New("serviceaccounts"),
+		cfg:            cfg,
+		features:       features,
+		store:          database.NewServiceAccountsStore(store),
+		log:            log.New("serviceaccounts"),
+		dormancyWindow: cfg.ServiceAccountTokenDormancyWindow,
 	}
 
 	if features.IsEnabled(featuremgmt.FlagServiceAccounts) {
@@ -49,12 +66,15 @@ func ProvideServiceAccountsService(
 	return s, nil
 }
 
-func (sa *ServiceAccountsService) CreateServiceAccount(ctx context.Context, orgID int64, name string) (*serviceaccounts.ServiceAccountDTO, error) {
+func (sa *ServiceAccountsService) CreateServiceAccount(ctx context.Context, orgID int64, saForm *serviceaccounts.CreateServiceAccountForm) (*serviceaccounts.ServiceAccountDTO, error) {
 	if !sa.features.IsEnabled(featuremgmt.FlagServiceAccounts) {
 		sa.log.Debug(ServiceAccountFeatureToggleNotFound)
 		return nil, nil
 	}
-	return sa.store.CreateServiceAccount(ctx, orgID, name)
+	if err := serviceaccounts.CheckOrgLimit(ctx, sa.cfg, sa.store, orgID); err != nil {
+		return nil, err
+	}
+	return sa.store.CreateServiceAccount(ctx, orgID, saForm)
 }
 
 func (sa *ServiceAccountsService) DeleteServiceAccount(ctx context.Context, orgID, serviceAccountID int64) error {
@@ -64,3 +84,78 @@ func (sa *ServiceAccountsService) DeleteServiceAccount(ctx context.Context, orgI
 	}
 	return sa.store.DeleteServiceAccount(ctx, orgID, serviceAccountID)
 }
+
+// Run periodically disables service accounts that are past their expiration date and,
+// when a dormancy window is configured, revokes the tokens unused for longer than it
+func (sa *ServiceAccountsService) Run(ctx context.Context) error {
+	if !sa.features.IsEnabled(featuremgmt.FlagServiceAccounts) {
+		return nil
+	}
+
+	ticker := time.NewTicker(expiredServiceAccountsInterval)
+	defer ticker.Stop()
+	dormantTicker := time.NewTicker(dormantTokensInterval)
+	defer dormantTicker.Stop()
+
+	sa.disableExpiredServiceAccounts(ctx)
+	sa.revokeDormantTokens(ctx, time.Now())
+	for {
+		select {
+		case <-ticker.C:
+			sa.disableExpiredServiceAccounts(ctx)
+		case <-dormantTicker.C:
+			sa.revokeDormantTokens(ctx, time.Now())
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (sa *ServiceAccountsService) disableExpiredServiceAccounts(ctx context.Context) {
+	disabled, err := sa.store.DisableExpiredServiceAccounts(ctx, time.Now())
+	if err != nil {
+		sa.log.Error("Failed to disable expired service accounts", "error", err)
+		return
+	}
+	if disabled > 0 {
+		sa.log.Info("Disabled expired service accounts", "count", disabled)
+	}
+}
+
+// revokeDormantTokens deletes the tokens that haven't been used within the dormancy window and
+// publishes a ServiceAccountTokenRevoked event for each of them.
+func (sa *ServiceAccountsService) revokeDormantTokens(ctx context.Context, now time.Time) {
+	if sa.dormancyWindow <= 0 {
+		return
+	}
+
+	tokens, err := sa.store.ListDormantTokens(ctx, 0, now.Add(-sa.dormancyWindow))
+	if err != nil {
+		sa.log.Error("Failed to list dormant service account tokens", "error", err)
+		return
+	}
+
+	for _, token := range tokens {
+		if token.ServiceAccountId == nil {
+			continue
+		}
+		if err := sa.store.DeleteServiceAccountToken(ctx, token.OrgId, *token.ServiceAccountId, token.Id); err != nil {
+			sa.log.Error("Failed to revoke dormant service account token", "tokenId", token.Id, "error", err)
+			continue
+		}
+
+		sa.log.Info("Revoked dormant service account token", "orgId", token.OrgId,
+			"serviceAccountId", *token.ServiceAccountId, "tokenId", token.Id, "tokenName", token.Name, "lastUsedAt", token.LastUsedAt)
+		err := bus.Publish(ctx, &events.ServiceAccountTokenRevoked{
+			Timestamp:        now,
+			ID:               token.Id,
+			Name:             token.Name,
+			OrgID:            token.OrgId,
+			ServiceAccountID: *token.ServiceAccountId,
+			Reason:           fmt.Sprintf("unused for more than %s", sa.dormancyWindow),
+		})
+		if err != nil {
+			sa.log.Error("Failed to publish token revocation", "tokenId", token.Id, "error", err)
+		}
+	}
+}
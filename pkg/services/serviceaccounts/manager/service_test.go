@@ -3,10 +3,18 @@ package manager
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts"
+	"github.com/grafana/grafana/pkg/services/serviceaccounts/database"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts/tests"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/setting"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,3 +42,118 @@ func TestProvideServiceAccount_DeleteServiceAccount(t *testing.T) {
 		assert.Len(t, svcMock.Calls.DeleteServiceAccount, 0)
 	})
 }
+
+func TestProvideServiceAccount_DisableExpiredServiceAccounts(t *testing.T) {
+	storeMock := &tests.ServiceAccountsStoreMock{Calls: tests.Calls{}}
+	svc := ServiceAccountsService{
+		features: featuremgmt.WithFeatures("service-accounts", true),
+		store:    storeMock,
+		log:      log.New("serviceaccounts-manager-test"),
+	}
+
+	svc.disableExpiredServiceAccounts(context.Background())
+	assert.Len(t, storeMock.Calls.DisableExpired, 1)
+}
+
+type dormantTokensStoreMock struct {
+	*tests.ServiceAccountsStoreMock
+	tokens []*models.ApiKey
+}
+
+func (s *dormantTokensStoreMock) ListDormantTokens(ctx context.Context, orgID int64, usedBefore time.Time) ([]*models.ApiKey, error) {
+	s.Calls.ListDormantTokens = append(s.Calls.ListDormantTokens, []interface{}{ctx, orgID, usedBefore})
+	return s.tokens, nil
+}
+
+func TestProvideServiceAccount_RevokeDormantTokens(t *testing.T) {
+	saID := int64(3)
+	now := time.Now()
+	newStore := func() *dormantTokensStoreMock {
+		return &dormantTokensStoreMock{
+			ServiceAccountsStoreMock: &tests.ServiceAccountsStoreMock{Calls: tests.Calls{}},
+			tokens: []*models.ApiKey{
+				{Id: 1, OrgId: 1, Name: "first", ServiceAccountId: &saID},
+				{Id: 2, OrgId: 1, Name: "second", ServiceAccountId: &saID},
+			},
+		}
+	}
+
+	t.Run("should revoke dormant tokens and publish an event for each", func(t *testing.T) {
+		t.Cleanup(bus.ClearBusHandlers)
+		var revoked []*events.ServiceAccountTokenRevoked
+		bus.AddEventListener(func(ctx context.Context, e *events.ServiceAccountTokenRevoked) error {
+			revoked = append(revoked, e)
+			return nil
+		})
+
+		storeMock := newStore()
+		svc := ServiceAccountsService{
+			features:       featuremgmt.WithFeatures("service-accounts", true),
+			store:          storeMock,
+			log:            log.New("serviceaccounts-manager-test"),
+			dormancyWindow: 24 * time.Hour,
+		}
+		svc.revokeDormantTokens(context.Background(), now)
+
+		require.Len(t, storeMock.Calls.ListDormantTokens, 1)
+		assert.Equal(t, now.Add(-24*time.Hour), storeMock.Calls.ListDormantTokens[0].([]interface{})[2])
+		require.Len(t, storeMock.Calls.DeleteServiceAccountToken, 2)
+		assert.Equal(t, []interface{}{context.Background(), int64(1), saID, int64(2)}, storeMock.Calls.DeleteServiceAccountToken[1])
+
+		require.Len(t, revoked, 2)
+		assert.Equal(t, &events.ServiceAccountTokenRevoked{
+			Timestamp:        now,
+			ID:               1,
+			Name:             "first",
+			OrgID:            1,
+			ServiceAccountID: saID,
+			Reason:           "unused for more than 24h0m0s",
+		}, revoked[0])
+	})
+
+	t.Run("should do nothing without a dormancy window", func(t *testing.T) {
+		storeMock := newStore()
+		svc := ServiceAccountsService{
+			features: featuremgmt.WithFeatures("service-accounts", true),
+			store:    storeMock,
+			log:      log.New("serviceaccounts-manager-test"),
+		}
+		svc.revokeDormantTokens(context.Background(), now)
+
+		assert.Len(t, storeMock.Calls.ListDormantTokens, 0)
+		assert.Len(t, storeMock.Calls.DeleteServiceAccountToken, 0)
+	})
+}
+
+func TestProvideServiceAccount_CreateServiceAccountLimit(t *testing.T) {
+	sqlStore := sqlstore.InitTestDB(t)
+	tests.SetupMainOrg(t, sqlStore)
+	otherOrg, err := sqlStore.CreateOrgWithMember("other", 0)
+	require.NoError(t, err)
+	cfg := setting.NewCfg()
+	cfg.ServiceAccountsLimit = 2
+	svc := ServiceAccountsService{
+		cfg:      cfg,
+		features: featuremgmt.WithFeatures("service-accounts", true),
+		store:    database.NewServiceAccountsStore(sqlStore),
+		log:      log.New("serviceaccounts-manager-test"),
+	}
+
+	for _, name := range []string{"first", "second"} {
+		_, err := svc.CreateServiceAccount(context.Background(), 1, &serviceaccounts.CreateServiceAccountForm{Name: name})
+		require.NoError(t, err)
+	}
+
+	_, err = svc.CreateServiceAccount(context.Background(), 1, &serviceaccounts.CreateServiceAccountForm{Name: "third"})
+	require.ErrorIs(t, err, serviceaccounts.ErrServiceAccountQuotaReached)
+
+	// the limit is per org
+	_, err = svc.CreateServiceAccount(context.Background(), otherOrg.Id, &serviceaccounts.CreateServiceAccountForm{Name: "third"})
+	require.NoError(t, err)
+
+	t.Run("should not limit with -1", func(t *testing.T) {
+		cfg.ServiceAccountsLimit = -1
+		_, err := svc.CreateServiceAccount(context.Background(), 1, &serviceaccounts.CreateServiceAccountForm{Name: "unlimited"})
+		require.NoError(t, err)
+	})
+}
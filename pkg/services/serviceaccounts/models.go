@@ -1,7 +1,11 @@
 package serviceaccounts
 
 import (
+	"fmt"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
@@ -23,10 +27,55 @@ type ServiceAccount struct {
 	Id int64
 }
 
+type CreateServiceAccountForm struct {
+	Name      string           `json:"name" binding:"Required"`
+	Role      *models.RoleType `json:"role"`
+	ExpiresAt *time.Time       `json:"expiresAt"`
+	// Login is generated from the name when empty, otherwise it has to start with the configured login prefix.
+	Login string `json:"login"`
+}
+
 type UpdateServiceAccountForm struct {
 	Name       *string          `json:"name"`
 	Role       *models.RoleType `json:"role"`
 	IsDisabled *bool            `json:"isDisabled"`
+	ExpiresAt  *time.Time       `json:"expiresAt"`
+}
+
+// MaxNameLength is the longest service account name, in characters
+const MaxNameLength = 190
+
+// Validate checks the name of the service account to create
+func (form CreateServiceAccountForm) Validate() error {
+	return validateName(form.Name)
+}
+
+// Validate checks the new name of the service account, when there is one
+func (form UpdateServiceAccountForm) Validate() error {
+	if form.Name == nil {
+		return nil
+	}
+	return validateName(*form.Name)
+}
+
+// validateName rejects names that are empty, longer than MaxNameLength or contain control
+// characters like newlines, as they can't be stored or displayed properly.
+func validateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return &ErrInvalidField{Field: "name", Reason: "must not be empty"}
+	}
+	if !utf8.ValidString(name) {
+		return &ErrInvalidField{Field: "name", Reason: "must be valid UTF-8"}
+	}
+	if length := utf8.RuneCountInString(name); length > MaxNameLength {
+		return &ErrInvalidField{Field: "name", Reason: fmt.Sprintf("must be at most %d characters long, got %d", MaxNameLength, length)}
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return &ErrInvalidField{Field: "name", Reason: fmt.Sprintf("must not contain control characters, found %U", r)}
+		}
+	}
+	return nil
 }
 
 type ServiceAccountDTO struct {
@@ -35,11 +84,63 @@ type ServiceAccountDTO struct {
 	Login         string          `json:"login" xorm:"login"`
 	OrgId         int64           `json:"orgId" xorm:"org_id"`
 	IsDisabled    bool            `json:"isDisabled" xorm:"is_disabled"`
+	ExpiresAt     *time.Time      `json:"expiresAt" xorm:"expires_at"`
 	Role          string          `json:"role" xorm:"role"`
 	Tokens        int64           `json:"tokens"`
+	ExpiredTokens int64           `json:"expiredTokens"`
+	ActiveTokens  int64           `json:"activeTokens"`
 	AvatarUrl     string          `json:"avatarUrl"`
 	AccessControl map[string]bool `json:"accessControl,omitempty"`
 }
+
+// ConvertedApiKeyDTO links a legacy API key to the service account it was converted to
+type ConvertedApiKeyDTO struct {
+	KeyId            int64 `json:"keyId"`
+	ServiceAccountId int64 `json:"serviceAccountId"`
+}
+
+// ServiceAccountFilter narrows down the service accounts returned by a search
+type ServiceAccountFilter string
+
+const (
+	FilterIncludeAll ServiceAccountFilter = "all"
+	// FilterOnlyExpiredTokens keeps the accounts that have tokens, all of them expired
+	FilterOnlyExpiredTokens ServiceAccountFilter = "expiredTokens"
+	// FilterOnlyDisabled keeps the disabled accounts
+	FilterOnlyDisabled ServiceAccountFilter = "disabled"
+)
+
+// SortField is a field service account searches can be sorted by
+type SortField string
+
+const (
+	SortByName   SortField = "name"
+	SortByTokens SortField = "tokens"
+	// SortByLastUsed sorts by when the service account was last seen
+	SortByLastUsed SortField = "lastUsed"
+)
+
+// SortFields lists the valid sort fields
+var SortFields = []SortField{SortByName, SortByTokens, SortByLastUsed}
+
+// SortOpts orders the results of a search. Without a field they are sorted by email and login.
+type SortOpts struct {
+	Field      SortField
+	Descending bool
+}
+
+// SearchOrgServiceAccountsQuery searches the service accounts of an org
+type SearchOrgServiceAccountsQuery struct {
+	OrgID        int64
+	Query        string
+	Filter       ServiceAccountFilter
+	Role         models.RoleType
+	Page         int
+	Limit        int
+	SortOpts     SortOpts
+	SignedInUser *models.SignedInUser
+}
+
 type SearchServiceAccountsResult struct {
 	TotalCount      int64                `json:"totalCount"`
 	ServiceAccounts []*ServiceAccountDTO `json:"serviceAccounts"`
@@ -47,14 +148,69 @@ type SearchServiceAccountsResult struct {
 	PerPage         int                  `json:"perPage"`
 }
 
+// ServiceAccountsQuotaDTO reports how many service accounts an org uses
+// out of its quota. Limit and Remaining are -1 when there's no limit.
+type ServiceAccountsQuotaDTO struct {
+	Used      int64 `json:"used"`
+	Limit     int64 `json:"limit"`
+	Remaining int64 `json:"remaining"`
+}
+
+// ServiceAccountsStatsDTO breaks the service accounts of an org down by org role,
+// Roles always has an entry for Viewer, Editor and Admin.
+type ServiceAccountsStatsDTO struct {
+	Total    int64                     `json:"total"`
+	Disabled int64                     `json:"disabled"`
+	Roles    map[models.RoleType]int64 `json:"roles"`
+}
+
+// UpdateTokenCommand changes the name or expiration of a service account token, fields left nil are kept.
+type UpdateTokenCommand struct {
+	// Name renames the token. Secrets are hashed with the token name, so HashedKey has to be set along with it.
+	Name      *string
+	HashedKey string
+	// SecondsToLive sets the expiration from now, 0 removes it.
+	SecondsToLive *int64
+
+	Result *models.ApiKey
+}
+
+// ExpiringToken is a service account token along with the account it belongs to
+type ExpiringToken struct {
+	models.ApiKey       `xorm:"extends"`
+	ServiceAccountName  string `xorm:"service_account_name"`
+	ServiceAccountLogin string `xorm:"service_account_login"`
+}
+
+// TokenCounts splits the tokens of a service account by expiration. Tokens expiring soon
+// haven't expired yet, tokens without expiration are in neither group.
+type TokenCounts struct {
+	Tokens       int64
+	Expired      int64
+	ExpiringSoon int64
+}
+
+// ServiceAccountActivityDTO summarizes the recent activity of a service account.
+// LastSeenAt is nil when the account has never authenticated.
+type ServiceAccountActivityDTO struct {
+	Id                 int64      `json:"id"`
+	IsDisabled         bool       `json:"isDisabled"`
+	Tokens             int64      `json:"tokens"`
+	ExpiredTokens      int64      `json:"expiredTokens"`
+	TokensExpiringSoon int64      `json:"tokensExpiringSoon"`
+	LastSeenAt         *time.Time `json:"lastSeenAt"`
+}
+
 type ServiceAccountProfileDTO struct {
 	Id            int64           `json:"id" xorm:"user_id"`
 	Name          string          `json:"name" xorm:"name"`
 	Login         string          `json:"login" xorm:"login"`
 	OrgId         int64           `json:"orgId" xorm:"org_id"`
 	IsDisabled    bool            `json:"isDisabled" xorm:"is_disabled"`
+	ExpiresAt     *time.Time      `json:"expiresAt" xorm:"expires_at"`
 	Created       time.Time       `json:"createdAt" xorm:"created"`
 	Updated       time.Time       `json:"updatedAt" xorm:"updated"`
+	LastSeenAt    time.Time       `json:"lastSeenAt" xorm:"last_seen_at"`
 	AvatarUrl     string          `json:"avatarUrl" xorm:"-"`
 	Role          string          `json:"role" xorm:"role"`
 	Teams         []string        `json:"teams" xorm:"-"`
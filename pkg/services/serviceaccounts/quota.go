@@ -0,0 +1,35 @@
+package serviceaccounts
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// OrgLimit returns the most service accounts an org may have, -1 if there's no limit.
+// It's the lowest of the service accounts limit and, when quotas are enabled, the org quota.
+func OrgLimit(cfg *setting.Cfg) int64 {
+	limit := cfg.ServiceAccountsLimit
+	if cfg.Quota.Enabled && cfg.Quota.Org != nil && cfg.Quota.Org.ServiceAccount >= 0 {
+		if limit < 0 || cfg.Quota.Org.ServiceAccount < limit {
+			limit = cfg.Quota.Org.ServiceAccount
+		}
+	}
+	return limit
+}
+
+// CheckOrgLimit returns ErrServiceAccountQuotaReached when the org can't have another service account.
+func CheckOrgLimit(ctx context.Context, cfg *setting.Cfg, store Store, orgID int64) error {
+	limit := OrgLimit(cfg)
+	if limit < 0 {
+		return nil
+	}
+	count, err := store.CountServiceAccounts(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	if count >= limit {
+		return ErrServiceAccountQuotaReached
+	}
+	return nil
+}
@@ -2,27 +2,51 @@ package serviceaccounts
 
 import (
 	"context"
+	"time"
 
 	"github.com/grafana/grafana/pkg/models"
 )
 
 // this should reflect the api
 type Service interface {
-	CreateServiceAccount(ctx context.Context, orgID int64, name string) (*ServiceAccountDTO, error)
+	CreateServiceAccount(ctx context.Context, orgID int64, saForm *CreateServiceAccountForm) (*ServiceAccountDTO, error)
 	DeleteServiceAccount(ctx context.Context, orgID, serviceAccountID int64) error
 }
 
 type Store interface {
-	CreateServiceAccount(ctx context.Context, orgID int64, name string) (*ServiceAccountDTO, error)
-	SearchOrgServiceAccounts(ctx context.Context, orgID int64, query string, page int, limit int,
-		signedInUser *models.SignedInUser) (*SearchServiceAccountsResult, error)
+	CreateServiceAccount(ctx context.Context, orgID int64, saForm *CreateServiceAccountForm) (*ServiceAccountDTO, error)
+	// ProvisionServiceAccount creates a service account and its tokens, all of them or none
+	ProvisionServiceAccount(ctx context.Context, orgID int64, saForm *CreateServiceAccountForm, tokens []*models.AddApiKeyCommand) (*ServiceAccountDTO, error)
+	SearchOrgServiceAccounts(ctx context.Context, query *SearchOrgServiceAccountsQuery) (*SearchServiceAccountsResult, error)
+	// CountOrgServiceAccounts returns the total of a search, its paging and sorting are ignored
+	CountOrgServiceAccounts(ctx context.Context, query *SearchOrgServiceAccountsQuery) (int64, error)
 	UpdateServiceAccount(ctx context.Context, orgID, serviceAccountID int64,
 		saForm *UpdateServiceAccountForm) (*ServiceAccountProfileDTO, error)
 	RetrieveServiceAccount(ctx context.Context, orgID, serviceAccountID int64) (*ServiceAccountProfileDTO, error)
+	SetServiceAccountDisabled(ctx context.Context, orgID, serviceAccountID int64, disabled bool) error
 	DeleteServiceAccount(ctx context.Context, orgID, serviceAccountID int64) error
 	UpgradeServiceAccounts(ctx context.Context) error
-	ConvertToServiceAccounts(ctx context.Context, keys []int64) error
+	ConvertToServiceAccounts(ctx context.Context, keys []int64) (map[int64]int64, error)
 	ListTokens(ctx context.Context, orgID int64, serviceAccount int64) ([]*models.ApiKey, error)
+	ListTokensForServiceAccounts(ctx context.Context, orgID int64, serviceAccountIDs []int64) ([]*models.ApiKey, error)
+	// CountTokensByServiceAccounts returns the number of tokens of each service account that has some
+	CountTokensByServiceAccounts(ctx context.Context, orgID int64, serviceAccountIDs []int64) (map[int64]int64, error)
+	// CountTokensByExpiration counts the tokens of each service account that has some, split by expiration
+	CountTokensByExpiration(ctx context.Context, orgID int64, serviceAccountIDs []int64, now, soon time.Time) (map[int64]*TokenCounts, error)
+	// ListDormantTokens returns the service account tokens last used, or if never used created, before
+	// the given time. An orgID of 0 lists the tokens of every org.
+	ListDormantTokens(ctx context.Context, orgID int64, usedBefore time.Time) ([]*models.ApiKey, error)
+	// ListExpiringTokens returns the tokens of the org that expire after now, the soonest first
+	ListExpiringTokens(ctx context.Context, orgID int64, now time.Time, limit int) ([]*ExpiringToken, error)
 	DeleteServiceAccountToken(ctx context.Context, orgID, serviceAccountID, tokenID int64) error
+	SetServiceAccountTokenPaused(ctx context.Context, orgID, serviceAccountID, tokenID int64, paused bool) error
+	RotateServiceAccountToken(ctx context.Context, orgID, serviceAccountID, tokenID int64, hashedKey string) error
+	UpdateServiceAccountToken(ctx context.Context, orgID, serviceAccountID, tokenID int64, cmd *UpdateTokenCommand) error
 	AddServiceAccountToken(ctx context.Context, serviceAccountID int64, cmd *models.AddApiKeyCommand) error
+	CountServiceAccounts(ctx context.Context, orgID int64) (int64, error)
+	// GetServiceAccountsStats counts the service accounts of an org by role and disabled state
+	GetServiceAccountsStats(ctx context.Context, orgID int64) (*ServiceAccountsStatsDTO, error)
+	IsServiceAccountNameAvailable(ctx context.Context, name string) (bool, error)
+	GetServiceAccountIDsByName(ctx context.Context, orgID int64, name string) ([]int64, error)
+	DisableExpiredServiceAccounts(ctx context.Context, now time.Time) (int64, error)
 }
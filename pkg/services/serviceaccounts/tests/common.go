@@ -2,13 +2,16 @@ package tests
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/services/accesscontrol"
 	accesscontrolmock "github.com/grafana/grafana/pkg/services/accesscontrol/mock"
 	"github.com/grafana/grafana/pkg/services/serviceaccounts"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/setting"
 	"github.com/stretchr/testify/require"
 )
 
@@ -35,10 +38,27 @@ func SetupUserServiceAccount(t *testing.T, sqlStore *sqlstore.SQLStore, testUser
 	return u1
 }
 
+// SetupMainOrg makes the users created by the test members of the main org, with the role of their
+// TestUser, instead of admins of an org of their own. Tests with several service accounts in an org need it.
+func SetupMainOrg(t *testing.T, sqlStore *sqlstore.SQLStore) {
+	t.Helper()
+	autoAssignOrg, autoAssignOrgID := setting.AutoAssignOrg, setting.AutoAssignOrgId
+	setting.AutoAssignOrg, setting.AutoAssignOrgId = true, 1
+	t.Cleanup(func() {
+		setting.AutoAssignOrg, setting.AutoAssignOrgId = autoAssignOrg, autoAssignOrgID
+	})
+
+	err := sqlStore.GetOrgById(context.Background(), &models.GetOrgByIdQuery{Id: 1})
+	if errors.Is(err, models.ErrOrgNotFound) {
+		_, err = sqlStore.CreateOrgWithMember(sqlstore.MainOrgName, 0)
+	}
+	require.NoError(t, err)
+}
+
 // create mock for serviceaccountservice
 type ServiceAccountMock struct{}
 
-func (s *ServiceAccountMock) CreateServiceAccount(ctx context.Context, orgID int64, name string) (*serviceaccounts.ServiceAccountDTO, error) {
+func (s *ServiceAccountMock) CreateServiceAccount(ctx context.Context, orgID int64, saForm *serviceaccounts.CreateServiceAccountForm) (*serviceaccounts.ServiceAccountDTO, error) {
 	return nil, nil
 }
 
@@ -68,24 +88,45 @@ var _ serviceaccounts.Store = new(ServiceAccountsStoreMock)
 
 type Calls struct {
 	CreateServiceAccount      []interface{}
+	ProvisionServiceAccount   []interface{}
 	RetrieveServiceAccount    []interface{}
 	DeleteServiceAccount      []interface{}
 	UpgradeServiceAccounts    []interface{}
 	ConvertServiceAccounts    []interface{}
 	ListTokens                []interface{}
+	ListTokensForAccounts     []interface{}
+	ListExpiringTokens        []interface{}
+	ListDormantTokens         []interface{}
 	DeleteServiceAccountToken []interface{}
+	SetTokenPaused            []interface{}
+	RotateToken               []interface{}
+	UpdateToken               []interface{}
 	UpdateServiceAccount      []interface{}
+	SetDisabled               []interface{}
 	AddServiceAccountToken    []interface{}
 	SearchOrgServiceAccounts  []interface{}
+	CountOrgServiceAccounts   []interface{}
+	CountServiceAccounts      []interface{}
+	GetStats                  []interface{}
+	CountTokens               []interface{}
+	CountTokensByExpiration   []interface{}
+	NameAvailable             []interface{}
+	IDsByName                 []interface{}
+	DisableExpired            []interface{}
 }
 
 type ServiceAccountsStoreMock struct {
 	Calls Calls
 }
 
-func (s *ServiceAccountsStoreMock) CreateServiceAccount(ctx context.Context, orgID int64, name string) (*serviceaccounts.ServiceAccountDTO, error) {
+func (s *ServiceAccountsStoreMock) CreateServiceAccount(ctx context.Context, orgID int64, saForm *serviceaccounts.CreateServiceAccountForm) (*serviceaccounts.ServiceAccountDTO, error) {
 	// now we can test that the mock has these calls when we call the function
-	s.Calls.CreateServiceAccount = append(s.Calls.CreateServiceAccount, []interface{}{ctx, orgID, name})
+	s.Calls.CreateServiceAccount = append(s.Calls.CreateServiceAccount, []interface{}{ctx, orgID, saForm})
+	return nil, nil
+}
+
+func (s *ServiceAccountsStoreMock) ProvisionServiceAccount(ctx context.Context, orgID int64, saForm *serviceaccounts.CreateServiceAccountForm, tokens []*models.AddApiKeyCommand) (*serviceaccounts.ServiceAccountDTO, error) {
+	s.Calls.ProvisionServiceAccount = append(s.Calls.ProvisionServiceAccount, []interface{}{ctx, orgID, saForm, tokens})
 	return nil, nil
 }
 
@@ -100,9 +141,9 @@ func (s *ServiceAccountsStoreMock) UpgradeServiceAccounts(ctx context.Context) e
 	return nil
 }
 
-func (s *ServiceAccountsStoreMock) ConvertToServiceAccounts(ctx context.Context, keys []int64) error {
+func (s *ServiceAccountsStoreMock) ConvertToServiceAccounts(ctx context.Context, keys []int64) (map[int64]int64, error) {
 	s.Calls.ConvertServiceAccounts = append(s.Calls.ConvertServiceAccounts, []interface{}{ctx})
-	return nil
+	return nil, nil
 }
 
 func (s *ServiceAccountsStoreMock) ListTokens(ctx context.Context, orgID int64, serviceAccount int64) ([]*models.ApiKey, error) {
@@ -110,11 +151,31 @@ func (s *ServiceAccountsStoreMock) ListTokens(ctx context.Context, orgID int64,
 	return nil, nil
 }
 
+func (s *ServiceAccountsStoreMock) ListTokensForServiceAccounts(ctx context.Context, orgID int64, serviceAccountIDs []int64) ([]*models.ApiKey, error) {
+	s.Calls.ListTokensForAccounts = append(s.Calls.ListTokensForAccounts, []interface{}{ctx, orgID, serviceAccountIDs})
+	return nil, nil
+}
+
+func (s *ServiceAccountsStoreMock) ListDormantTokens(ctx context.Context, orgID int64, usedBefore time.Time) ([]*models.ApiKey, error) {
+	s.Calls.ListDormantTokens = append(s.Calls.ListDormantTokens, []interface{}{ctx, orgID, usedBefore})
+	return nil, nil
+}
+
+func (s *ServiceAccountsStoreMock) ListExpiringTokens(ctx context.Context, orgID int64, now time.Time, limit int) ([]*serviceaccounts.ExpiringToken, error) {
+	s.Calls.ListExpiringTokens = append(s.Calls.ListExpiringTokens, []interface{}{ctx, orgID, now, limit})
+	return nil, nil
+}
+
 func (s *ServiceAccountsStoreMock) RetrieveServiceAccount(ctx context.Context, orgID, serviceAccountID int64) (*serviceaccounts.ServiceAccountProfileDTO, error) {
 	s.Calls.RetrieveServiceAccount = append(s.Calls.RetrieveServiceAccount, []interface{}{ctx, orgID, serviceAccountID})
 	return nil, nil
 }
 
+func (s *ServiceAccountsStoreMock) SetServiceAccountDisabled(ctx context.Context, orgID, serviceAccountID int64, disabled bool) error {
+	s.Calls.SetDisabled = append(s.Calls.SetDisabled, []interface{}{ctx, orgID, serviceAccountID, disabled})
+	return nil
+}
+
 func (s *ServiceAccountsStoreMock) UpdateServiceAccount(ctx context.Context,
 	orgID, serviceAccountID int64,
 	saForm *serviceaccounts.UpdateServiceAccountForm) (*serviceaccounts.ServiceAccountProfileDTO, error) {
@@ -123,18 +184,74 @@ func (s *ServiceAccountsStoreMock) UpdateServiceAccount(ctx context.Context,
 	return nil, nil
 }
 
-func (s *ServiceAccountsStoreMock) SearchOrgServiceAccounts(ctx context.Context, orgID int64, query string, page int, limit int,
-	user *models.SignedInUser) (*serviceaccounts.SearchServiceAccountsResult, error) {
-	s.Calls.SearchOrgServiceAccounts = append(s.Calls.SearchOrgServiceAccounts, []interface{}{ctx, orgID, query, page, limit, user})
+func (s *ServiceAccountsStoreMock) SearchOrgServiceAccounts(ctx context.Context,
+	query *serviceaccounts.SearchOrgServiceAccountsQuery) (*serviceaccounts.SearchServiceAccountsResult, error) {
+	s.Calls.SearchOrgServiceAccounts = append(s.Calls.SearchOrgServiceAccounts, []interface{}{ctx, query})
 	return nil, nil
 }
 
+func (s *ServiceAccountsStoreMock) CountOrgServiceAccounts(ctx context.Context, query *serviceaccounts.SearchOrgServiceAccountsQuery) (int64, error) {
+	s.Calls.CountOrgServiceAccounts = append(s.Calls.CountOrgServiceAccounts, []interface{}{ctx, query})
+	return 0, nil
+}
+
 func (s *ServiceAccountsStoreMock) DeleteServiceAccountToken(ctx context.Context, orgID, serviceAccountID, tokenID int64) error {
 	s.Calls.DeleteServiceAccountToken = append(s.Calls.DeleteServiceAccountToken, []interface{}{ctx, orgID, serviceAccountID, tokenID})
 	return nil
 }
 
+func (s *ServiceAccountsStoreMock) SetServiceAccountTokenPaused(ctx context.Context, orgID, serviceAccountID, tokenID int64, paused bool) error {
+	s.Calls.SetTokenPaused = append(s.Calls.SetTokenPaused, []interface{}{ctx, orgID, serviceAccountID, tokenID, paused})
+	return nil
+}
+
+func (s *ServiceAccountsStoreMock) RotateServiceAccountToken(ctx context.Context, orgID, serviceAccountID, tokenID int64, hashedKey string) error {
+	s.Calls.RotateToken = append(s.Calls.RotateToken, []interface{}{ctx, orgID, serviceAccountID, tokenID, hashedKey})
+	return nil
+}
+
+func (s *ServiceAccountsStoreMock) UpdateServiceAccountToken(ctx context.Context, orgID, serviceAccountID, tokenID int64, cmd *serviceaccounts.UpdateTokenCommand) error {
+	s.Calls.UpdateToken = append(s.Calls.UpdateToken, []interface{}{ctx, orgID, serviceAccountID, tokenID, cmd})
+	return nil
+}
+
 func (s *ServiceAccountsStoreMock) AddServiceAccountToken(ctx context.Context, serviceAccountID int64, cmd *models.AddApiKeyCommand) error {
 	s.Calls.AddServiceAccountToken = append(s.Calls.AddServiceAccountToken, []interface{}{ctx, cmd})
 	return nil
 }
+
+func (s *ServiceAccountsStoreMock) CountServiceAccounts(ctx context.Context, orgID int64) (int64, error) {
+	s.Calls.CountServiceAccounts = append(s.Calls.CountServiceAccounts, []interface{}{ctx, orgID})
+	return 0, nil
+}
+
+func (s *ServiceAccountsStoreMock) CountTokensByServiceAccounts(ctx context.Context, orgID int64, serviceAccountIDs []int64) (map[int64]int64, error) {
+	s.Calls.CountTokens = append(s.Calls.CountTokens, []interface{}{ctx, orgID, serviceAccountIDs})
+	return map[int64]int64{}, nil
+}
+
+func (s *ServiceAccountsStoreMock) CountTokensByExpiration(ctx context.Context, orgID int64, serviceAccountIDs []int64,
+	now, soon time.Time) (map[int64]*serviceaccounts.TokenCounts, error) {
+	s.Calls.CountTokensByExpiration = append(s.Calls.CountTokensByExpiration, []interface{}{ctx, orgID, serviceAccountIDs, now, soon})
+	return map[int64]*serviceaccounts.TokenCounts{}, nil
+}
+
+func (s *ServiceAccountsStoreMock) GetServiceAccountsStats(ctx context.Context, orgID int64) (*serviceaccounts.ServiceAccountsStatsDTO, error) {
+	s.Calls.GetStats = append(s.Calls.GetStats, []interface{}{ctx, orgID})
+	return &serviceaccounts.ServiceAccountsStatsDTO{}, nil
+}
+
+func (s *ServiceAccountsStoreMock) IsServiceAccountNameAvailable(ctx context.Context, name string) (bool, error) {
+	s.Calls.NameAvailable = append(s.Calls.NameAvailable, []interface{}{ctx, name})
+	return true, nil
+}
+
+func (s *ServiceAccountsStoreMock) GetServiceAccountIDsByName(ctx context.Context, orgID int64, name string) ([]int64, error) {
+	s.Calls.IDsByName = append(s.Calls.IDsByName, []interface{}{ctx, orgID, name})
+	return nil, nil
+}
+
+func (s *ServiceAccountsStoreMock) DisableExpiredServiceAccounts(ctx context.Context, now time.Time) (int64, error) {
+	s.Calls.DisableExpired = append(s.Calls.DisableExpired, []interface{}{ctx, now})
+	return 0, nil
+}
@@ -16,6 +16,7 @@ func (ss *SQLStore) addAPIKeysQueryAndCommandHandlers() {
 	bus.AddHandler("sql", ss.GetApiKeyByName)
 	bus.AddHandler("sql", ss.DeleteApiKey)
 	bus.AddHandler("sql", ss.AddAPIKey)
+	bus.AddHandler("sql", ss.UpdateApiKeyLastUsed)
 }
 
 // GetAPIKeys queries the database based
@@ -113,6 +114,23 @@ func (ss *SQLStore) AddAPIKey(ctx context.Context, cmd *models.AddApiKeyCommand)
 	})
 }
 
+// UpdateApiKeyLastUsed records when an API key last authenticated a request.
+func (ss *SQLStore) UpdateApiKeyLastUsed(ctx context.Context, cmd *models.UpdateApiKeyLastUsedCommand) error {
+	return ss.WithDbSession(ctx, func(sess *DBSession) error {
+		result, err := sess.Exec("UPDATE api_key SET last_used_at=? WHERE id=?", cmd.LastUsedAt, cmd.Id)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		} else if n == 0 {
+			return models.ErrApiKeyNotFound
+		}
+		return nil
+	})
+}
+
 func (ss *SQLStore) GetApiKeyById(ctx context.Context, query *models.GetApiKeyByIdQuery) error {
 	return ss.WithDbSession(ctx, func(sess *DBSession) error {
 		var apikey models.ApiKey
@@ -117,6 +117,24 @@ func TestApiKeyDataAccess(t *testing.T) {
 			}
 			assert.True(t, found)
 		})
+
+		t.Run("Record the last use of a key", func(t *testing.T) {
+			cmd := models.AddApiKeyCommand{OrgId: 1, Name: "used", Key: "asd4"}
+			err := ss.AddAPIKey(context.Background(), &cmd)
+			assert.Nil(t, err)
+			assert.Nil(t, cmd.Result.LastUsedAt)
+
+			lastUsedAt := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+			err = ss.UpdateApiKeyLastUsed(context.Background(), &models.UpdateApiKeyLastUsedCommand{Id: cmd.Result.Id, LastUsedAt: lastUsedAt})
+			assert.Nil(t, err)
+
+			query := models.GetApiKeyByNameQuery{KeyName: "used", OrgId: 1}
+			err = ss.GetApiKeyByName(context.Background(), &query)
+			assert.Nil(t, err)
+			if assert.NotNil(t, query.Result.LastUsedAt) {
+				assert.True(t, lastUsedAt.Equal(*query.Result.LastUsedAt))
+			}
+		})
 	})
 }
 
@@ -134,6 +152,13 @@ func TestApiKeyErrors(t *testing.T) {
 			assert.EqualError(t, err, models.ErrApiKeyNotFound.Error())
 		})
 
+		t.Run("Update last use of non-existing key should return error", func(t *testing.T) {
+			cmd := models.UpdateApiKeyLastUsedCommand{Id: 1, LastUsedAt: time.Now()}
+			err := ss.UpdateApiKeyLastUsed(context.Background(), &cmd)
+
+			assert.EqualError(t, err, models.ErrApiKeyNotFound.Error())
+		})
+
 		t.Run("Testing API Duplicate Key Errors", func(t *testing.T) {
 			t.Run("Given saved api key", func(t *testing.T) {
 				cmd := models.AddApiKeyCommand{OrgId: 0, Name: "duplicate", Key: "asd"}
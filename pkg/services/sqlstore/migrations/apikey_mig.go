@@ -91,4 +91,20 @@ func addApiKeyMigrations(mg *Migrator) {
 
 	mg.AddMigration("set service account foreign key to nil if 0", NewRawSQLMigration(
 		"UPDATE api_key SET service_account_id = NULL WHERE service_account_id = 0;"))
+
+	mg.AddMigration("Add ip_allowlist to api_key table", NewAddColumnMigration(apiKeyV2, &Column{
+		Name: "ip_allowlist", Type: DB_Text, Nullable: true,
+	}))
+
+	mg.AddMigration("Add is_paused to api_key table", NewAddColumnMigration(apiKeyV2, &Column{
+		Name: "is_paused", Type: DB_Bool, Nullable: false, Default: "0",
+	}))
+
+	mg.AddMigration("Add last_used_at to api_key table", NewAddColumnMigration(apiKeyV2, &Column{
+		Name: "last_used_at", Type: DB_DateTime, Nullable: true,
+	}))
+
+	mg.AddMigration("Add is_read_only to api_key table", NewAddColumnMigration(apiKeyV2, &Column{
+		Name: "is_read_only", Type: DB_Bool, Nullable: false, Default: "0",
+	}))
 }
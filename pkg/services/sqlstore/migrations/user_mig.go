@@ -139,6 +139,11 @@ func addUserMigrations(mg *Migrator) {
 			SQLite(migSQLITEisServiceAccountNullable).
 			Postgres("ALTER TABLE `user` ALTER COLUMN is_service_account DROP NOT NULL;").
 			Mysql("ALTER TABLE user MODIFY is_service_account BOOLEAN DEFAULT 0;"))
+
+	// Service accounts can be provisioned for a limited time and get disabled once expired
+	mg.AddMigration("Add expires_at column to user", NewAddColumnMigration(userV2, &Column{
+		Name: "expires_at", Type: DB_DateTime, Nullable: true,
+	}))
 }
 
 const migSQLITEisServiceAccountNullable = `ALTER TABLE user ADD COLUMN tmp_service_account BOOLEAN DEFAULT 0;
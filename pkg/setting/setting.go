@@ -344,6 +344,8 @@ type Cfg struct {
 	EditorsCanAdmin bool
 
 	ApiKeyMaxSecondsToLive int64
+	// ApiKeyExpiredStatusCode is the HTTP status returned when an expired API key is used.
+	ApiKeyExpiredStatusCode int
 
 	// Check if a feature toggle is enabled
 	// @deprecated
@@ -400,6 +402,22 @@ type Cfg struct {
 	AutoAssignOrgRole          string
 	OAuthSkipOrgRoleUpdateSync bool
 
+	// ServiceAccountDefaultRole is the org role given to service accounts created without one.
+	ServiceAccountDefaultRole string
+	// ServiceAccountLoginPrefix is prepended to the logins of service accounts.
+	ServiceAccountLoginPrefix string
+	// ServiceAccountTokenDormancyWindow is how long a service account token may go unused before it
+	// is revoked. 0 disables the revocation.
+	ServiceAccountTokenDormancyWindow time.Duration
+	// ServiceAccountsLimit is the most service accounts an org may have, -1 for no limit.
+	ServiceAccountsLimit int64
+	// ServiceAccountTokensPerAccountLimit is the most tokens a service account may have, -1 for no limit.
+	// Expired tokens count towards it until they're deleted.
+	ServiceAccountTokensPerAccountLimit int64
+	// ServiceAccountNameDenylist lists names service accounts can't have, compared case-insensitively.
+	// Entries ending with * deny every name with that prefix.
+	ServiceAccountNameDenylist []string
+
 	// ExpressionsEnabled specifies whether expressions are enabled.
 	ExpressionsEnabled bool
 
@@ -818,6 +836,9 @@ func NewCfg() *Cfg {
 	return &Cfg{
 		Logger: log.New("settings"),
 		Raw:    ini.Empty(),
+		// unlimited unless configured, zero values would forbid any service account or token
+		ServiceAccountsLimit:                -1,
+		ServiceAccountTokensPerAccountLimit: -1,
 	}
 }
 
@@ -1243,6 +1264,11 @@ func readAuthSettings(iniFile *ini.File, cfg *Cfg) (err error) {
 	}
 
 	cfg.ApiKeyMaxSecondsToLive = auth.Key("api_key_max_seconds_to_live").MustInt64(-1)
+	cfg.ApiKeyExpiredStatusCode = auth.Key("api_key_expired_status_code").MustInt(http.StatusUnauthorized)
+	if cfg.ApiKeyExpiredStatusCode < 400 || cfg.ApiKeyExpiredStatusCode > 499 {
+		cfg.Logger.Warn("api_key_expired_status_code must be a 4xx status code, falling back to 401", "value", cfg.ApiKeyExpiredStatusCode)
+		cfg.ApiKeyExpiredStatusCode = http.StatusUnauthorized
+	}
 
 	cfg.TokenRotationIntervalMinutes = auth.Key("token_rotation_interval_minutes").MustInt(10)
 	if cfg.TokenRotationIntervalMinutes < 2 {
@@ -1333,6 +1359,12 @@ func readUserSettings(iniFile *ini.File, cfg *Cfg) error {
 	AutoAssignOrgId = cfg.AutoAssignOrgId
 	cfg.AutoAssignOrgRole = users.Key("auto_assign_org_role").In("Editor", []string{"Editor", "Admin", "Viewer"})
 	AutoAssignOrgRole = cfg.AutoAssignOrgRole
+	cfg.ServiceAccountDefaultRole = users.Key("service_account_default_role").In("Viewer", []string{"Editor", "Admin", "Viewer"})
+	cfg.ServiceAccountLoginPrefix = valueAsString(users, "service_account_login_prefix", "sa-")
+	cfg.ServiceAccountTokenDormancyWindow = users.Key("service_account_token_dormancy_window").MustDuration(0)
+	cfg.ServiceAccountsLimit = users.Key("service_accounts_limit").MustInt64(-1)
+	cfg.ServiceAccountTokensPerAccountLimit = users.Key("service_account_tokens_per_account_limit").MustInt64(-1)
+	cfg.ServiceAccountNameDenylist = util.SplitString(users.Key("service_account_name_denylist").String())
 	VerifyEmailEnabled = users.Key("verify_email_enabled").MustBool(false)
 
 	LoginHint = valueAsString(users, "login_hint", "")
@@ -1,6 +1,11 @@
 package setting
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/util"
+)
 
 const (
 	AzurePublic       = "AzureCloud"
@@ -13,6 +18,29 @@ type AzureSettings struct {
 	Cloud                   string
 	ManagedIdentityEnabled  bool
 	ManagedIdentityClientId string
+
+	// ResourceGraphAllowedOrgs restricts Azure Resource Graph queries to the listed orgs.
+	// An empty list allows every org.
+	ResourceGraphAllowedOrgs []int64
+
+	// ResourceGraphDeprecatedKQL lists KQL tokens that Azure has deprecated.
+	// Queries using one of them get an informational notice.
+	ResourceGraphDeprecatedKQL []string
+
+	// ResourceGraphMaxTagColumns caps the number of tag columns added to
+	// Azure Resource Graph results for queries with includeTags.
+	ResourceGraphMaxTagColumns int
+
+	// ResourceGraphMaxRetries is how often a failed Azure Resource Graph request is retried.
+	ResourceGraphMaxRetries int
+
+	// ResourceGraphMaxSubscriptions is how many subscriptions a single
+	// Azure Resource Graph query may target.
+	ResourceGraphMaxSubscriptions int
+
+	// ResourceGraphAllowURLOverride lets datasources point Azure Resource Graph
+	// queries at another endpoint, such as a mock for integration tests.
+	ResourceGraphAllowURLOverride bool
 }
 
 func (cfg *Cfg) readAzureSettings() {
@@ -25,6 +53,22 @@ func (cfg *Cfg) readAzureSettings() {
 	// Managed Identity
 	cfg.Azure.ManagedIdentityEnabled = azureSection.Key("managed_identity_enabled").MustBool(false)
 	cfg.Azure.ManagedIdentityClientId = azureSection.Key("managed_identity_client_id").String()
+
+	// Resource Graph
+	cfg.Azure.ResourceGraphAllowedOrgs = []int64{}
+	for _, orgID := range util.SplitString(azureSection.Key("resource_graph_allowed_orgs").String()) {
+		id, err := strconv.ParseInt(orgID, 10, 64)
+		if err != nil {
+			cfg.Logger.Warn("Ignoring invalid org ID in resource_graph_allowed_orgs", "orgId", orgID)
+			continue
+		}
+		cfg.Azure.ResourceGraphAllowedOrgs = append(cfg.Azure.ResourceGraphAllowedOrgs, id)
+	}
+	cfg.Azure.ResourceGraphDeprecatedKQL = util.SplitString(azureSection.Key("resource_graph_deprecated_kql").String())
+	cfg.Azure.ResourceGraphMaxTagColumns = azureSection.Key("resource_graph_max_tag_columns").MustInt(50)
+	cfg.Azure.ResourceGraphMaxRetries = azureSection.Key("resource_graph_max_retries").MustInt(2)
+	cfg.Azure.ResourceGraphMaxSubscriptions = azureSection.Key("resource_graph_max_subscriptions").MustInt(1000)
+	cfg.Azure.ResourceGraphAllowURLOverride = azureSection.Key("resource_graph_allow_url_override").MustBool(false)
 }
 
 func normalizeAzureCloud(cloudName string) string {
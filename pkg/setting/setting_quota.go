@@ -10,6 +10,9 @@ type OrgQuota struct {
 	Dashboard  int64 `target:"dashboard"`
 	ApiKey     int64 `target:"api_key"`
 	AlertRule  int64 `target:"alert_rule"`
+	// ServiceAccount isn't backed by its own table, so it's checked by the
+	// service accounts service and API rather than by the quota service.
+	ServiceAccount int64 `target:"-"`
 }
 
 type UserQuota struct {
@@ -79,6 +82,8 @@ func (cfg *Cfg) readQuotaSettings() {
 		Dashboard:  quota.Key("org_dashboard").MustInt64(10),
 		ApiKey:     quota.Key("org_api_key").MustInt64(10),
 		AlertRule:  alertOrgQuota,
+
+		ServiceAccount: quota.Key("org_service_account").MustInt64(-1),
 	}
 
 	// per User limits
@@ -7,17 +7,17 @@ var (
 )
 
 func Warn(msg string, args ...interface{}) {
-	azlog.Warn(msg, args)
+	azlog.Warn(msg, args...)
 }
 
 func Debug(msg string, args ...interface{}) {
-	azlog.Debug(msg, args)
+	azlog.Debug(msg, args...)
 }
 
 func Error(msg string, args ...interface{}) {
-	azlog.Error(msg, args)
+	azlog.Error(msg, args...)
 }
 
 func Info(msg string, args ...interface{}) {
-	azlog.Info(msg, args)
+	azlog.Info(msg, args...)
 }
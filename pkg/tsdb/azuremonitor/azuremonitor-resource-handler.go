@@ -1,6 +1,7 @@
 package azuremonitor
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/azlog"
 	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/deprecated"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/resourcegraph"
 	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
 )
 
@@ -118,6 +120,50 @@ func (s *Service) handleResourceReq(subDataSource string) func(rw http.ResponseW
 	}
 }
 
+// handleResourceGraphEstimate runs a bounded probe of an Azure Resource Graph query and
+// returns an estimate of the size of its result.
+func (s *Service) handleResourceGraphEstimate(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeResponse(rw, http.StatusMethodNotAllowed, "only POST is supported")
+		return
+	}
+	estimator, ok := s.executors[azureResourceGraph].(*resourcegraph.AzureResourceGraphDatasource)
+	if !ok {
+		writeResponse(rw, http.StatusNotFound, "Azure Resource Graph is not available")
+		return
+	}
+
+	estimateReq := resourcegraph.EstimateRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&estimateReq); err != nil {
+		writeResponse(rw, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	dsInfo, err := s.getDataSourceFromHTTPReq(req)
+	if err != nil {
+		writeResponse(rw, http.StatusInternalServerError, fmt.Sprintf("unexpected error %v", err))
+		return
+	}
+	dsInfo.OrgID = httpadapter.PluginConfigFromContext(req.Context()).OrgID
+	service := dsInfo.Services[azureResourceGraph]
+
+	estimate, err := estimator.Estimate(req.Context(), estimateReq, dsInfo, service.HTTPClient, service.URL)
+	if err != nil {
+		writeResponse(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	body, err := json.Marshal(estimate)
+	if err != nil {
+		writeResponse(rw, http.StatusInternalServerError, fmt.Sprintf("unexpected error %v", err))
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if _, err := rw.Write(body); err != nil {
+		azlog.Error("Unable to write HTTP response", "error", err)
+	}
+}
+
 // newResourceMux provides route definitions shared with the frontend.
 // Check: /public/app/plugins/datasource/grafana-azure-monitor-datasource/utils/common.ts <routeNames>
 func (s *Service) newResourceMux() *http.ServeMux {
@@ -125,6 +171,7 @@ func (s *Service) newResourceMux() *http.ServeMux {
 	mux.HandleFunc("/azuremonitor/", s.handleResourceReq(azureMonitor))
 	mux.HandleFunc("/loganalytics/", s.handleResourceReq(azureLogAnalytics))
 	mux.HandleFunc("/resourcegraph/", s.handleResourceReq(azureResourceGraph))
+	mux.HandleFunc("/resourcegraph-estimate", s.handleResourceGraphEstimate)
 	// Remove with Grafana 9
 	mux.HandleFunc("/appinsights/", s.handleResourceReq(deprecated.AppInsights))
 	return mux
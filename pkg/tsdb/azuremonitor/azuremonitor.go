@@ -25,9 +25,17 @@ import (
 func ProvideService(cfg *setting.Cfg, httpClientProvider *httpclient.Provider, tracer tracing.Tracer) *Service {
 	proxy := &httpServiceProxy{}
 	executors := map[string]azDatasourceExecutor{
-		azureMonitor:       &metrics.AzureMonitorDatasource{Proxy: proxy},
-		azureLogAnalytics:  &loganalytics.AzureLogAnalyticsDatasource{Proxy: proxy},
-		azureResourceGraph: &resourcegraph.AzureResourceGraphDatasource{Proxy: proxy},
+		azureMonitor:      &metrics.AzureMonitorDatasource{Proxy: proxy},
+		azureLogAnalytics: &loganalytics.AzureLogAnalyticsDatasource{Proxy: proxy},
+		azureResourceGraph: &resourcegraph.AzureResourceGraphDatasource{
+			Proxy:            proxy,
+			AllowedOrgs:      cfg.Azure.ResourceGraphAllowedOrgs,
+			DeprecatedKQL:    cfg.Azure.ResourceGraphDeprecatedKQL,
+			MaxTagColumns:    cfg.Azure.ResourceGraphMaxTagColumns,
+			MaxRetries:       cfg.Azure.ResourceGraphMaxRetries,
+			MaxSubscriptions: cfg.Azure.ResourceGraphMaxSubscriptions,
+			AllowURLOverride: cfg.Azure.ResourceGraphAllowURLOverride,
+		},
 	}
 
 	// Insights Analytics and Application Insights were deprecated in Grafana 8.x and
@@ -116,6 +124,8 @@ func NewInstanceSettings(cfg *setting.Cfg, clientProvider httpclient.Provider, e
 			JSONData:                jsonDataObj,
 			DecryptedSecureJSONData: settings.DecryptedSecureJSONData,
 			DatasourceID:            settings.ID,
+			DatasourceUID:           settings.UID,
+			DatasourceName:          settings.Name,
 			Routes:                  routes[cloud],
 			Services:                map[string]types.DatasourceService{},
 		}
@@ -59,6 +59,8 @@ func TestNewInstanceSettings(t *testing.T) {
 				JSONData:                []byte(`{"azureAuthType":"msi"}`),
 				DecryptedSecureJSONData: map[string]string{"key": "value"},
 				ID:                      40,
+				UID:                     "azure-monitor-uid",
+				Name:                    "Azure Monitor",
 			},
 			expectedModel: types.DatasourceInfo{
 				Cloud:                   setting.AzurePublic,
@@ -67,6 +69,8 @@ func TestNewInstanceSettings(t *testing.T) {
 				Routes:                  routes[setting.AzurePublic],
 				JSONData:                map[string]interface{}{"azureAuthType": "msi"},
 				DatasourceID:            40,
+				DatasourceUID:           "azure-monitor-uid",
+				DatasourceName:          "Azure Monitor",
 				DecryptedSecureJSONData: map[string]string{"key": "value"},
 				Services:                map[string]types.DatasourceService{},
 			},
@@ -14,10 +14,19 @@ import (
 	"github.com/grafana/grafana/pkg/tsdb/legacydata/interval"
 )
 
-const rsIdentifier = `__(timeFilter|timeFrom|timeTo|interval|contains|escapeMulti)`
-const sExpr = `\$` + rsIdentifier + `(?:\(([^\)]*)\))?`
+const rsIdentifier = `__(timeFilter|timeFrom|timeTo|interval|contains|escapeMulti|regex|in)`
+const sExpr = `\$` + rsIdentifier + `\b(?:\(([^\)]*)\))?`
 const escapeMultiExpr = `\$__escapeMulti\(('.*')\)`
 
+// regexExpr matches $__regex(column, 'pattern'). The pattern ends at the first quote followed by the closing
+// parenthesis, so that it can contain quotes, commas and parentheses.
+const regexExpr = `\$__regex\(\s*([^,\)]+?)\s*,\s*'(.*?)'\s*\)`
+
+var (
+	macroNameRegex  = regexp.MustCompile(`\$(__\w+)`)
+	knownMacroRegex = regexp.MustCompile(`^` + rsIdentifier + `$`)
+)
+
 type kqlMacroEngine struct {
 	timeRange backend.TimeRange
 	query     backend.DataQuery
@@ -30,6 +39,8 @@ type kqlMacroEngine struct {
 //   - $__to -> datetime(2018-06-05T20:09:58.907Z)
 //   - $__interval -> 5m
 //   - $__contains(col, 'val1','val2') -> col in ('val1', 'val2')
+//   - $__in(col, 'val1','val2') -> col in ('val1', 'val2')
+//   - $__regex(col, 'vm-.*') -> col matches regex @'(?i)vm-.*'
 //   - $__escapeMulti('\\vm\eth0\Total','\\vm\eth2\Total') -> @'\\vm\eth0\Total',@'\\vm\eth2\Total'
 
 // KqlInterpolate interpolates macros for Kusto Query Language (KQL) queries
@@ -43,11 +54,23 @@ func KqlInterpolate(query backend.DataQuery, dsInfo types.DatasourceInfo, kql st
 	return engine.Interpolate(query, dsInfo, kql, defaultTimeFieldForAllDatasources)
 }
 
+// UnknownMacros returns the names of the $__ macros in kql that KqlInterpolate doesn't support.
+func UnknownMacros(kql string) []string {
+	unknown := []string{}
+	for _, groups := range macroNameRegex.FindAllStringSubmatch(kql, -1) {
+		if !knownMacroRegex.MatchString(groups[1]) {
+			unknown = append(unknown, "$"+groups[1])
+		}
+	}
+	return unknown
+}
+
 func (m *kqlMacroEngine) Interpolate(query backend.DataQuery, dsInfo types.DatasourceInfo, kql string, defaultTimeField string) (string, error) {
 	m.timeRange = query.TimeRange
 	m.query = query
 	rExp, _ := regexp.Compile(sExpr)
 	escapeMultiRegex, _ := regexp.Compile(escapeMultiExpr)
+	regexMacroRegex, _ := regexp.Compile(regexExpr)
 
 	var macroError error
 
@@ -63,6 +86,14 @@ func (m *kqlMacroEngine) Interpolate(query backend.DataQuery, dsInfo types.Datas
 		return fmt.Sprintf("@%s", expr)
 	})
 
+	// the regex macro is expanded before the others too, its pattern may contain commas and parentheses.
+	// The pattern becomes a verbatim string, in which quotes are escaped by doubling them, and matches
+	// case-insensitively.
+	kql = m.ReplaceAllStringSubmatchFunc(regexMacroRegex, kql, func(groups []string) string {
+		pattern := strings.ReplaceAll(groups[2], "'", "''")
+		return fmt.Sprintf("['%s'] matches regex @'(?i)%s'", groups[1], pattern)
+	})
+
 	// second pass for all the other macros
 	kql = m.ReplaceAllStringSubmatchFunc(rExp, kql, func(groups []string) string {
 		args := []string{}
@@ -126,7 +157,7 @@ func (m *kqlMacroEngine) evaluateMacro(name string, defaultTimeField string, arg
 			it = time.Millisecond * time.Duration(m.query.Interval.Milliseconds())
 		}
 		return fmt.Sprintf("%dms", int(it/time.Millisecond)), nil
-	case "contains":
+	case "contains", "in":
 		if len(args) < 2 || args[0] == "" || args[1] == "" {
 			return "", fmt.Errorf("macro %v needs colName and variableSet", name)
 		}
@@ -139,6 +170,8 @@ func (m *kqlMacroEngine) evaluateMacro(name string, defaultTimeField string, arg
 		return fmt.Sprintf("['%s'] in (%s)", args[0], expression), nil
 	case "escapeMulti":
 		return "", fmt.Errorf("escapeMulti macro not formatted correctly")
+	case "regex":
+		return "", fmt.Errorf("regex macro not formatted correctly, expected $__regex(column, 'pattern')")
 	default:
 		return "", fmt.Errorf("unknown macro %q", name)
 	}
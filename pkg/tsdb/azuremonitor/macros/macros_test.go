@@ -60,6 +60,62 @@ func TestAzureLogAnalyticsMacros(t *testing.T) {
 			expected: "1 == 1",
 			Err:      require.NoError,
 		},
+		{
+			name:     "$__in macro should build in clause",
+			query:    backend.DataQuery{},
+			kql:      "$__in(col, 'val1', 'val2','val3')",
+			expected: "['col'] in ('val1','val2','val3')",
+			Err:      require.NoError,
+		},
+		{
+			name:     "$__in macro without values should fail",
+			query:    backend.DataQuery{},
+			kql:      "$__in(col)",
+			expected: "",
+			Err:      require.Error,
+		},
+		{
+			name:     "macros starting like $__in should be ignored",
+			query:    backend.DataQuery{},
+			kql:      "$__index(col)",
+			expected: "$__index(col)",
+			Err:      require.NoError,
+		},
+		{
+			name:     "$__regex macro should build a case-insensitive regex predicate",
+			query:    backend.DataQuery{},
+			kql:      "resources | where $__regex(name, '^vm-[0-9]+$')",
+			expected: "resources | where ['name'] matches regex @'(?i)^vm-[0-9]+$'",
+			Err:      require.NoError,
+		},
+		{
+			name:     "$__regex macro should escape the quotes of the pattern",
+			query:    backend.DataQuery{},
+			kql:      "$__regex(owner, 'o'brien|\"admin\"')",
+			expected: "['owner'] matches regex @'(?i)o''brien|\"admin\"'",
+			Err:      require.NoError,
+		},
+		{
+			name:     "$__regex macro should keep commas and parentheses of the pattern",
+			query:    backend.DataQuery{},
+			kql:      "$__regex(type, '(vm|disk){1,2}') and $__regex(location, 'east.*')",
+			expected: "['type'] matches regex @'(?i)(vm|disk){1,2}' and ['location'] matches regex @'(?i)east.*'",
+			Err:      require.NoError,
+		},
+		{
+			name:     "$__regex macro with a column name containing special characters should bracket-quote it",
+			query:    backend.DataQuery{},
+			kql:      "$__regex(properties-name, 'prod')",
+			expected: "['properties-name'] matches regex @'(?i)prod'",
+			Err:      require.NoError,
+		},
+		{
+			name:     "$__regex macro with a pattern not wrapped in single quotes should fail",
+			query:    backend.DataQuery{},
+			kql:      "$__regex(name, prod)",
+			expected: "",
+			Err:      require.Error,
+		},
 		{
 			name:     "$__timeFilter has no column parameter should use default time field",
 			query:    backend.DataQuery{TimeRange: timeRange},
@@ -134,3 +190,9 @@ func TestAzureLogAnalyticsMacros(t *testing.T) {
 		})
 	}
 }
+
+func TestUnknownMacros(t *testing.T) {
+	require.Empty(t, UnknownMacros("resources | where $__contains(name, 'a') and $__timeFilter(createdTime)"))
+	require.Empty(t, UnknownMacros("resources | where $__in(type, 'vm') and $__regex(name, 'prod.*')"))
+	require.Equal(t, []string{"$__timefilter", "$__foo"}, UnknownMacros("resources | where $__timefilter(createdTime) and $__foo"))
+}
@@ -0,0 +1,58 @@
+package resourcegraph
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// aggregationPrefixes are the prefixes KQL names the result columns of summarize
+// aggregations with when they aren't named explicitly, e.g. avg_x for avg(x) or count_ for count().
+var aggregationPrefixes = []string{
+	"avg_", "avgif_", "sum_", "sumif_", "count_", "countif_", "dcount_", "dcountif_",
+	"min_", "max_", "stdev_", "variance_", "percentile_",
+}
+
+// isAggregationColumn reports whether name is the default name of an aggregation result column.
+func isAggregationColumn(name string) bool {
+	for _, prefix := range aggregationPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// convertAggregationColumns replaces aggregation result columns that Azure returned as
+// strings by float64 columns, so that panels like gauges can use them without a
+// transformation. Columns with values that aren't numbers are left untouched.
+func convertAggregationColumns(frame *data.Frame) {
+	for i, field := range frame.Fields {
+		if field.Type() != data.FieldTypeNullableString || !isAggregationColumn(field.Name) {
+			continue
+		}
+
+		values := make([]*float64, field.Len())
+		numeric := true
+		for j := 0; j < field.Len(); j++ {
+			raw, ok := field.ConcreteAt(j)
+			if !ok {
+				continue
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(raw.(string)), 64)
+			if err != nil {
+				numeric = false
+				break
+			}
+			values[j] = &value
+		}
+		if !numeric {
+			continue
+		}
+
+		converted := data.NewField(field.Name, field.Labels, values)
+		converted.Config = field.Config
+		frame.Fields[i] = converted
+	}
+}
@@ -0,0 +1,63 @@
+package resourcegraph
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/loganalytics"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertAggregationColumns(t *testing.T) {
+	newFrame := func(t *testing.T, raw string) *data.Frame {
+		t.Helper()
+		table := types.AzureResponseTable{}
+		require.NoError(t, json.Unmarshal([]byte(raw), &table))
+		frame, err := loganalytics.ResponseTableToFrame(&table)
+		require.NoError(t, err)
+		return frame
+	}
+
+	t.Run("should type an avg() result returned as a string as a number", func(t *testing.T) {
+		frame := newFrame(t, `{
+			"columns": [{"name": "type", "type": "string"}, {"name": "avg_cores", "type": "string"}],
+			"rows": [["vm", "4.5"], ["disk", null]]
+		}`)
+
+		convertAggregationColumns(frame)
+
+		require.Len(t, frame.Fields, 2)
+		assert.Equal(t, data.FieldTypeNullableString, frame.Fields[0].Type())
+		avg := frame.Fields[1]
+		assert.Equal(t, "avg_cores", avg.Name)
+		require.Equal(t, data.FieldTypeNullableFloat64, avg.Type())
+		value := 4.5
+		assert.Equal(t, &value, avg.At(0))
+		assert.Nil(t, avg.At(1))
+	})
+
+	t.Run("should leave aggregation columns with other values untouched", func(t *testing.T) {
+		frame := newFrame(t, `{
+			"columns": [{"name": "max_name", "type": "string"}],
+			"rows": [["vm-1"]]
+		}`)
+
+		convertAggregationColumns(frame)
+
+		assert.Equal(t, data.FieldTypeNullableString, frame.Fields[0].Type())
+	})
+
+	t.Run("should leave other string columns untouched", func(t *testing.T) {
+		frame := newFrame(t, `{
+			"columns": [{"name": "cores", "type": "string"}],
+			"rows": [["4"]]
+		}`)
+
+		convertAggregationColumns(frame)
+
+		assert.Equal(t, data.FieldTypeNullableString, frame.Fields[0].Type())
+	})
+}
@@ -6,15 +6,17 @@ import (
 
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
+	"regexp"
+	"strings"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
-	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/infra/tracing"
 	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/azlog"
@@ -23,17 +25,52 @@ import (
 	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
 	"github.com/grafana/grafana/pkg/util/errutil"
 	"go.opentelemetry.io/otel/attribute"
-	"golang.org/x/net/context/ctxhttp"
 )
 
 // AzureResourceGraphResponse is the json response object from the Azure Resource Graph Analytics API.
 type AzureResourceGraphResponse struct {
 	Data types.AzureResponseTable `json:"data"`
+	// SkipToken is set when there are more results, it requests the next page.
+	SkipToken string `json:"$skipToken"`
+	// Count is the number of rows in this response.
+	Count int64 `json:"count"`
+	// ResultTruncated is "true" when Azure returned only part of the rows.
+	ResultTruncated string `json:"resultTruncated"`
+	// RawBody is the response as Azure sent it.
+	RawBody []byte `json:"-"`
+}
+
+// AzureResourceGraphMeta is the custom metadata of Azure Resource Graph frames.
+type AzureResourceGraphMeta struct {
+	ColumnTypes []string `json:"azureColumnTypes"`
+	// SkipToken continues the query from the next page, it is empty when the result is complete.
+	SkipToken string `json:"skipToken,omitempty"`
+	// DatasourceUID and DatasourceName identify the datasource that produced the frame,
+	// so that panels mixing several Azure datasources can tell their frames apart.
+	DatasourceUID  string `json:"datasourceUid,omitempty"`
+	DatasourceName string `json:"datasourceName,omitempty"`
+	// ErrorCode and CorrelationID are set on the frame of a failed query when Azure returned them,
+	// so that users can hand them to Azure support.
+	ErrorCode     string `json:"errorCode,omitempty"`
+	CorrelationID string `json:"correlationId,omitempty"`
 }
 
 // AzureResourceGraphDatasource calls the Azure Resource Graph API's
 type AzureResourceGraphDatasource struct {
 	Proxy types.ServiceProxy
+	// AllowedOrgs limits which orgs may run queries. Empty means all orgs are allowed.
+	AllowedOrgs []int64
+	// DeprecatedKQL lists KQL tokens that produce a notice when a query uses them.
+	DeprecatedKQL []string
+	// MaxTagColumns caps the number of tag columns added for queries with includeTags.
+	MaxTagColumns int
+	// MaxSubscriptions is the number of subscriptions a query may target, 0 uses Azure's limit.
+	MaxSubscriptions int
+	// AllowURLOverride lets a datasource send its queries to the endpoint in its resourceGraphUrl setting.
+	// Requests carry the Azure credentials, so this should only be enabled for test setups.
+	AllowURLOverride bool
+	// MaxRetries is how often a request failing with a connection error or a retryable status is retried.
+	MaxRetries int
 }
 
 // AzureResourceGraphQuery is the query request that is built from the saved values for
@@ -45,11 +82,40 @@ type AzureResourceGraphQuery struct {
 	JSON              json.RawMessage
 	InterpolatedQuery string
 	TimeRange         backend.TimeRange
+	Aliases           map[string]string
+	SeriesBy          string
+	IncludeTags       bool
+	SkipToken         string
+	MergeOn           string
+	NullPolicy        string
+	// Debug logs the details of the query's execution at info level.
+	Debug bool
+	// Subscriptions scopes the query, it falls back to the default subscription of the datasource.
+	Subscriptions []string
 }
 
 const argAPIVersion = "2021-06-01-preview"
 const argQueryProviderName = "/providers/Microsoft.ResourceGraph/resources"
 
+// resourceTypeCountQueryMode runs resourceTypeCountQuery when the query has no KQL of its own.
+const resourceTypeCountQueryMode = "resourceTypeCount"
+const resourceTypeCountQuery = "resources | summarize count() by type"
+
+// defaultMaxSubscriptions is the number of subscriptions Azure Resource Graph accepts in a single query.
+const defaultMaxSubscriptions = 1000
+
+// previewLimit is the number of rows returned for preview queries run from the query editor.
+const previewLimit = 100
+
+// defaultMaxRows is the number of rows a query returns at most when the datasource doesn't set resourceGraphMaxRows.
+const defaultMaxRows = 10000
+
+// logQueryDetails logs the execution of queries with the debug flag.
+var logQueryDetails = azlog.Info
+
+// allSubscriptions is the value a subscription template variable takes when All is selected.
+const allSubscriptions = "$__all"
+
 func (e *AzureResourceGraphDatasource) ResourceRequest(rw http.ResponseWriter, req *http.Request, cli *http.Client) {
 	e.Proxy.Do(rw, req, cli)
 }
@@ -58,28 +124,66 @@ func (e *AzureResourceGraphDatasource) ResourceRequest(rw http.ResponseWriter, r
 // 1. builds the AzureMonitor url and querystring for each query
 // 2. executes each query by calling the Azure Monitor API
 // 3. parses the responses for each query into data frames
+// 4. merges the frames of queries that share a mergeOn key column
 func (e *AzureResourceGraphDatasource) ExecuteTimeSeriesQuery(ctx context.Context, originalQueries []backend.DataQuery, dsInfo types.DatasourceInfo, client *http.Client,
 	url string, tracer tracing.Tracer) (*backend.QueryDataResponse, error) {
 	result := &backend.QueryDataResponse{
 		Responses: map[string]backend.DataResponse{},
 	}
 
+	if !e.isOrgAllowed(dsInfo.OrgID) {
+		err := fmt.Errorf("organization %d is not allowed to run Azure Resource Graph queries", dsInfo.OrgID)
+		for _, query := range originalQueries {
+			result.Responses[query.RefID] = backend.DataResponse{Error: err}
+		}
+		return result, nil
+	}
+
 	queries, err := e.buildQueries(originalQueries, dsInfo)
 	if err != nil {
 		return nil, err
 	}
 
+	if e.AllowURLOverride && dsInfo.Settings.ResourceGraphURL != "" {
+		url = dsInfo.Settings.ResourceGraphURL
+	}
+
 	for _, query := range queries {
 		result.Responses[query.RefID] = e.executeQuery(ctx, query, dsInfo, client, url, tracer)
 	}
 
+	mergeResponses(result.Responses, queries)
+
 	return result, nil
 }
 
+func (e *AzureResourceGraphDatasource) isOrgAllowed(orgID int64) bool {
+	if len(e.AllowedOrgs) == 0 {
+		return true
+	}
+	for _, allowed := range e.AllowedOrgs {
+		if allowed == orgID {
+			return true
+		}
+	}
+	return false
+}
+
 type argJSONQuery struct {
+	Subscriptions      []string `json:"subscriptions"`
 	AzureResourceGraph struct {
-		Query        string `json:"query"`
-		ResultFormat string `json:"resultFormat"`
+		Query        string            `json:"query"`
+		ResultFormat string            `json:"resultFormat"`
+		Aliases      map[string]string `json:"aliases"`
+		StrictMacros bool              `json:"strictMacros"`
+		SeriesBy     string            `json:"seriesBy"`
+		IncludeTags  bool              `json:"includeTags"`
+		QueryMode    string            `json:"queryMode"`
+		SkipToken    string            `json:"skipToken"`
+		Preview      bool              `json:"preview"`
+		MergeOn      string            `json:"mergeOn"`
+		NullPolicy   string            `json:"nullPolicy"`
+		Debug        bool              `json:"debug"`
 	} `json:"azureResourceGraph"`
 }
 
@@ -96,9 +200,39 @@ func (e *AzureResourceGraphDatasource) buildQueries(queries []backend.DataQuery,
 		azureResourceGraphTarget := queryJSONModel.AzureResourceGraph
 		azlog.Debug("AzureResourceGraph", "target", azureResourceGraphTarget)
 
+		if azureResourceGraphTarget.QueryMode == resourceTypeCountQueryMode && strings.TrimSpace(azureResourceGraphTarget.Query) == "" {
+			azureResourceGraphTarget.Query = resourceTypeCountQuery
+		}
+
 		resultFormat := azureResourceGraphTarget.ResultFormat
 		if resultFormat == "" {
-			resultFormat = "table"
+			resultFormat = types.Table
+		}
+		if !validResultFormat(resultFormat) {
+			return nil, fmt.Errorf("query %s has an unknown result format %q, expected one of %s", query.RefID,
+				resultFormat, strings.Join(resultFormats, ", "))
+		}
+
+		maxSubscriptions := e.MaxSubscriptions
+		if maxSubscriptions <= 0 {
+			maxSubscriptions = defaultMaxSubscriptions
+		}
+		if subscriptions := queryJSONModel.Subscriptions; len(subscriptions) > maxSubscriptions && !includesAllSubscriptions(subscriptions) {
+			return nil, fmt.Errorf("query %s targets %d subscriptions but Azure Resource Graph accepts at most %d, split it into several queries",
+				query.RefID, len(subscriptions), maxSubscriptions)
+		}
+
+		subscriptions := scopeSubscriptions(queryJSONModel.Subscriptions, dsInfo)
+
+		if azureResourceGraphTarget.StrictMacros {
+			if unknown := macros.UnknownMacros(azureResourceGraphTarget.Query); len(unknown) > 0 {
+				return nil, fmt.Errorf("query %s contains unknown macros: %s", query.RefID, strings.Join(unknown, ", "))
+			}
+		}
+
+		if !validNullPolicy(azureResourceGraphTarget.NullPolicy) {
+			return nil, fmt.Errorf("query %s has an unknown null policy %q, expected one of %s, %s or %s", query.RefID,
+				azureResourceGraphTarget.NullPolicy, nullPolicyKeep, nullPolicyZeroFill, nullPolicyDropRow)
 		}
 
 		interpolatedQuery, err := macros.KqlInterpolate(query, dsInfo, azureResourceGraphTarget.Query)
@@ -107,12 +241,25 @@ func (e *AzureResourceGraphDatasource) buildQueries(queries []backend.DataQuery,
 			return nil, err
 		}
 
+		// the query editor sets preview to show a sample quickly, panel refreshes return the full result
+		if azureResourceGraphTarget.Preview {
+			interpolatedQuery = fmt.Sprintf("%s\n| limit %d", interpolatedQuery, previewLimit)
+		}
+
 		azureResourceGraphQueries = append(azureResourceGraphQueries, &AzureResourceGraphQuery{
 			RefID:             query.RefID,
 			ResultFormat:      resultFormat,
 			JSON:              query.JSON,
 			InterpolatedQuery: interpolatedQuery,
 			TimeRange:         query.TimeRange,
+			Aliases:           azureResourceGraphTarget.Aliases,
+			SeriesBy:          azureResourceGraphTarget.SeriesBy,
+			IncludeTags:       azureResourceGraphTarget.IncludeTags,
+			SkipToken:         azureResourceGraphTarget.SkipToken,
+			Subscriptions:     subscriptions,
+			MergeOn:           azureResourceGraphTarget.MergeOn,
+			NullPolicy:        azureResourceGraphTarget.NullPolicy,
+			Debug:             azureResourceGraphTarget.Debug,
 		})
 	}
 
@@ -126,6 +273,14 @@ func (e *AzureResourceGraphDatasource) executeQuery(ctx context.Context, query *
 	params := url.Values{}
 	params.Add("api-version", argAPIVersion)
 
+	// the timeout covers every page of the query along with their retries
+	timeout := time.Duration(dsInfo.Settings.QueryTimeout) * time.Second
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	dataResponseErrorWithExecuted := func(err error) backend.DataResponse {
 		dataResponse = backend.DataResponse{Error: err}
 		frames := data.Frames{
@@ -136,37 +291,50 @@ func (e *AzureResourceGraphDatasource) executeQuery(ctx context.Context, query *
 				},
 			},
 		}
+		var supportErr AzureSupportError
+		if errors.As(err, &supportErr) {
+			frames[0].Meta.Custom = &AzureResourceGraphMeta{
+				ErrorCode:     supportErr.AzureErrorCode(),
+				CorrelationID: supportErr.AzureCorrelationID(),
+			}
+		}
 		dataResponse.Frames = frames
 		return dataResponse
 	}
 
-	model, err := simplejson.NewJson(query.JSON)
-	if err != nil {
-		dataResponse.Error = err
-		return dataResponse
+	options := map[string]string{"resultFormat": "table"}
+	if query.SkipToken != "" {
+		options["$skipToken"] = query.SkipToken
 	}
-
-	reqBody, err := json.Marshal(map[string]interface{}{
-		"subscriptions": model.Get("subscriptions").MustStringArray(),
-		"query":         query.InterpolatedQuery,
-		"options":       map[string]string{"resultFormat": "table"},
-	})
-
-	if err != nil {
-		dataResponse.Error = err
-		return dataResponse
+	body := map[string]interface{}{
+		"query":   query.InterpolatedQuery,
+		"options": options,
+	}
+	// without a subscriptions scope Azure queries every subscription the credentials can access
+	subscriptions := query.Subscriptions
+	if len(subscriptions) > 0 && !includesAllSubscriptions(subscriptions) {
+		body["subscriptions"] = subscriptions
+	}
+	newRequest := func() (*http.Request, error) {
+		reqBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		req, err := e.createRequest(ctx, dsInfo, reqBody, dsURL)
+		if err != nil {
+			return nil, err
+		}
+		req.URL.Path = path.Join(req.URL.Path, argQueryProviderName)
+		req.URL.RawQuery = params.Encode()
+		return req, nil
 	}
 
-	req, err := e.createRequest(ctx, dsInfo, reqBody, dsURL)
-
+	req, err := newRequest()
 	if err != nil {
 		dataResponse.Error = err
 		return dataResponse
 	}
 
-	req.URL.Path = path.Join(req.URL.Path, argQueryProviderName)
-	req.URL.RawQuery = params.Encode()
-
 	ctx, span := tracer.Start(ctx, "azure resource graph query")
 	span.SetAttributes("interpolated_query", query.InterpolatedQuery, attribute.Key("interpolated_query").String(query.InterpolatedQuery))
 	span.SetAttributes("from", query.TimeRange.From.UnixNano()/int64(time.Millisecond), attribute.Key("from").Int64(query.TimeRange.From.UnixNano()/int64(time.Millisecond)))
@@ -179,32 +347,153 @@ func (e *AzureResourceGraphDatasource) executeQuery(ctx context.Context, query *
 	tracer.Inject(ctx, req.Header, span)
 
 	azlog.Debug("AzureResourceGraph", "Request ApiURL", req.URL.String())
-	res, err := ctxhttp.Do(ctx, client, req)
+	var status, rows int
+	if query.Debug {
+		start := time.Now()
+		defer func() {
+			scope := "all"
+			if _, ok := body["subscriptions"]; ok {
+				scope = strings.Join(subscriptions, ",")
+			}
+			logQueryDetails("Azure Resource Graph query", "refId", query.RefID, "query", query.InterpolatedQuery,
+				"subscriptions", scope, "status", status, "rows", rows, "duration", time.Since(start), "error", dataResponse.Error)
+		}()
+	}
+	argResponse, status, err := e.send(ctx, client, req, timeout)
 	if err != nil {
 		return dataResponseErrorWithExecuted(err)
 	}
 
-	argResponse, err := e.unmarshalResponse(res)
-	if err != nil {
-		return dataResponseErrorWithExecuted(err)
+	// the trace format returns the first page as Azure sent it, without parsing it into columns
+	if query.ResultFormat == types.Trace {
+		rows = len(argResponse.Data.Rows)
+		rawBody, err := dropRawColumns(argResponse.RawBody, dsInfo.Settings.ResourceGraphDropColumns)
+		if err != nil {
+			return dataResponseErrorWithExecuted(err)
+		}
+		frame := traceFrame(rawBody)
+		frame.Meta = &data.FrameMeta{
+			ExecutedQueryString: req.URL.RawQuery,
+			Custom: &AzureResourceGraphMeta{
+				SkipToken:      argResponse.SkipToken,
+				DatasourceUID:  dsInfo.DatasourceUID,
+				DatasourceName: dsInfo.DatasourceName,
+			},
+		}
+		dataResponse.Frames = data.Frames{frame}
+		return dataResponse
+	}
+
+	// Azure returns at most 1000 rows per request, the following pages are requested with the skip
+	// token of the previous one until the result is complete or has as many rows as it may have
+	maxRows := dsInfo.Settings.ResourceGraphMaxRows
+	if maxRows <= 0 {
+		maxRows = defaultMaxRows
+	}
+	for argResponse.SkipToken != "" && len(argResponse.Data.Rows) < maxRows {
+		options["$skipToken"] = argResponse.SkipToken
+		pageReq, err := newRequest()
+		if err != nil {
+			return dataResponseErrorWithExecuted(err)
+		}
+		tracer.Inject(ctx, pageReq.Header, span)
+
+		var page AzureResourceGraphResponse
+		page, status, err = e.send(ctx, client, pageReq, timeout)
+		if err != nil {
+			return dataResponseErrorWithExecuted(err)
+		}
+		// an empty page would request the same token again
+		if len(page.Data.Rows) == 0 {
+			break
+		}
+		argResponse.Data.Rows = append(argResponse.Data.Rows, page.Data.Rows...)
+		argResponse.SkipToken = page.SkipToken
+	}
+	// pages are up to 1000 rows, the last one may go past the limit
+	limited := argResponse.SkipToken != "" && len(argResponse.Data.Rows) >= maxRows
+	if len(argResponse.Data.Rows) > maxRows {
+		argResponse.Data.Rows = argResponse.Data.Rows[:maxRows]
+		limited = true
 	}
 
 	frame, err := loganalytics.ResponseTableToFrame(&argResponse.Data)
 	if err != nil {
 		return dataResponseErrorWithExecuted(err)
 	}
+	rows = frame.Rows()
+	dropColumns(frame, dsInfo.Settings.ResourceGraphDropColumns)
+	convertAggregationColumns(frame)
 
-	azurePortalUrl, err := GetAzurePortalUrl(dsInfo.Cloud)
-	if err != nil {
+	if query.IncludeTags {
+		if err := expandTags(frame, e.MaxTagColumns); err != nil {
+			return dataResponseErrorWithExecuted(err)
+		}
+	}
+
+	if err := applyNullPolicy(frame, query.NullPolicy); err != nil {
 		return dataResponseErrorWithExecuted(err)
 	}
 
-	url := azurePortalUrl + "/#blade/HubsExtension/ArgQueryBlade/query/" + url.PathEscape(query.InterpolatedQuery)
-	frameWithLink := AddConfigLinks(*frame, url)
+	resultFormat := query.ResultFormat
+	if resultFormat == types.AutoResultFormat {
+		resultFormat = detectResultFormat(frame)
+	}
+
+	// an empty result keeps the columns of the table, the time series conversions would drop them
+	if resultFormat == types.TimeSeries && frame.Rows() > 0 {
+		if query.SeriesBy != "" {
+			seriesFrame, err := partitionBySeries(frame, query.SeriesBy)
+			if err == nil {
+				frame = seriesFrame
+			} else {
+				frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityWarning, Text: "could not split the result into series, returning raw table: " + err.Error()})
+			}
+		} else if query.ResultFormat == types.AutoResultFormat && frame.TimeSeriesSchema().Type == data.TimeSeriesTypeLong {
+			// only detected time series are widened, explicit time_series queries return the frame as it is
+			wideFrame, err := data.LongToWide(frame, nil)
+			if err == nil {
+				frame = wideFrame
+			} else {
+				frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityWarning, Text: "could not convert frame to time series, returning raw table: " + err.Error()})
+			}
+		}
+	}
+	applyFieldAliases(frame, query.Aliases)
+	// reshaped after the aliases so that aliased columns are named by their alias in the metric column
+	if resultFormat == types.Long {
+		longFrame, err := toLongFormat(frame)
+		if err == nil {
+			frame = longFrame
+		} else {
+			frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityWarning, Text: "could not convert frame to long format, returning raw table: " + err.Error()})
+		}
+	}
+
+	frameWithLink, err := addPortalLinks(*frame, dsInfo.Cloud, "/#blade/HubsExtension/ArgQueryBlade/query/"+url.PathEscape(query.InterpolatedQuery))
+	if err != nil {
+		return dataResponseErrorWithExecuted(err)
+	}
 	if frameWithLink.Meta == nil {
 		frameWithLink.Meta = &data.FrameMeta{}
 	}
 	frameWithLink.Meta.ExecutedQueryString = req.URL.RawQuery
+	meta := &AzureResourceGraphMeta{
+		SkipToken:      argResponse.SkipToken,
+		DatasourceUID:  dsInfo.DatasourceUID,
+		DatasourceName: dsInfo.DatasourceName,
+	}
+	if laMeta, ok := frameWithLink.Meta.Custom.(*loganalytics.LogAnalyticsMeta); ok {
+		meta.ColumnTypes = laMeta.ColumnTypes
+	}
+	frameWithLink.Meta.Custom = meta
+	frameWithLink.AppendNotices(deprecatedKQLNotices(query.InterpolatedQuery, e.DeprecatedKQL)...)
+	if limited {
+		frameWithLink.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("The result was limited to %d rows, refine the query to return fewer rows", maxRows),
+		})
+	}
 
 	dataResponse.Frames = data.Frames{&frameWithLink}
 	return dataResponse
@@ -225,19 +514,127 @@ func AddConfigLinks(frame data.Frame, dl string) data.Frame {
 	return frame
 }
 
+// addPortalLinks links every field of frame to bladePath in the Azure portal of cloud,
+// so that datasources of sovereign clouds don't link to the public portal.
+func addPortalLinks(frame data.Frame, cloud string, bladePath string) (data.Frame, error) {
+	azurePortalUrl, err := GetAzurePortalUrl(cloud)
+	if err != nil {
+		return frame, err
+	}
+	return AddConfigLinks(frame, azurePortalUrl+bladePath), nil
+}
+
+// applyFieldAliases sets the display name of every field whose column name has an
+// entry in aliases. Aliases that don't match a column are ignored.
+func applyFieldAliases(frame *data.Frame, aliases map[string]string) {
+	for _, field := range frame.Fields {
+		alias, ok := aliases[field.Name]
+		if !ok || alias == "" {
+			continue
+		}
+		if field.Config == nil {
+			field.Config = &data.FieldConfig{}
+		}
+		field.Config.DisplayNameFromDS = alias
+	}
+}
+
+// scopeSubscriptions returns the subscriptions a query runs on, the default subscription
+// of the datasource when the query has none.
+func scopeSubscriptions(subscriptions []string, dsInfo types.DatasourceInfo) []string {
+	if len(subscriptions) == 0 && dsInfo.Settings.SubscriptionId != "" {
+		return []string{dsInfo.Settings.SubscriptionId}
+	}
+	return subscriptions
+}
+
+// includesAllSubscriptions reports whether a subscription template variable was set to All.
+func includesAllSubscriptions(subscriptions []string) bool {
+	for _, subscription := range subscriptions {
+		if subscription == allSubscriptions {
+			return true
+		}
+	}
+	return false
+}
+
+// resultFormats are the result formats supported by Azure Resource Graph queries
+var resultFormats = []string{types.Table, types.TimeSeries, types.AutoResultFormat, types.Long, types.Trace}
+
+func validResultFormat(format string) bool {
+	for _, f := range resultFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// detectResultFormat returns time_series when the frame has a time column and table otherwise.
+func detectResultFormat(frame *data.Frame) string {
+	for _, field := range frame.Fields {
+		if field.Type().Time() {
+			return types.TimeSeries
+		}
+	}
+	return types.Table
+}
+
+// deprecatedKQLNotices returns an info notice for every deprecated token used in query.
+func deprecatedKQLNotices(query string, deprecated []string) []data.Notice {
+	var notices []data.Notice
+	for _, token := range deprecated {
+		re, err := regexp.Compile(`(^|[^\w])` + regexp.QuoteMeta(token) + `([^\w]|$)`)
+		if err != nil {
+			continue
+		}
+		if re.MatchString(query) {
+			notices = append(notices, data.Notice{
+				Severity: data.NoticeSeverityInfo,
+				Text:     fmt.Sprintf("The query uses %q, which is deprecated in Azure Resource Graph", token),
+			})
+		}
+	}
+	return notices
+}
+
 func (e *AzureResourceGraphDatasource) createRequest(ctx context.Context, dsInfo types.DatasourceInfo, reqBody []byte, url string) (*http.Request, error) {
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		azlog.Debug("Failed to create request", "error", err)
 		return nil, errutil.Wrap("failed to create request", err)
 	}
-	req.URL.Path = "/"
+	// keep the path of the base URL, an overridden endpoint may be served under a prefix
+	req.URL.Path = path.Join("/", req.URL.Path)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", fmt.Sprintf("Grafana/%s", setting.BuildVersion))
 
 	return req, nil
 }
 
+// send sends a query request, retrying it when it fails, and decodes the response. Requests that are still
+// throttled after the retries and queries running into their timeout get an error saying so, as the query
+// itself is fine in both cases.
+func (e *AzureResourceGraphDatasource) send(ctx context.Context, client *http.Client, req *http.Request, timeout time.Duration) (AzureResourceGraphResponse, int, error) {
+	argResponse := AzureResourceGraphResponse{}
+	status := 0
+	res, err := doWithRetry(ctx, client, req, e.MaxRetries)
+	if err == nil {
+		status = res.StatusCode
+		argResponse, err = e.unmarshalResponse(res)
+	}
+
+	var argErr *AzureResourceGraphError
+	switch {
+	case err == nil:
+	case timeout > 0 && errors.Is(err, context.DeadlineExceeded):
+		err = fmt.Errorf("query timed out after %s, raise the query timeout of the datasource or narrow the query: %w", timeout, err)
+	case errors.As(err, &argErr) && argErr.StatusCode == http.StatusTooManyRequests:
+		err = fmt.Errorf("requests are still throttled by Azure Resource Graph after retrying them, try again later: %w", err)
+	}
+	return argResponse, status, err
+}
+
 func (e *AzureResourceGraphDatasource) unmarshalResponse(res *http.Response) (AzureResourceGraphResponse, error) {
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -251,7 +648,7 @@ func (e *AzureResourceGraphDatasource) unmarshalResponse(res *http.Response) (Az
 
 	if res.StatusCode/100 != 2 {
 		azlog.Debug("Request failed", "status", res.Status, "body", string(body))
-		return AzureResourceGraphResponse{}, fmt.Errorf("%s. Azure Resource Graph error: %s", res.Status, string(body))
+		return AzureResourceGraphResponse{}, newAzureResourceGraphError(res, body)
 	}
 
 	var data AzureResourceGraphResponse
@@ -262,6 +659,7 @@ func (e *AzureResourceGraphDatasource) unmarshalResponse(res *http.Response) (Az
 		azlog.Debug("Failed to unmarshal azure resource graph response", "error", err, "status", res.Status, "body", string(body))
 		return AzureResourceGraphResponse{}, err
 	}
+	data.RawBody = body
 
 	return data, nil
 }
@@ -2,8 +2,11 @@ package resourcegraph
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -13,7 +16,9 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/tracing"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/azlog"
 	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -65,6 +70,82 @@ func TestBuildingAzureResourceGraphQueries(t *testing.T) {
 			},
 			Err: require.NoError,
 		},
+		{
+			name: "Time filter macro should bound the column to the query time range",
+			queryModel: []backend.DataQuery{
+				{
+					JSON: []byte(`{
+						"azureResourceGraph": {
+							"query": "resources | where $__timeFilter(properties-createdTime)"
+						}
+					}`),
+					RefID: "A",
+					TimeRange: backend.TimeRange{
+						From: fromStart,
+						To:   fromStart.Add(34 * time.Minute),
+					},
+				},
+			},
+			azureResourceGraphQueries: []*AzureResourceGraphQuery{
+				{
+					RefID:        "A",
+					ResultFormat: "table",
+					JSON: []byte(`{
+						"azureResourceGraph": {
+							"query": "resources | where $__timeFilter(properties-createdTime)"
+						}
+					}`),
+					InterpolatedQuery: "resources | where ['properties-createdTime'] >= datetime('2018-03-15T13:00:00Z') and " +
+						"['properties-createdTime'] <= datetime('2018-03-15T13:34:00Z')",
+					TimeRange: backend.TimeRange{
+						From: fromStart,
+						To:   fromStart.Add(34 * time.Minute),
+					},
+				},
+			},
+			Err: require.NoError,
+		},
+		{
+			name: "Unknown macros should be passed through by default",
+			queryModel: []backend.DataQuery{
+				{
+					JSON: []byte(`{
+						"azureResourceGraph": {
+							"query": "resources | where $__timefilter(createdTime)"
+						}
+					}`),
+					RefID: "A",
+				},
+			},
+			azureResourceGraphQueries: []*AzureResourceGraphQuery{
+				{
+					RefID:        "A",
+					ResultFormat: "table",
+					JSON: []byte(`{
+						"azureResourceGraph": {
+							"query": "resources | where $__timefilter(createdTime)"
+						}
+					}`),
+					InterpolatedQuery: "resources | where $__timefilter(createdTime)",
+				},
+			},
+			Err: require.NoError,
+		},
+		{
+			name: "Unknown macros should be rejected with strict macros",
+			queryModel: []backend.DataQuery{
+				{
+					JSON: []byte(`{
+						"azureResourceGraph": {
+							"query":        "resources | where $__timefilter(createdTime)",
+							"strictMacros": true
+						}
+					}`),
+					RefID: "A",
+				},
+			},
+			Err: require.Error,
+		},
 	}
 
 	for _, tt := range tests {
@@ -78,19 +159,153 @@ func TestBuildingAzureResourceGraphQueries(t *testing.T) {
 	}
 }
 
+func TestBuildingAzureResourceGraphQueriesSubscriptions(t *testing.T) {
+	datasource := &AzureResourceGraphDatasource{}
+	dsInfo := types.DatasourceInfo{Settings: types.AzureMonitorSettings{SubscriptionId: "default-sub"}}
+	build := func(t *testing.T, model string, dsInfo types.DatasourceInfo) []string {
+		queries, err := datasource.buildQueries([]backend.DataQuery{{RefID: "A", JSON: []byte(model)}}, dsInfo)
+		require.NoError(t, err)
+		require.Len(t, queries, 1)
+		return queries[0].Subscriptions
+	}
+
+	t.Run("should scope the query to its subscriptions", func(t *testing.T) {
+		subscriptions := build(t, `{"subscriptions": ["sub1", "sub2"], "azureResourceGraph": {"query": "resources"}}`, dsInfo)
+		assert.Equal(t, []string{"sub1", "sub2"}, subscriptions)
+	})
+
+	t.Run("should fall back to the default subscription of the datasource", func(t *testing.T) {
+		subscriptions := build(t, `{"azureResourceGraph": {"query": "resources"}}`, dsInfo)
+		assert.Equal(t, []string{"default-sub"}, subscriptions)
+	})
+
+	t.Run("should have no subscriptions without a default subscription", func(t *testing.T) {
+		subscriptions := build(t, `{"subscriptions": [], "azureResourceGraph": {"query": "resources"}}`, types.DatasourceInfo{})
+		assert.Empty(t, subscriptions)
+	})
+}
+
+func TestBuildingAzureResourceGraphQueriesMaxSubscriptions(t *testing.T) {
+	datasource := &AzureResourceGraphDatasource{MaxSubscriptions: 2}
+	query := func(subscriptions string) []backend.DataQuery {
+		return []backend.DataQuery{{
+			RefID: "A",
+			JSON:  []byte(`{"subscriptions": ` + subscriptions + `, "azureResourceGraph": {"query": "resources"}}`),
+		}}
+	}
+
+	t.Run("should accept a query within the limit", func(t *testing.T) {
+		_, err := datasource.buildQueries(query(`["sub1", "sub2"]`), types.DatasourceInfo{})
+		require.NoError(t, err)
+	})
+
+	t.Run("should reject a query over the limit before sending it", func(t *testing.T) {
+		_, err := datasource.buildQueries(query(`["sub1", "sub2", "sub3"]`), types.DatasourceInfo{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "targets 3 subscriptions but Azure Resource Graph accepts at most 2")
+		assert.Contains(t, err.Error(), "split it into several queries")
+	})
+
+	t.Run("should use Azure's limit when none is configured", func(t *testing.T) {
+		subscriptions := make([]string, defaultMaxSubscriptions+1)
+		for i := range subscriptions {
+			subscriptions[i] = fmt.Sprintf("sub%d", i)
+		}
+		raw, err := json.Marshal(subscriptions)
+		require.NoError(t, err)
+
+		_, err = (&AzureResourceGraphDatasource{}).buildQueries(query(string(raw)), types.DatasourceInfo{})
+		require.Error(t, err)
+	})
+}
+
+func TestBuildingAzureResourceGraphQueriesResultFormat(t *testing.T) {
+	datasource := &AzureResourceGraphDatasource{}
+	query := func(format string) []backend.DataQuery {
+		return []backend.DataQuery{{
+			RefID: "A",
+			JSON:  []byte(`{"azureResourceGraph": {"query": "resources", "resultFormat": "` + format + `"}}`),
+		}}
+	}
+
+	for _, format := range resultFormats {
+		queries, err := datasource.buildQueries(query(format), types.DatasourceInfo{})
+		require.NoError(t, err)
+		require.Len(t, queries, 1)
+		assert.Equal(t, format, queries[0].ResultFormat)
+	}
+
+	_, err := datasource.buildQueries(query("tabel"), types.DatasourceInfo{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown result format "tabel"`)
+	assert.Contains(t, err.Error(), "table, time_series, auto, long, trace")
+}
+
+func TestExecuteTimeSeriesQueryUnknownResultFormat(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, err := w.Write([]byte(`{"data":{"columns":[{"name":"name","type":"string"}],"rows":[["res1"]]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{}
+	dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic}
+	queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources", "resultFormat": "tabel"}}`)}}
+
+	_, err = datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+	require.Error(t, err)
+	assert.Equal(t, 0, requests)
+}
+
+func TestBuildingAzureResourceGraphQueriesPreview(t *testing.T) {
+	datasource := &AzureResourceGraphDatasource{}
+
+	tests := []struct {
+		name              string
+		queryJSON         string
+		interpolatedQuery string
+	}{
+		{
+			name:              "should limit the result of a preview query",
+			queryJSON:         `{"azureResourceGraph": {"query": "resources", "preview": true}}`,
+			interpolatedQuery: "resources\n| limit 100",
+		},
+		{
+			name:              "should return the full result otherwise",
+			queryJSON:         `{"azureResourceGraph": {"query": "resources"}}`,
+			interpolatedQuery: "resources",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries, err := datasource.buildQueries([]backend.DataQuery{{RefID: "A", JSON: []byte(tt.queryJSON)}}, types.DatasourceInfo{})
+			require.NoError(t, err)
+			require.Len(t, queries, 1)
+			assert.Equal(t, tt.interpolatedQuery, queries[0].InterpolatedQuery)
+		})
+	}
+}
+
 func TestAzureResourceGraphCreateRequest(t *testing.T) {
 	ctx := context.Background()
-	url := "http://ds"
 	dsInfo := types.DatasourceInfo{}
 
 	tests := []struct {
 		name            string
+		url             string
 		expectedURL     string
 		expectedHeaders http.Header
 		Err             require.ErrorAssertionFunc
 	}{
 		{
 			name:        "creates a request",
+			url:         "http://ds",
 			expectedURL: "http://ds/",
 			expectedHeaders: http.Header{
 				"Content-Type": []string{"application/json"},
@@ -98,12 +313,22 @@ func TestAzureResourceGraphCreateRequest(t *testing.T) {
 			},
 			Err: require.NoError,
 		},
+		{
+			name:        "keeps the path of the base URL",
+			url:         "http://mock/replay/",
+			expectedURL: "http://mock/replay",
+			expectedHeaders: http.Header{
+				"Content-Type": []string{"application/json"},
+				"User-Agent":   []string{"Grafana/"},
+			},
+			Err: require.NoError,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ds := AzureResourceGraphDatasource{}
-			req, err := ds.createRequest(ctx, dsInfo, []byte{}, url)
+			req, err := ds.createRequest(ctx, dsInfo, []byte{}, tt.url)
 			tt.Err(t, err)
 			if req.URL.String() != tt.expectedURL {
 				t.Errorf("Expecting %s, got %s", tt.expectedURL, req.URL.String())
@@ -115,6 +340,49 @@ func TestAzureResourceGraphCreateRequest(t *testing.T) {
 	}
 }
 
+func TestExecuteQueryURLOverride(t *testing.T) {
+	var requestPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestPath = r.URL.Path
+		_, err := w.Write([]byte(`{"data":{"columns":[{"name":"name","type":"string"}],"rows":[["canned"]]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources"}}`)}}
+	dsInfo := types.DatasourceInfo{
+		Cloud:    setting.AzurePublic,
+		Settings: types.AzureMonitorSettings{ResourceGraphURL: srv.URL + "/replay"},
+	}
+	// nothing listens on the route URL, only the override can answer
+	routeURL := "http://127.0.0.1:1"
+
+	t.Run("should query the overridden endpoint when allowed", func(t *testing.T) {
+		datasource := &AzureResourceGraphDatasource{AllowURLOverride: true}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), routeURL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.Equal(t, "/replay/providers/Microsoft.ResourceGraph/resources", requestPath)
+
+		require.Len(t, res.Responses["A"].Frames, 1)
+		frame := res.Responses["A"].Frames[0]
+		require.Len(t, frame.Fields, 1)
+		assert.Equal(t, "name", frame.Fields[0].Name)
+		canned := "canned"
+		assert.Equal(t, &canned, frame.Fields[0].At(0))
+	})
+
+	t.Run("should ignore the override unless allowed", func(t *testing.T) {
+		datasource := &AzureResourceGraphDatasource{}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), routeURL, tracer)
+		require.NoError(t, err)
+		require.Error(t, res.Responses["A"].Error)
+	})
+}
+
 func TestAddConfigData(t *testing.T) {
 	field := data.Field{}
 	dataLink := data.DataLink{Title: "View in Azure Portal", TargetBlank: true, URL: "http://ds"}
@@ -136,6 +404,63 @@ func TestAddConfigData(t *testing.T) {
 	}
 }
 
+func TestAddPortalLinks(t *testing.T) {
+	frame := data.Frame{Fields: []*data.Field{data.NewField("name", nil, []string{"vm1"})}}
+
+	t.Run("should link to the portal of the cloud", func(t *testing.T) {
+		frameWithLink, err := addPortalLinks(frame, setting.AzureChina, "/#blade/path")
+		require.NoError(t, err)
+		require.Len(t, frameWithLink.Fields[0].Config.Links, 1)
+		assert.Equal(t, "https://portal.azure.cn/#blade/path", frameWithLink.Fields[0].Config.Links[0].URL)
+	})
+
+	t.Run("should fail for an unknown cloud", func(t *testing.T) {
+		_, err := addPortalLinks(frame, "unknown", "/#blade/path")
+		require.Error(t, err)
+	})
+}
+
+func TestExecuteQueryChinaPortalLink(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"data":{"columns":[{"name":"name","type":"string"}],"rows":[["vm1"]]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{}
+	dsInfo := types.DatasourceInfo{Cloud: setting.AzureChina}
+	queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources"}}`)}}
+
+	res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+	require.NoError(t, err)
+	require.NoError(t, res.Responses["A"].Error)
+	require.Len(t, res.Responses["A"].Frames, 1)
+
+	links := res.Responses["A"].Frames[0].Fields[0].Config.Links
+	require.Len(t, links, 1)
+	assert.Equal(t, "https://portal.azure.cn/#blade/HubsExtension/ArgQueryBlade/query/resources", links[0].URL)
+}
+
+func TestApplyFieldAliases(t *testing.T) {
+	frame := data.NewFrame("",
+		data.NewField("name", nil, []string{"res1"}),
+		data.NewField("properties_storageProfile_osDisk_osType", nil, []string{"Linux"}),
+	)
+
+	applyFieldAliases(frame, map[string]string{
+		"properties_storageProfile_osDisk_osType": "OS",
+		"missingColumn": "Ignored",
+	})
+
+	assert.Nil(t, frame.Fields[0].Config)
+	require.NotNil(t, frame.Fields[1].Config)
+	assert.Equal(t, "OS", frame.Fields[1].Config.DisplayNameFromDS)
+	assert.Equal(t, "properties_storageProfile_osDisk_osType", frame.Fields[1].Name)
+}
+
 func TestGetAzurePortalUrl(t *testing.T) {
 	clouds := []string{setting.AzurePublic, setting.AzureChina, setting.AzureUSGovernment, setting.AzureGermany}
 	expectedAzurePortalUrl := map[string]interface{}{
@@ -168,6 +493,70 @@ func TestUnmarshalResponse400(t *testing.T) {
 	assert.Empty(t, res)
 }
 
+func TestUnmarshalResponseRetryable(t *testing.T) {
+	testCases := []struct {
+		desc                  string
+		statusCode            int
+		status                string
+		body                  string
+		header                http.Header
+		expectedCode          string
+		expectedCorrelationID string
+		retryable             bool
+	}{
+		{
+			desc:                  "throttled request is retryable",
+			statusCode:            http.StatusTooManyRequests,
+			status:                "429 Too Many Requests",
+			body:                  `{"error":{"code":"RateLimiting","message":"Please provide below info when asking for support: timestamp = 2022-01-01T00:00:00Z, correlationId = 0c1f2a7e."}}`,
+			expectedCode:          "RateLimiting",
+			expectedCorrelationID: "0c1f2a7e",
+			retryable:             true,
+		},
+		{
+			desc:                  "unavailable service is retryable",
+			statusCode:            http.StatusServiceUnavailable,
+			status:                "503 Service Unavailable",
+			body:                  "upstream unavailable",
+			header:                http.Header{"X-Ms-Correlation-Request-Id": []string{"5d2b-41c9"}},
+			expectedCode:          "",
+			expectedCorrelationID: "5d2b-41c9",
+			retryable:             true,
+		},
+		{
+			desc:         "invalid query is not retryable",
+			statusCode:   http.StatusBadRequest,
+			status:       "400 Bad Request",
+			body:         `{"error":{"code":"BadRequest","message":"Please provide below info when asking for support.","details":[{"code":"InvalidQuery","message":"Query is invalid."}]}}`,
+			expectedCode: "BadRequest",
+			retryable:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			datasource := &AzureResourceGraphDatasource{}
+			_, err := datasource.unmarshalResponse(&http.Response{
+				StatusCode: tc.statusCode,
+				Status:     tc.status,
+				Header:     tc.header,
+				Body:       io.NopCloser(strings.NewReader(tc.body)),
+			})
+
+			var argErr *AzureResourceGraphError
+			require.ErrorAs(t, err, &argErr)
+			assert.Equal(t, tc.retryable, argErr.Retryable)
+			assert.Equal(t, tc.expectedCode, argErr.Code)
+			assert.Equal(t, tc.status+". Azure Resource Graph error: "+tc.body, err.Error())
+
+			var supportErr AzureSupportError
+			require.ErrorAs(t, err, &supportErr)
+			assert.Equal(t, tc.expectedCode, supportErr.AzureErrorCode())
+			assert.Equal(t, tc.expectedCorrelationID, supportErr.AzureCorrelationID())
+		})
+	}
+}
+
 func TestUnmarshalResponse200Invalid(t *testing.T) {
 	datasource := &AzureResourceGraphDatasource{}
 	res, err := datasource.unmarshalResponse(&http.Response{
@@ -196,3 +585,496 @@ func TestUnmarshalResponse200(t *testing.T) {
 	assert.NoError(t, err2)
 	assert.Equal(t, expectedRes, res)
 }
+
+func TestExecuteTimeSeriesQueryAllowedOrgs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"data":{"columns":[{"name":"name","type":"string"}],"rows":[["res1"]]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{AllowedOrgs: []int64{1}}
+	queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources"}}`)}}
+
+	t.Run("should run the query for an allowed org", func(t *testing.T) {
+		dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic, OrgID: 1}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+	})
+
+	t.Run("should reject the query for an org not in the allowlist", func(t *testing.T) {
+		dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic, OrgID: 2}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.Error(t, res.Responses["A"].Error)
+		assert.Contains(t, res.Responses["A"].Error.Error(), "not allowed to run Azure Resource Graph queries")
+	})
+}
+
+func TestExecuteQuerySubscriptions(t *testing.T) {
+	var reqBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody = map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&reqBody))
+		_, err := w.Write([]byte(`{"data":{"columns":[{"name":"name","type":"string"}],"rows":[["res1"]]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{}
+	dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic}
+
+	t.Run("should scope the request to the selected subscriptions", func(t *testing.T) {
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"subscriptions": ["sub1", "sub2"], "azureResourceGraph": {"query": "resources"}}`)}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.Equal(t, []interface{}{"sub1", "sub2"}, reqBody["subscriptions"])
+	})
+
+	t.Run("should send an unscoped request when All subscriptions are selected", func(t *testing.T) {
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"subscriptions": ["$__all"], "azureResourceGraph": {"query": "resources"}}`)}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.NotContains(t, reqBody, "subscriptions")
+		assert.Equal(t, "resources", reqBody["query"])
+	})
+
+	t.Run("should scope the request to the default subscription of the datasource", func(t *testing.T) {
+		dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic, Settings: types.AzureMonitorSettings{SubscriptionId: "default-sub"}}
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources"}}`)}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.Equal(t, []interface{}{"default-sub"}, reqBody["subscriptions"])
+	})
+
+	t.Run("should send an unscoped request without any subscription", func(t *testing.T) {
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources"}}`)}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.NotContains(t, reqBody, "subscriptions")
+	})
+}
+
+func TestExecuteQueryDebugLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"data":{"columns":[{"name":"name","type":"string"}],"rows":[["res1"],["res2"]]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	var logged []map[string]interface{}
+	t.Cleanup(func() { logQueryDetails = azlog.Info })
+	logQueryDetails = func(msg string, args ...interface{}) {
+		entry := map[string]interface{}{}
+		for i := 0; i+1 < len(args); i += 2 {
+			entry[args[i].(string)] = args[i+1]
+		}
+		logged = append(logged, entry)
+	}
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+	datasource := &AzureResourceGraphDatasource{}
+	dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic}
+
+	t.Run("should not log the details without the debug flag", func(t *testing.T) {
+		logged = nil
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"subscriptions": ["sub1"], "azureResourceGraph": {"query": "resources"}}`)}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.Empty(t, logged)
+	})
+
+	t.Run("should log the details of queries with the debug flag", func(t *testing.T) {
+		logged = nil
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"subscriptions": ["sub1", "sub2"], "azureResourceGraph": {"query": "resources", "debug": true}}`)}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+
+		require.Len(t, logged, 1)
+		assert.Equal(t, "A", logged[0]["refId"])
+		assert.Equal(t, "resources", logged[0]["query"])
+		assert.Equal(t, "sub1,sub2", logged[0]["subscriptions"])
+		assert.Equal(t, http.StatusOK, logged[0]["status"])
+		assert.Equal(t, 2, logged[0]["rows"])
+		assert.IsType(t, time.Duration(0), logged[0]["duration"])
+	})
+}
+
+func TestResourceTypeCountQueryMode(t *testing.T) {
+	var reqBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody = map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&reqBody))
+		_, err := w.Write([]byte(`{"data":{"columns":[{"name":"type","type":"string"},{"name":"count_","type":"long"}],` +
+			`"rows":[["microsoft.compute/virtualmachines",3],["microsoft.storage/storageaccounts",2]]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{}
+	dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic}
+
+	t.Run("should count resources by type without explicit KQL", func(t *testing.T) {
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"queryMode": "resourceTypeCount"}}`)}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.Equal(t, "resources | summarize count() by type", reqBody["query"])
+
+		require.Len(t, res.Responses["A"].Frames, 1)
+		frame := res.Responses["A"].Frames[0]
+		require.Len(t, frame.Fields, 2)
+		assert.Equal(t, "type", frame.Fields[0].Name)
+		assert.Equal(t, "count_", frame.Fields[1].Name)
+		assert.Equal(t, 2, frame.Rows())
+		count := int64(3)
+		assert.Equal(t, &count, frame.Fields[1].At(0))
+	})
+
+	t.Run("should run an explicit query unchanged", func(t *testing.T) {
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"queryMode": "resourceTypeCount", "query": "resources | take 1"}}`)}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.Equal(t, "resources | take 1", reqBody["query"])
+	})
+}
+
+func TestExecuteQuerySkipToken(t *testing.T) {
+	var reqBody map[string]interface{}
+	skipToken := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody = map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&reqBody))
+		res := map[string]interface{}{
+			"data": map[string]interface{}{
+				"columns": []map[string]string{{"name": "name", "type": "string"}},
+				"rows":    [][]interface{}{{"res1"}},
+			},
+		}
+		if skipToken != "" {
+			res["$skipToken"] = skipToken
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(res))
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{}
+	// the row limit stops paging after the first page, so the skip token is returned to the caller
+	dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic, Settings: types.AzureMonitorSettings{ResourceGraphMaxRows: 1}}
+
+	execute := func(model string) *AzureResourceGraphMeta {
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(model)}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+		meta, ok := res.Responses["A"].Frames[0].Meta.Custom.(*AzureResourceGraphMeta)
+		require.True(t, ok)
+		return meta
+	}
+
+	t.Run("should surface the skip token when there are more results", func(t *testing.T) {
+		skipToken = "next-page"
+		meta := execute(`{"azureResourceGraph": {"query": "resources"}}`)
+		assert.Equal(t, "next-page", meta.SkipToken)
+		assert.Equal(t, []string{"string"}, meta.ColumnTypes)
+	})
+
+	t.Run("should have no skip token when the result is complete", func(t *testing.T) {
+		skipToken = ""
+		meta := execute(`{"azureResourceGraph": {"query": "resources"}}`)
+		assert.Empty(t, meta.SkipToken)
+	})
+
+	t.Run("should send the skip token of the query to continue from the next page", func(t *testing.T) {
+		skipToken = ""
+		execute(`{"azureResourceGraph": {"query": "resources", "skipToken": "next-page"}}`)
+		assert.Equal(t, map[string]interface{}{"resultFormat": "table", "$skipToken": "next-page"}, reqBody["options"])
+	})
+}
+
+func TestExecuteQueryPagination(t *testing.T) {
+	var skipTokens []interface{}
+	lastPage := 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&reqBody))
+		options := reqBody["options"].(map[string]interface{})
+		skipTokens = append(skipTokens, options["$skipToken"])
+
+		page := len(skipTokens)
+		res := map[string]interface{}{
+			"data": map[string]interface{}{
+				"columns": []map[string]string{{"name": "name", "type": "string"}},
+				"rows":    [][]interface{}{{fmt.Sprintf("res%d-1", page)}, {fmt.Sprintf("res%d-2", page)}},
+			},
+		}
+		if page < lastPage {
+			res["$skipToken"] = fmt.Sprintf("page-%d", page+1)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(res))
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{}
+	queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources"}}`)}}
+
+	execute := func(t *testing.T, dsInfo types.DatasourceInfo) *data.Frame {
+		skipTokens = nil
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+		return res.Responses["A"].Frames[0]
+	}
+
+	t.Run("should return the rows of every page", func(t *testing.T) {
+		lastPage = 2
+		frame := execute(t, types.DatasourceInfo{Cloud: setting.AzurePublic})
+
+		assert.Equal(t, []interface{}{nil, "page-2"}, skipTokens)
+		require.Equal(t, 4, frame.Rows())
+		names := make([]string, 0, frame.Rows())
+		for i := 0; i < frame.Rows(); i++ {
+			names = append(names, *frame.Fields[0].At(i).(*string))
+		}
+		assert.Equal(t, []string{"res1-1", "res1-2", "res2-1", "res2-2"}, names)
+
+		meta, ok := frame.Meta.Custom.(*AzureResourceGraphMeta)
+		require.True(t, ok)
+		assert.Empty(t, meta.SkipToken)
+		assert.Empty(t, frame.Meta.Notices)
+	})
+
+	t.Run("should stop at the row limit of the datasource", func(t *testing.T) {
+		lastPage = 10
+		frame := execute(t, types.DatasourceInfo{Cloud: setting.AzurePublic, Settings: types.AzureMonitorSettings{ResourceGraphMaxRows: 3}})
+
+		assert.Len(t, skipTokens, 2)
+		assert.Equal(t, 3, frame.Rows())
+
+		meta, ok := frame.Meta.Custom.(*AzureResourceGraphMeta)
+		require.True(t, ok)
+		assert.Equal(t, "page-3", meta.SkipToken)
+		require.Len(t, frame.Meta.Notices, 1)
+		assert.Equal(t, data.NoticeSeverityWarning, frame.Meta.Notices[0].Severity)
+		assert.Contains(t, frame.Meta.Notices[0].Text, "limited to 3 rows")
+	})
+
+	t.Run("should truncate a last page that goes past the row limit", func(t *testing.T) {
+		lastPage = 2
+		frame := execute(t, types.DatasourceInfo{Cloud: setting.AzurePublic, Settings: types.AzureMonitorSettings{ResourceGraphMaxRows: 3}})
+
+		assert.Len(t, skipTokens, 2)
+		require.Equal(t, 3, frame.Rows())
+		names := make([]string, 0, frame.Rows())
+		for i := 0; i < frame.Rows(); i++ {
+			names = append(names, *frame.Fields[0].At(i).(*string))
+		}
+		assert.Equal(t, []string{"res1-1", "res1-2", "res2-1"}, names)
+
+		meta, ok := frame.Meta.Custom.(*AzureResourceGraphMeta)
+		require.True(t, ok)
+		assert.Empty(t, meta.SkipToken)
+		require.Len(t, frame.Meta.Notices, 1)
+		assert.Contains(t, frame.Meta.Notices[0].Text, "limited to 3 rows")
+	})
+}
+
+func TestExecuteQueryZeroRows(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"totalRecords":0,"count":0,"data":{"columns":[{"name":"timestamp","type":"datetime"},` +
+			`{"name":"name","type":"string"},{"name":"cores","type":"long"}],"rows":[]},"resultTruncated":"false"}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{}
+	dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic}
+
+	tests := []struct {
+		name      string
+		queryJSON string
+	}{
+		{
+			name:      "table",
+			queryJSON: `{"azureResourceGraph": {"query": "resources", "resultFormat": "table"}}`,
+		},
+		{
+			name:      "time series",
+			queryJSON: `{"azureResourceGraph": {"query": "resources", "resultFormat": "time_series"}}`,
+		},
+		{
+			name:      "time series split by a column",
+			queryJSON: `{"azureResourceGraph": {"query": "resources", "resultFormat": "time_series", "seriesBy": "name"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run("should return the columns of an empty "+tt.name+" result", func(t *testing.T) {
+			queries := []backend.DataQuery{{RefID: "A", JSON: []byte(tt.queryJSON)}}
+			res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+			require.NoError(t, err)
+			require.NoError(t, res.Responses["A"].Error)
+			require.Len(t, res.Responses["A"].Frames, 1)
+
+			frame := res.Responses["A"].Frames[0]
+			assert.Equal(t, 0, frame.Rows())
+			require.Len(t, frame.Fields, 3)
+			assert.Equal(t, "timestamp", frame.Fields[0].Name)
+			assert.Equal(t, data.FieldTypeNullableTime, frame.Fields[0].Type())
+			assert.Equal(t, "name", frame.Fields[1].Name)
+			assert.Equal(t, data.FieldTypeNullableString, frame.Fields[1].Type())
+			assert.Equal(t, "cores", frame.Fields[2].Name)
+			assert.Equal(t, data.FieldTypeNullableInt64, frame.Fields[2].Type())
+			assert.Empty(t, frame.Meta.Notices)
+		})
+	}
+}
+
+func TestExecuteQueryDatasourceMeta(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"data":{"columns":[{"name":"name","type":"string"}],"rows":[["res1"]]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{}
+	dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic, DatasourceUID: "arg-uid", DatasourceName: "Production ARG"}
+	queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources"}}`)}}
+
+	res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+	require.NoError(t, err)
+	require.NoError(t, res.Responses["A"].Error)
+	require.Len(t, res.Responses["A"].Frames, 1)
+
+	meta, ok := res.Responses["A"].Frames[0].Meta.Custom.(*AzureResourceGraphMeta)
+	require.True(t, ok)
+	assert.Equal(t, "arg-uid", meta.DatasourceUID)
+	assert.Equal(t, "Production ARG", meta.DatasourceName)
+}
+
+func TestDeprecatedKQLNotices(t *testing.T) {
+	deprecated := []string{"mvexpand", "!has"}
+
+	t.Run("should return a notice for each deprecated token", func(t *testing.T) {
+		notices := deprecatedKQLNotices("resources | mvexpand tags | where name !has 'test'", deprecated)
+		require.Len(t, notices, 2)
+		assert.Equal(t, data.NoticeSeverityInfo, notices[0].Severity)
+		assert.Contains(t, notices[0].Text, `"mvexpand"`)
+		assert.Contains(t, notices[1].Text, `"!has"`)
+	})
+
+	t.Run("should not match a token inside another word", func(t *testing.T) {
+		notices := deprecatedKQLNotices("resources | mv-expand mvexpanded = tags", deprecated)
+		assert.Empty(t, notices)
+	})
+
+	t.Run("should attach the notice to the frame", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write([]byte(`{"data":{"columns":[{"name":"name","type":"string"}],"rows":[["res1"]]}}`))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(srv.Close)
+
+		tracer, err := tracing.InitializeTracerForTest()
+		require.NoError(t, err)
+
+		datasource := &AzureResourceGraphDatasource{DeprecatedKQL: deprecated}
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources | mvexpand tags"}}`)}}
+		dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic}
+
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+		require.NotNil(t, res.Responses["A"].Frames[0].Meta)
+		notices := res.Responses["A"].Frames[0].Meta.Notices
+		require.Len(t, notices, 1)
+		assert.Contains(t, notices[0].Text, "mvexpand")
+	})
+}
+
+func TestDetectResultFormat(t *testing.T) {
+	t.Run("should pick time_series when there is a time column", func(t *testing.T) {
+		frame := data.NewFrame("",
+			data.NewField("timestamp", nil, []*time.Time{}),
+			data.NewField("count", nil, []*float64{}),
+		)
+		assert.Equal(t, types.TimeSeries, detectResultFormat(frame))
+	})
+
+	t.Run("should pick table when there is no time column", func(t *testing.T) {
+		frame := data.NewFrame("",
+			data.NewField("name", nil, []*string{}),
+			data.NewField("count", nil, []*float64{}),
+		)
+		assert.Equal(t, types.Table, detectResultFormat(frame))
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"data":{"columns":[{"name":"timestamp","type":"datetime"},{"name":"location","type":"string"},{"name":"count","type":"long"}],` +
+			`"rows":[["2022-01-01T00:00:00Z","eastus",1],["2022-01-01T00:00:00Z","westus",2]]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	execute := func(t *testing.T, resultFormat string) *data.Frame {
+		datasource := &AzureResourceGraphDatasource{}
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(fmt.Sprintf(`{"azureResourceGraph": {"query": "resources", "resultFormat": %q}}`, resultFormat))}}
+		dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic}
+
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+		return res.Responses["A"].Frames[0]
+	}
+
+	t.Run("should convert an auto query with a time column to a wide time series", func(t *testing.T) {
+		frame := execute(t, types.AutoResultFormat)
+		assert.Equal(t, 1, frame.Rows())
+		assert.Len(t, frame.Fields, 3)
+	})
+
+	t.Run("should return the long frame of an explicit time_series query as it is", func(t *testing.T) {
+		frame := execute(t, types.TimeSeries)
+		assert.Equal(t, 2, frame.Rows())
+		require.Len(t, frame.Fields, 3)
+		assert.Equal(t, "location", frame.Fields[1].Name)
+	})
+}
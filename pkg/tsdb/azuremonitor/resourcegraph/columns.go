@@ -0,0 +1,51 @@
+package resourcegraph
+
+import (
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/loganalytics"
+)
+
+// dropColumns removes the fields named in columns from frame, names are compared case-insensitively.
+// The matching entries of the Azure column types are removed as well so they stay aligned with the fields.
+// Columns are matched by name only, a query can still return the same data under another name.
+func dropColumns(frame *data.Frame, columns []string) {
+	if len(columns) == 0 {
+		return
+	}
+
+	var laMeta *loganalytics.LogAnalyticsMeta
+	if frame.Meta != nil {
+		laMeta, _ = frame.Meta.Custom.(*loganalytics.LogAnalyticsMeta)
+	}
+	var colTypes []string
+	if laMeta != nil && len(laMeta.ColumnTypes) == len(frame.Fields) {
+		colTypes = laMeta.ColumnTypes
+	}
+
+	fields := make([]*data.Field, 0, len(frame.Fields))
+	keptTypes := make([]string, 0, len(colTypes))
+	for i, field := range frame.Fields {
+		if isDropped(field.Name, columns) {
+			continue
+		}
+		fields = append(fields, field)
+		if colTypes != nil {
+			keptTypes = append(keptTypes, colTypes[i])
+		}
+	}
+	frame.Fields = fields
+	if colTypes != nil {
+		laMeta.ColumnTypes = keptTypes
+	}
+}
+
+func isDropped(name string, columns []string) bool {
+	for _, column := range columns {
+		if strings.EqualFold(name, column) {
+			return true
+		}
+	}
+	return false
+}
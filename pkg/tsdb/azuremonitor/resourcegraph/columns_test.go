@@ -0,0 +1,76 @@
+package resourcegraph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/loganalytics"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropColumns(t *testing.T) {
+	newFrame := func(t *testing.T) *data.Frame {
+		table := types.AzureResponseTable{}
+		err := json.Unmarshal([]byte(`{
+			"columns": [{"name": "name", "type": "string"}, {"name": "privateIp", "type": "string"}, {"name": "count_", "type": "long"}],
+			"rows": [["vm-1", "10.0.0.1", 1]]
+		}`), &table)
+		require.NoError(t, err)
+		frame, err := loganalytics.ResponseTableToFrame(&table)
+		require.NoError(t, err)
+		return frame
+	}
+
+	t.Run("should keep all columns without a policy", func(t *testing.T) {
+		frame := newFrame(t)
+		dropColumns(frame, nil)
+		assert.Len(t, frame.Fields, 3)
+	})
+
+	t.Run("should remove the configured columns and their column types", func(t *testing.T) {
+		frame := newFrame(t)
+		dropColumns(frame, []string{"PrivateIP", "unknown"})
+
+		require.Len(t, frame.Fields, 2)
+		assert.Equal(t, "name", frame.Fields[0].Name)
+		assert.Equal(t, "count_", frame.Fields[1].Name)
+		assert.Equal(t, []string{"string", "long"}, frame.Meta.Custom.(*loganalytics.LogAnalyticsMeta).ColumnTypes)
+	})
+}
+
+func TestExecuteQueryDropColumns(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"data":{"columns":[{"name":"name","type":"string"},{"name":"publicIp","type":"string"}],"rows":[["vm-1","203.0.113.7"]]}}`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+	datasource := &AzureResourceGraphDatasource{}
+	dsInfo := types.DatasourceInfo{
+		Cloud:    setting.AzurePublic,
+		Settings: types.AzureMonitorSettings{ResourceGraphDropColumns: []string{"publicIp"}},
+	}
+
+	queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources", "resultFormat": "table"}}`)}}
+	res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+	require.NoError(t, err)
+	require.NoError(t, res.Responses["A"].Error)
+
+	frames := res.Responses["A"].Frames
+	require.Len(t, frames, 1)
+	require.Len(t, frames[0].Fields, 1)
+	assert.Equal(t, "name", frames[0].Fields[0].Name)
+	field, _ := frames[0].FieldByName("publicIp")
+	assert.Nil(t, field)
+}
@@ -0,0 +1,97 @@
+package resourcegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// AzureSupportError is implemented by errors carrying what Azure support asks for about a failed request.
+type AzureSupportError interface {
+	error
+	// AzureErrorCode is the top-level Azure error code, empty when unknown.
+	AzureErrorCode() string
+	// AzureCorrelationID identifies the failed request for Azure support, empty when unknown.
+	AzureCorrelationID() string
+}
+
+// AzureResourceGraphError is returned when the Azure Resource Graph API responds
+// with a non-2xx status.
+type AzureResourceGraphError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	// Code is the top-level Azure error code, e.g. BadRequest. Empty if the body isn't an Azure error.
+	Code string
+	// CorrelationID identifies the failed request for Azure support. It is parsed from the error message,
+	// or taken from the x-ms-correlation-request-id header when the message has none.
+	CorrelationID string
+	// Retryable reports whether sending the same request again may succeed,
+	// e.g. after throttling or a transient service failure.
+	Retryable bool
+}
+
+func (e *AzureResourceGraphError) Error() string {
+	return fmt.Sprintf("%s. Azure Resource Graph error: %s", e.Status, e.Body)
+}
+
+func (e *AzureResourceGraphError) AzureErrorCode() string {
+	return e.Code
+}
+
+func (e *AzureResourceGraphError) AzureCorrelationID() string {
+	return e.CorrelationID
+}
+
+// correlationIDRegex matches the correlation ID in Azure error messages, e.g.
+// "Please provide below info when asking for support: timestamp = ..., correlationId = 0c1f2a7e."
+var correlationIDRegex = regexp.MustCompile(`correlationId\s*[=:]\s*([0-9A-Za-z-]+)`)
+
+type azureErrorBody struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Details []struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func newAzureResourceGraphError(res *http.Response, body []byte) *AzureResourceGraphError {
+	argErr := &AzureResourceGraphError{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Body:       string(body),
+	}
+
+	var parsed azureErrorBody
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		argErr.Code = parsed.Error.Code
+		if match := correlationIDRegex.FindStringSubmatch(parsed.Error.Message); match != nil {
+			argErr.CorrelationID = match[1]
+		}
+	}
+	if argErr.CorrelationID == "" {
+		argErr.CorrelationID = res.Header.Get("x-ms-correlation-request-id")
+	}
+	argErr.Retryable = isRetryableStatus(res.StatusCode)
+
+	return argErr
+}
+
+// isRetryableStatus reports whether a response status is worth retrying. Throttling and
+// transient server errors are; client errors such as an invalid query are not.
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
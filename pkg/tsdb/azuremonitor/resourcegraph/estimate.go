@@ -0,0 +1,98 @@
+package resourcegraph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/azlog"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
+)
+
+// estimateProbeLimit is the number of rows the probe run of an estimation asks for.
+const estimateProbeLimit = 1000
+
+// ErrOrgNotAllowed is returned when the org of a request may not use Azure Resource Graph.
+var ErrOrgNotAllowed = errors.New("organization is not allowed to run Azure Resource Graph queries")
+
+// EstimateRequest is the body of a query estimation request. The query is run as is,
+// template variables and macros have to be interpolated by the caller.
+type EstimateRequest struct {
+	Query         string   `json:"query"`
+	Subscriptions []string `json:"subscriptions"`
+}
+
+// QueryEstimate is the size of a query result, as reported by a probe run limited to estimateProbeLimit rows.
+type QueryEstimate struct {
+	// Rows is the number of rows the probe returned.
+	Rows int64 `json:"rows"`
+	// AtLeast is set when the probe hit its limit, the full result has Rows rows or more.
+	AtLeast bool `json:"atLeast"`
+	// ResultTruncated is set when Azure truncated the probe result.
+	ResultTruncated bool `json:"resultTruncated"`
+}
+
+// Estimate runs the query with a row limit and derives an estimate of its result size from
+// the response, so that the query editor can warn about large results before running it.
+func (e *AzureResourceGraphDatasource) Estimate(ctx context.Context, estimateReq EstimateRequest, dsInfo types.DatasourceInfo,
+	client *http.Client, dsURL string) (*QueryEstimate, error) {
+	if !e.isOrgAllowed(dsInfo.OrgID) {
+		return nil, ErrOrgNotAllowed
+	}
+	if strings.TrimSpace(estimateReq.Query) == "" {
+		return nil, errors.New("query is empty")
+	}
+
+	if e.AllowURLOverride && dsInfo.Settings.ResourceGraphURL != "" {
+		dsURL = dsInfo.Settings.ResourceGraphURL
+	}
+
+	body := map[string]interface{}{
+		"query":   fmt.Sprintf("%s\n| limit %d", estimateReq.Query, estimateProbeLimit),
+		"options": map[string]string{"resultFormat": "table"},
+	}
+	// like queries, an estimate without subscriptions runs on the default subscription of the datasource
+	subscriptions := scopeSubscriptions(estimateReq.Subscriptions, dsInfo)
+	if len(subscriptions) > 0 && !includesAllSubscriptions(subscriptions) {
+		body["subscriptions"] = subscriptions
+	}
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := e.createRequest(ctx, dsInfo, reqBody, dsURL)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Add("api-version", argAPIVersion)
+	req.URL.Path = path.Join(req.URL.Path, argQueryProviderName)
+	req.URL.RawQuery = params.Encode()
+
+	azlog.Debug("AzureResourceGraph estimate", "Request ApiURL", req.URL.String())
+	res, err := doWithRetry(ctx, client, req, e.MaxRetries)
+	if err != nil {
+		return nil, err
+	}
+	argResponse, err := e.unmarshalResponse(res)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := argResponse.Count
+	if rows == 0 {
+		rows = int64(len(argResponse.Data.Rows))
+	}
+	truncated := argResponse.ResultTruncated == "true"
+	return &QueryEstimate{
+		Rows:            rows,
+		AtLeast:         truncated || rows >= estimateProbeLimit,
+		ResultTruncated: truncated,
+	}, nil
+}
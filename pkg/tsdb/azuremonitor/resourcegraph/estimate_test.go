@@ -0,0 +1,96 @@
+package resourcegraph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name             string
+		response         string
+		expectedEstimate QueryEstimate
+	}{
+		{
+			name:             "should count the rows of a small result",
+			response:         `{"count": 2, "resultTruncated": "false", "data": {"columns": [{"name": "name", "type": "string"}], "rows": [["vm-1"], ["vm-2"]]}}`,
+			expectedEstimate: QueryEstimate{Rows: 2},
+		},
+		{
+			name:             "should flag a result that fills the probe",
+			response:         `{"count": 1000, "resultTruncated": "false", "data": {"columns": [], "rows": []}}`,
+			expectedEstimate: QueryEstimate{Rows: estimateProbeLimit, AtLeast: true},
+		},
+		{
+			name:             "should flag a truncated result",
+			response:         `{"count": 400, "resultTruncated": "true", "data": {"columns": [], "rows": []}}`,
+			expectedEstimate: QueryEstimate{Rows: 400, AtLeast: true, ResultTruncated: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestBody map[string]interface{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&requestBody))
+				_, err := w.Write([]byte(tt.response))
+				require.NoError(t, err)
+			}))
+			t.Cleanup(srv.Close)
+
+			datasource := &AzureResourceGraphDatasource{}
+			estimate, err := datasource.Estimate(context.Background(), EstimateRequest{Query: "resources", Subscriptions: []string{"sub-1"}},
+				types.DatasourceInfo{}, srv.Client(), srv.URL)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedEstimate, *estimate)
+
+			assert.Equal(t, "resources\n| limit 1000", requestBody["query"])
+			assert.Equal(t, []interface{}{"sub-1"}, requestBody["subscriptions"])
+		})
+	}
+
+	t.Run("should scope the probe like queries", func(t *testing.T) {
+		var requestBody map[string]interface{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestBody = map[string]interface{}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&requestBody))
+			_, err := w.Write([]byte(`{"count": 0, "resultTruncated": "false", "data": {"columns": [], "rows": []}}`))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(srv.Close)
+
+		datasource := &AzureResourceGraphDatasource{}
+		estimate := func(t *testing.T, subscriptions []string, dsInfo types.DatasourceInfo) {
+			_, err := datasource.Estimate(context.Background(), EstimateRequest{Query: "resources", Subscriptions: subscriptions},
+				dsInfo, srv.Client(), srv.URL)
+			require.NoError(t, err)
+		}
+		withDefault := types.DatasourceInfo{Settings: types.AzureMonitorSettings{SubscriptionId: "default-sub"}}
+
+		estimate(t, nil, withDefault)
+		assert.Equal(t, []interface{}{"default-sub"}, requestBody["subscriptions"])
+
+		estimate(t, []string{"sub-1"}, withDefault)
+		assert.Equal(t, []interface{}{"sub-1"}, requestBody["subscriptions"])
+
+		estimate(t, nil, types.DatasourceInfo{})
+		assert.NotContains(t, requestBody, "subscriptions")
+
+		estimate(t, []string{allSubscriptions}, withDefault)
+		assert.NotContains(t, requestBody, "subscriptions")
+	})
+
+	t.Run("should reject orgs that aren't allowed", func(t *testing.T) {
+		datasource := &AzureResourceGraphDatasource{AllowedOrgs: []int64{2}}
+		_, err := datasource.Estimate(context.Background(), EstimateRequest{Query: "resources"},
+			types.DatasourceInfo{OrgID: 1}, http.DefaultClient, "http://127.0.0.1:1")
+		require.ErrorIs(t, err, ErrOrgNotAllowed)
+	})
+}
@@ -0,0 +1,102 @@
+package resourcegraph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Column names of frames in the long format.
+const (
+	longLabelColumn  = "label"
+	longMetricColumn = "metric"
+	longValueColumn  = "value"
+)
+
+// toLongFormat reshapes a wide frame, e.g. the result of a summarize, into a long frame with a row per
+// row and numeric column of frame. The label column holds the values of the string columns of the row,
+// joined with ", " when there are several, and the metric column the name of the numeric column.
+// Time columns are kept as they are.
+func toLongFormat(frame *data.Frame) (*data.Frame, error) {
+	var timeFields, labelFields, valueFields []*data.Field
+	for _, field := range frame.Fields {
+		switch {
+		case field.Type().Time():
+			timeFields = append(timeFields, field)
+		case field.Type().Numeric():
+			valueFields = append(valueFields, field)
+		default:
+			labelFields = append(labelFields, field)
+		}
+	}
+	if len(valueFields) == 0 {
+		return nil, fmt.Errorf("the query result has no numeric column")
+	}
+
+	times := make([]*data.Field, len(timeFields))
+	for i, field := range timeFields {
+		times[i] = data.NewFieldFromFieldType(field.Type(), 0)
+		times[i].Name = field.Name
+		times[i].Config = field.Config
+	}
+	labels := make([]string, 0, frame.Rows()*len(valueFields))
+	metrics := make([]string, 0, frame.Rows()*len(valueFields))
+	values := make([]*float64, 0, frame.Rows()*len(valueFields))
+
+	metricNames := make([]string, len(valueFields))
+	for i, field := range valueFields {
+		metricNames[i] = metricName(field.Name)
+	}
+
+	for row := 0; row < frame.Rows(); row++ {
+		parts := make([]string, 0, len(labelFields))
+		for _, field := range labelFields {
+			if v, ok := field.ConcreteAt(row); ok {
+				parts = append(parts, fmt.Sprint(v))
+			} else {
+				parts = append(parts, "")
+			}
+		}
+		label := strings.Join(parts, ", ")
+
+		for i, field := range valueFields {
+			value, err := field.NullableFloatAt(row)
+			if err != nil {
+				return nil, err
+			}
+			for j, timeField := range timeFields {
+				times[j].Append(timeField.CopyAt(row))
+			}
+			labels = append(labels, label)
+			metrics = append(metrics, metricNames[i])
+			values = append(values, value)
+		}
+	}
+
+	fields := append(times,
+		data.NewField(longLabelColumn, nil, labels),
+		data.NewField(longMetricColumn, nil, metrics),
+		data.NewField(longValueColumn, nil, values),
+	)
+	long := data.NewFrame(frame.Name, fields...)
+	if frame.Meta != nil {
+		// the custom metadata describes the columns of the wide frame
+		meta := *frame.Meta
+		meta.Custom = nil
+		long.Meta = &meta
+	}
+	return long, nil
+}
+
+// metricName returns the name of a numeric column in the metric column. Default aggregation
+// column names are turned back into the aggregation, e.g. avg_cores into avg(cores) and count_
+// into count(), other names are kept.
+func metricName(column string) string {
+	for _, prefix := range aggregationPrefixes {
+		if strings.HasPrefix(column, prefix) {
+			return strings.TrimSuffix(prefix, "_") + "(" + strings.TrimPrefix(column, prefix) + ")"
+		}
+	}
+	return column
+}
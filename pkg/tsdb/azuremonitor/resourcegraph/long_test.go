@@ -0,0 +1,92 @@
+package resourcegraph
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/loganalytics"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToLongFormat(t *testing.T) {
+	newFrame := func(t *testing.T, raw string) *data.Frame {
+		t.Helper()
+		table := types.AzureResponseTable{}
+		require.NoError(t, json.Unmarshal([]byte(raw), &table))
+		frame, err := loganalytics.ResponseTableToFrame(&table)
+		require.NoError(t, err)
+		return frame
+	}
+	float := func(v float64) *float64 { return &v }
+
+	t.Run("should reshape a wide aggregation result into label, metric and value columns", func(t *testing.T) {
+		frame := newFrame(t, `{
+			"columns": [
+				{"name": "type", "type": "string"}, {"name": "location", "type": "string"},
+				{"name": "count_", "type": "long"}, {"name": "avg_cores", "type": "real"}
+			],
+			"rows": [["vm", "eastus", 3, 4.5], ["disk", "westus", 2, null]]
+		}`)
+
+		long, err := toLongFormat(frame)
+		require.NoError(t, err)
+
+		require.Len(t, long.Fields, 3)
+		assert.Equal(t, "label", long.Fields[0].Name)
+		assert.Equal(t, "metric", long.Fields[1].Name)
+		assert.Equal(t, "value", long.Fields[2].Name)
+		require.Equal(t, 4, long.Rows())
+
+		expected := []struct {
+			label  string
+			metric string
+			value  *float64
+		}{
+			{"vm, eastus", "count()", float(3)},
+			{"vm, eastus", "avg(cores)", float(4.5)},
+			{"disk, westus", "count()", float(2)},
+			{"disk, westus", "avg(cores)", nil},
+		}
+		for i, row := range expected {
+			assert.Equal(t, row.label, long.Fields[0].At(i))
+			assert.Equal(t, row.metric, long.Fields[1].At(i))
+			assert.Equal(t, row.value, long.Fields[2].At(i))
+		}
+	})
+
+	t.Run("should keep the time columns and explicitly named metrics", func(t *testing.T) {
+		frame := newFrame(t, `{
+			"columns": [{"name": "timestamp", "type": "datetime"}, {"name": "vms", "type": "long"}],
+			"rows": [["2022-03-01T12:00:00Z", 7]]
+		}`)
+
+		long, err := toLongFormat(frame)
+		require.NoError(t, err)
+
+		require.Len(t, long.Fields, 4)
+		assert.Equal(t, "timestamp", long.Fields[0].Name)
+		ts := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+		assert.Equal(t, &ts, long.Fields[0].At(0))
+		assert.Equal(t, "", long.Fields[1].At(0))
+		assert.Equal(t, "vms", long.Fields[2].At(0))
+		assert.Equal(t, float(7), long.Fields[3].At(0))
+	})
+
+	t.Run("should fail without numeric columns", func(t *testing.T) {
+		frame := newFrame(t, `{"columns": [{"name": "name", "type": "string"}], "rows": [["vm-1"]]}`)
+
+		_, err := toLongFormat(frame)
+		assert.Error(t, err)
+	})
+}
+
+func TestMetricName(t *testing.T) {
+	assert.Equal(t, "count()", metricName("count_"))
+	assert.Equal(t, "avg(cores)", metricName("avg_cores"))
+	assert.Equal(t, "countif(running)", metricName("countif_running"))
+	assert.Equal(t, "vms", metricName("vms"))
+}
@@ -0,0 +1,140 @@
+package resourcegraph
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// mergeResponses merges the frames of queries that set the same mergeOn column into a single
+// wide frame, returned on the first of these queries. The other queries of the group get an
+// empty response. Groups where a query failed or didn't return exactly one frame are left as is.
+func mergeResponses(responses backend.Responses, queries []*AzureResourceGraphQuery) {
+	var keys []string
+	groups := map[string][]*AzureResourceGraphQuery{}
+	for _, query := range queries {
+		if query.MergeOn == "" {
+			continue
+		}
+		if _, ok := groups[query.MergeOn]; !ok {
+			keys = append(keys, query.MergeOn)
+		}
+		groups[query.MergeOn] = append(groups[query.MergeOn], query)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+
+		frames := make([]*data.Frame, 0, len(group))
+		for _, query := range group {
+			res := responses[query.RefID]
+			if res.Error != nil || len(res.Frames) != 1 {
+				frames = nil
+				break
+			}
+			res.Frames[0].RefID = query.RefID
+			frames = append(frames, res.Frames[0])
+		}
+		if frames == nil {
+			continue
+		}
+
+		merged, err := mergeFrames(frames, key)
+		if err != nil {
+			for _, frame := range frames {
+				frame.AppendNotices(data.Notice{
+					Severity: data.NoticeSeverityWarning,
+					Text:     fmt.Sprintf("The results could not be merged: %s", err),
+				})
+			}
+			continue
+		}
+
+		responses[group[0].RefID] = backend.DataResponse{Frames: data.Frames{merged}}
+		for _, query := range group[1:] {
+			responses[query.RefID] = backend.DataResponse{}
+		}
+	}
+}
+
+// mergeFrames outer joins frames on the key column. The result has one row per key value, in
+// order of first appearance, and the columns of every frame; values missing from a frame are
+// null. Rows with a null key are dropped, and when a key repeats within a frame its last row
+// wins. Columns whose name is already taken are prefixed with the RefID of their frame.
+func mergeFrames(frames []*data.Frame, key string) (*data.Frame, error) {
+	first, _ := frames[0].FieldByName(key)
+	if first == nil {
+		return nil, fmt.Errorf("query %s has no %s column", frames[0].RefID, key)
+	}
+
+	var keyValues []interface{}
+	rowOf := map[interface{}]int{}
+	frameRows := make([][]int, len(frames))
+	for i, frame := range frames {
+		keyField, _ := frame.FieldByName(key)
+		if keyField == nil {
+			return nil, fmt.Errorf("query %s has no %s column", frame.RefID, key)
+		}
+		if keyField.Type().NonNullableType() != first.Type().NonNullableType() {
+			return nil, fmt.Errorf("the %s column of query %s is %s, expected %s", key, frame.RefID, keyField.Type(), first.Type())
+		}
+
+		frameRows[i] = make([]int, keyField.Len())
+		for row := 0; row < keyField.Len(); row++ {
+			value, ok := keyField.ConcreteAt(row)
+			if !ok {
+				frameRows[i][row] = -1
+				continue
+			}
+			idx, ok := rowOf[value]
+			if !ok {
+				idx = len(keyValues)
+				rowOf[value] = idx
+				keyValues = append(keyValues, value)
+			}
+			frameRows[i][row] = idx
+		}
+	}
+
+	keyField := data.NewFieldFromFieldType(first.Type().NullableType(), len(keyValues))
+	keyField.Name = key
+	keyField.Config = first.Config
+	for idx, value := range keyValues {
+		keyField.SetConcrete(idx, value)
+	}
+
+	merged := data.NewFrame(frames[0].Name, keyField)
+	merged.RefID = frames[0].RefID
+	names := map[string]bool{key: true}
+	for i, frame := range frames {
+		for _, field := range frame.Fields {
+			if field.Name == key {
+				continue
+			}
+			out := data.NewFieldFromFieldType(field.Type().NullableType(), len(keyValues))
+			out.Name = field.Name
+			if names[out.Name] {
+				out.Name = frame.RefID + "." + field.Name
+			}
+			names[out.Name] = true
+			out.Labels = field.Labels
+			out.Config = field.Config
+
+			for row, idx := range frameRows[i] {
+				if idx < 0 {
+					continue
+				}
+				if value, ok := field.ConcreteAt(row); ok {
+					out.SetConcrete(idx, value)
+				}
+			}
+			merged.Fields = append(merged.Fields, out)
+		}
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,82 @@
+package resourcegraph
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+func TestMergeResponses(t *testing.T) {
+	newResponses := func() backend.Responses {
+		return backend.Responses{
+			"A": {Frames: data.Frames{data.NewFrame("",
+				data.NewField("name", nil, []*string{strPtr("vm-1"), strPtr("vm-2")}),
+				data.NewField("location", nil, []*string{strPtr("westeurope"), strPtr("eastus")}),
+			)}},
+			"B": {Frames: data.Frames{data.NewFrame("",
+				data.NewField("name", nil, []*string{strPtr("vm-2"), strPtr("vm-3")}),
+				data.NewField("location", nil, []*string{strPtr("eastus"), strPtr("northeurope")}),
+				data.NewField("disks", nil, []*int64{int64Ptr(2), int64Ptr(1)}),
+			)}},
+		}
+	}
+
+	t.Run("should outer join the frames on the key column", func(t *testing.T) {
+		responses := newResponses()
+		mergeResponses(responses, []*AzureResourceGraphQuery{{RefID: "A", MergeOn: "name"}, {RefID: "B", MergeOn: "name"}})
+
+		expected := data.NewFrame("",
+			data.NewField("name", nil, []*string{strPtr("vm-1"), strPtr("vm-2"), strPtr("vm-3")}),
+			data.NewField("location", nil, []*string{strPtr("westeurope"), strPtr("eastus"), nil}),
+			data.NewField("B.location", nil, []*string{nil, strPtr("eastus"), strPtr("northeurope")}),
+			data.NewField("disks", nil, []*int64{nil, int64Ptr(2), int64Ptr(1)}),
+		)
+		expected.RefID = "A"
+
+		require.Len(t, responses["A"].Frames, 1)
+		if diff := cmp.Diff(expected, responses["A"].Frames[0], data.FrameTestCompareOptions()...); diff != "" {
+			t.Errorf("Result mismatch (-want +got):\n%s", diff)
+		}
+		assert.Empty(t, responses["B"].Frames)
+		assert.NoError(t, responses["B"].Error)
+	})
+
+	t.Run("should keep the frames separate by default", func(t *testing.T) {
+		responses := newResponses()
+		mergeResponses(responses, []*AzureResourceGraphQuery{{RefID: "A"}, {RefID: "B"}})
+
+		assert.Equal(t, newResponses(), responses)
+	})
+
+	t.Run("should not merge when a query failed", func(t *testing.T) {
+		responses := newResponses()
+		responses["B"] = backend.DataResponse{Error: errors.New("bad request")}
+		mergeResponses(responses, []*AzureResourceGraphQuery{{RefID: "A", MergeOn: "name"}, {RefID: "B", MergeOn: "name"}})
+
+		require.Len(t, responses["A"].Frames, 1)
+		assert.Len(t, responses["A"].Frames[0].Fields, 2)
+		assert.Error(t, responses["B"].Error)
+	})
+
+	t.Run("should add a notice when a frame has no key column", func(t *testing.T) {
+		responses := newResponses()
+		mergeResponses(responses, []*AzureResourceGraphQuery{{RefID: "A", MergeOn: "disks"}, {RefID: "B", MergeOn: "disks"}})
+
+		for _, refID := range []string{"A", "B"} {
+			require.Len(t, responses[refID].Frames, 1)
+			frame := responses[refID].Frames[0]
+			require.NotNil(t, frame.Meta)
+			require.Len(t, frame.Meta.Notices, 1)
+			assert.Equal(t, "The results could not be merged: query A has no disks column", frame.Meta.Notices[0].Text)
+		}
+	})
+}
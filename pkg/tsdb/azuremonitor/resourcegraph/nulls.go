@@ -0,0 +1,71 @@
+package resourcegraph
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Null policies set how nulls in numeric columns are returned.
+const (
+	// nullPolicyKeep returns nulls as they are, the default
+	nullPolicyKeep = "keepNull"
+	// nullPolicyZeroFill replaces nulls with 0
+	nullPolicyZeroFill = "zeroFill"
+	// nullPolicyDropRow removes the rows that have a null
+	nullPolicyDropRow = "dropRow"
+)
+
+func validNullPolicy(policy string) bool {
+	switch policy {
+	case "", nullPolicyKeep, nullPolicyZeroFill, nullPolicyDropRow:
+		return true
+	}
+	return false
+}
+
+// applyNullPolicy handles the nulls of the numeric fields of frame according to policy.
+// Non numeric fields, like strings and times, keep their nulls whatever the policy.
+func applyNullPolicy(frame *data.Frame, policy string) error {
+	var numeric []*data.Field
+	for _, field := range frame.Fields {
+		if field.Type().Numeric() && field.Nullable() {
+			numeric = append(numeric, field)
+		}
+	}
+	if len(numeric) == 0 {
+		return nil
+	}
+
+	switch policy {
+	case "", nullPolicyKeep:
+		return nil
+	case nullPolicyZeroFill:
+		for _, field := range numeric {
+			zero := data.NewFieldFromFieldType(field.Type().NonNullableType(), 1).At(0)
+			for i := 0; i < field.Len(); i++ {
+				if _, ok := field.ConcreteAt(i); !ok {
+					field.SetConcrete(i, zero)
+				}
+			}
+		}
+		return nil
+	case nullPolicyDropRow:
+		rowLen, err := frame.RowLen()
+		if err != nil {
+			return err
+		}
+		// delete from the end so that the indexes of the rows left to check don't shift
+		for row := rowLen - 1; row >= 0; row-- {
+			for _, field := range numeric {
+				if _, ok := field.ConcreteAt(row); !ok {
+					frame.DeleteRow(row)
+					break
+				}
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown null policy %q", policy)
+	}
+}
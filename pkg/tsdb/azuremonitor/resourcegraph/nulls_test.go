@@ -0,0 +1,118 @@
+package resourcegraph
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/loganalytics"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func TestApplyNullPolicy(t *testing.T) {
+	newFrame := func(t *testing.T) *data.Frame {
+		table := types.AzureResponseTable{}
+		d := json.NewDecoder(strings.NewReader(`{
+			"columns": [{"name": "name", "type": "string"}, {"name": "disks", "type": "long"}, {"name": "cpu", "type": "real"}],
+			"rows": [
+				["vm-1", 2, 0.5],
+				["vm-2", null, 0.25],
+				[null, 1, null],
+				["vm-4", 3, 0.75]
+			]
+		}`))
+		d.UseNumber()
+		require.NoError(t, d.Decode(&table))
+		frame, err := loganalytics.ResponseTableToFrame(&table)
+		require.NoError(t, err)
+		return frame
+	}
+
+	tests := []struct {
+		name   string
+		policy string
+		names  []*string
+		disks  []*int64
+		cpu    []*float64
+	}{
+		{
+			name:   "should keep nulls by default",
+			policy: "",
+			names:  []*string{strPtr("vm-1"), strPtr("vm-2"), nil, strPtr("vm-4")},
+			disks:  []*int64{int64Ptr(2), nil, int64Ptr(1), int64Ptr(3)},
+			cpu:    []*float64{float64Ptr(0.5), float64Ptr(0.25), nil, float64Ptr(0.75)},
+		},
+		{
+			name:   "should keep nulls",
+			policy: nullPolicyKeep,
+			names:  []*string{strPtr("vm-1"), strPtr("vm-2"), nil, strPtr("vm-4")},
+			disks:  []*int64{int64Ptr(2), nil, int64Ptr(1), int64Ptr(3)},
+			cpu:    []*float64{float64Ptr(0.5), float64Ptr(0.25), nil, float64Ptr(0.75)},
+		},
+		{
+			name:   "should replace numeric nulls with zero",
+			policy: nullPolicyZeroFill,
+			names:  []*string{strPtr("vm-1"), strPtr("vm-2"), nil, strPtr("vm-4")},
+			disks:  []*int64{int64Ptr(2), int64Ptr(0), int64Ptr(1), int64Ptr(3)},
+			cpu:    []*float64{float64Ptr(0.5), float64Ptr(0.25), float64Ptr(0), float64Ptr(0.75)},
+		},
+		{
+			name:   "should drop the rows with numeric nulls",
+			policy: nullPolicyDropRow,
+			names:  []*string{strPtr("vm-1"), strPtr("vm-4")},
+			disks:  []*int64{int64Ptr(2), int64Ptr(3)},
+			cpu:    []*float64{float64Ptr(0.5), float64Ptr(0.75)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame := newFrame(t)
+			require.NoError(t, applyNullPolicy(frame, tt.policy))
+
+			expected := data.NewFrame("",
+				data.NewField("name", nil, tt.names),
+				data.NewField("disks", nil, tt.disks),
+				data.NewField("cpu", nil, tt.cpu),
+			)
+			for i, field := range expected.Fields {
+				assert.Equal(t, field.Name, frame.Fields[i].Name)
+				require.Equal(t, field.Len(), frame.Fields[i].Len(), field.Name)
+				for row := 0; row < field.Len(); row++ {
+					assert.Equal(t, field.At(row), frame.Fields[i].At(row), "%s row %d", field.Name, row)
+				}
+			}
+		})
+	}
+
+	t.Run("should reject an unknown policy", func(t *testing.T) {
+		require.Error(t, applyNullPolicy(newFrame(t), "interpolate"))
+	})
+}
+
+func TestBuildingAzureResourceGraphQueriesNullPolicy(t *testing.T) {
+	datasource := &AzureResourceGraphDatasource{}
+	query := func(policy string) []backend.DataQuery {
+		return []backend.DataQuery{{
+			RefID: "A",
+			JSON:  []byte(`{"azureResourceGraph": {"query": "resources", "nullPolicy": "` + policy + `"}}`),
+		}}
+	}
+
+	queries, err := datasource.buildQueries(query(nullPolicyZeroFill), types.DatasourceInfo{})
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	assert.Equal(t, nullPolicyZeroFill, queries[0].NullPolicy)
+
+	_, err = datasource.buildQueries(query("interpolate"), types.DatasourceInfo{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown null policy "interpolate"`)
+}
@@ -0,0 +1,115 @@
+package resourcegraph
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/azlog"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+const (
+	// maxRequestRetries caps the configured retry count so a failing query can't hang a panel for long.
+	maxRequestRetries = 5
+	// retryBaseBackoff is the backoff before the first retry, doubled for every following one.
+	retryBaseBackoff = 500 * time.Millisecond
+	// retryMaxBackoff caps the backoff between two attempts.
+	retryMaxBackoff = 5 * time.Second
+	// retryMaxAfter caps the wait a Retry-After header can ask for.
+	retryMaxAfter = 30 * time.Second
+)
+
+// retryBackoff returns how long to wait before the given retry, starting at 0.
+// It uses exponential backoff with full jitter. Tests replace it to avoid sleeping.
+var retryBackoff = func(retry int) time.Duration {
+	backoff := retryBaseBackoff << retry
+	if backoff <= 0 || backoff > retryMaxBackoff {
+		backoff = retryMaxBackoff
+	}
+	return time.Duration(rand.Int63n(int64(backoff)))
+}
+
+// doWithRetry sends req and retries connection errors and retryable statuses (429 and the
+// transient 5xx, see isRetryableStatus) up to maxRetries times. Other responses are returned
+// straight away. 429 and 503 responses are retried after their Retry-After header when they have one.
+func doWithRetry(ctx context.Context, client *http.Client, req *http.Request, maxRetries int) (*http.Response, error) {
+	if maxRetries > maxRequestRetries {
+		maxRetries = maxRequestRetries
+	}
+
+	for retry := 0; ; retry++ {
+		attempt := req
+		if retry > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt = req.Clone(ctx)
+			attempt.Body = body
+		}
+
+		res, err := ctxhttp.Do(ctx, client, attempt)
+		if retry >= maxRetries || ctx.Err() != nil {
+			return res, err
+		}
+		if err == nil && !isRetryableStatus(res.StatusCode) {
+			return res, nil
+		}
+
+		if err != nil {
+			azlog.Debug("Retrying Azure Resource Graph request", "retry", retry+1, "error", err)
+		} else {
+			azlog.Debug("Retrying Azure Resource Graph request", "retry", retry+1, "status", res.Status)
+			if err := res.Body.Close(); err != nil {
+				azlog.Warn("Failed to close response body", "err", err)
+			}
+		}
+
+		// throttled and unavailable responses may say when to try again
+		wait := retryBackoff(retry)
+		if err == nil && (res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable) {
+			if after, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				wait = after
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// parseRetryAfter returns the wait asked for by a Retry-After header, given either in seconds or as an HTTP
+// date, capped at retryMaxAfter. It returns false when the header is missing or invalid.
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		wait = date.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+	} else {
+		return 0, false
+	}
+
+	if wait > retryMaxAfter {
+		wait = retryMaxAfter
+	}
+	return wait, true
+}
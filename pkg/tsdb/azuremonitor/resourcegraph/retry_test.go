@@ -0,0 +1,235 @@
+package resourcegraph
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestDoWithRetry(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = func(int) time.Duration { return 0 }
+	t.Cleanup(func() { retryBackoff = backoff })
+
+	respond := func(status int) *http.Response {
+		return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: ioutil.NopCloser(bytes.NewReader(nil))}
+	}
+
+	// newClient answers with the given results in turn and records the request bodies it received.
+	newClient := func(results ...interface{}) (*http.Client, *[]string) {
+		var bodies []string
+		return &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			bodies = append(bodies, string(body))
+
+			result := results[0]
+			if len(results) > 1 {
+				results = results[1:]
+			}
+			if err, ok := result.(error); ok {
+				return nil, err
+			}
+			return respond(result.(int)), nil
+		})}, &bodies
+	}
+
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://arg.local/", bytes.NewBufferString(`{"query":"resources"}`))
+		require.NoError(t, err)
+		return req
+	}
+
+	t.Run("should succeed after two failed attempts", func(t *testing.T) {
+		client, bodies := newClient(errors.New("connection reset"), http.StatusBadGateway, http.StatusOK)
+
+		res, err := doWithRetry(context.Background(), client, newRequest(), 3)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		require.Len(t, *bodies, 3)
+		for _, body := range *bodies {
+			assert.Equal(t, `{"query":"resources"}`, body)
+		}
+	})
+
+	t.Run("should give up after the configured number of retries", func(t *testing.T) {
+		client, bodies := newClient(http.StatusServiceUnavailable)
+
+		res, err := doWithRetry(context.Background(), client, newRequest(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		assert.Len(t, *bodies, 3)
+	})
+
+	t.Run("should retry a throttled request", func(t *testing.T) {
+		client, bodies := newClient(http.StatusTooManyRequests, http.StatusOK)
+
+		res, err := doWithRetry(context.Background(), client, newRequest(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, *bodies, 2)
+	})
+
+	t.Run("should not retry other client errors", func(t *testing.T) {
+		client, bodies := newClient(http.StatusBadRequest, http.StatusOK)
+
+		res, err := doWithRetry(context.Background(), client, newRequest(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		assert.Len(t, *bodies, 1)
+	})
+
+	t.Run("should wait as long as Retry-After asks instead of backing off", func(t *testing.T) {
+		retryBackoff = func(int) time.Duration { return time.Hour }
+		t.Cleanup(func() { retryBackoff = func(int) time.Duration { return 0 } })
+
+		attempts := 0
+		client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				res := respond(http.StatusTooManyRequests)
+				res.Header = http.Header{"Retry-After": []string{"0"}}
+				return res, nil
+			}
+			return respond(http.StatusOK), nil
+		})}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		res, err := doWithRetry(ctx, client, newRequest(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("should cap the number of retries", func(t *testing.T) {
+		client, bodies := newClient(errors.New("connection refused"))
+
+		_, err := doWithRetry(context.Background(), client, newRequest(), 100)
+		require.Error(t, err)
+		assert.Len(t, *bodies, maxRequestRetries+1)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		desc     string
+		header   string
+		expected time.Duration
+		ok       bool
+	}{
+		{desc: "seconds", header: "10", expected: 10 * time.Second, ok: true},
+		{desc: "zero seconds", header: "0", expected: 0, ok: true},
+		{desc: "date", header: now.Add(20 * time.Second).Format(http.TimeFormat), expected: 20 * time.Second, ok: true},
+		{desc: "date in the past", header: now.Add(-time.Minute).Format(http.TimeFormat), expected: 0, ok: true},
+		{desc: "capped wait", header: "3600", expected: retryMaxAfter, ok: true},
+		{desc: "missing header", header: "", ok: false},
+		{desc: "negative seconds", header: "-1", ok: false},
+		{desc: "invalid header", header: "soon", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			wait, ok := parseRetryAfter(tc.header, now)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, wait)
+		})
+	}
+}
+
+func TestExecuteQueryRetries(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = func(int) time.Duration { return 0 }
+	t.Cleanup(func() { retryBackoff = backoff })
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{MaxRetries: 2}
+	dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic}
+	queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources"}}`)}}
+
+	// newServer throttles the given number of requests and answers the following ones
+	newServer := func(throttled int) (*httptest.Server, *int) {
+		requests := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests <= throttled {
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, err := w.Write([]byte(`{"error":{"code":"RateLimiting","message":"Please provide below info when asking for support: timestamp = 2022-03-01T12:00:00Z, correlationId = 1234."}}`))
+				require.NoError(t, err)
+				return
+			}
+			_, err := w.Write([]byte(`{"data":{"columns":[{"name":"name","type":"string"}],"rows":[["res1"]]}}`))
+			require.NoError(t, err)
+		}))
+		t.Cleanup(srv.Close)
+		return srv, &requests
+	}
+
+	t.Run("should succeed once throttling stops", func(t *testing.T) {
+		srv, requests := newServer(1)
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.Equal(t, 2, *requests)
+		require.Len(t, res.Responses["A"].Frames, 1)
+		assert.Equal(t, 1, res.Responses["A"].Frames[0].Rows())
+	})
+
+	t.Run("should report persistent throttling", func(t *testing.T) {
+		srv, requests := newServer(100)
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		assert.Equal(t, 3, *requests)
+
+		queryErr := res.Responses["A"].Error
+		require.Error(t, queryErr)
+		assert.Contains(t, queryErr.Error(), "still throttled by Azure Resource Graph")
+		var argErr *AzureResourceGraphError
+		require.True(t, errors.As(queryErr, &argErr))
+		assert.Equal(t, http.StatusTooManyRequests, argErr.StatusCode)
+
+		require.Len(t, res.Responses["A"].Frames, 1)
+		meta, ok := res.Responses["A"].Frames[0].Meta.Custom.(*AzureResourceGraphMeta)
+		require.True(t, ok)
+		assert.Equal(t, "RateLimiting", meta.ErrorCode)
+		assert.Equal(t, "1234", meta.CorrelationID)
+	})
+
+	t.Run("should time out after the query timeout of the datasource", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		t.Cleanup(srv.Close)
+
+		dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic, Settings: types.AzureMonitorSettings{QueryTimeout: 1}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+
+		queryErr := res.Responses["A"].Error
+		require.Error(t, queryErr)
+		assert.Contains(t, queryErr.Error(), "query timed out after 1s")
+		assert.True(t, errors.Is(queryErr, context.DeadlineExceeded))
+	})
+}
@@ -0,0 +1,109 @@
+package resourcegraph
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// maxPartitionedSeries caps the number of distinct seriesBy values turned into series.
+// Rows for any further values are dropped and a notice is added to the frame.
+const maxPartitionedSeries = 100
+
+// partitionBySeries turns a long table into a wide time series frame with one field
+// per numeric column and distinct value of the seriesBy column. The value is set as
+// the field's label, keyed by the column name.
+func partitionBySeries(frame *data.Frame, seriesBy string) (*data.Frame, error) {
+	timeIdx, groupIdx := -1, -1
+	var valueIdxs []int
+	for i, field := range frame.Fields {
+		switch {
+		case field.Name == seriesBy:
+			groupIdx = i
+		case timeIdx == -1 && field.Type().Time():
+			timeIdx = i
+		case field.Type().Numeric():
+			valueIdxs = append(valueIdxs, i)
+		}
+	}
+	if groupIdx == -1 {
+		return nil, fmt.Errorf("column %q not found in the query result", seriesBy)
+	}
+	if timeIdx == -1 {
+		return nil, fmt.Errorf("the query result has no time column")
+	}
+
+	type point struct {
+		row   int
+		time  time.Time
+		group int
+	}
+
+	var points []point
+	var groups []string
+	groupIndex := map[string]int{}
+	truncated := false
+	for row := 0; row < frame.Rows(); row++ {
+		t, ok := frame.Fields[timeIdx].ConcreteAt(row)
+		if !ok {
+			continue
+		}
+		g, ok := frame.Fields[groupIdx].ConcreteAt(row)
+		if !ok {
+			continue
+		}
+		group := fmt.Sprint(g)
+		idx, seen := groupIndex[group]
+		if !seen {
+			if len(groups) == maxPartitionedSeries {
+				truncated = true
+				continue
+			}
+			idx = len(groups)
+			groupIndex[group] = idx
+			groups = append(groups, group)
+		}
+		points = append(points, point{row: row, time: t.(time.Time), group: idx})
+	}
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].time.Before(points[j].time) })
+
+	var times []time.Time
+	timeIndex := map[time.Time]int{}
+	for _, p := range points {
+		if _, ok := timeIndex[p.time]; !ok {
+			timeIndex[p.time] = len(times)
+			times = append(times, p.time)
+		}
+	}
+
+	wide := data.NewFrame(frame.Name, data.NewField(frame.Fields[timeIdx].Name, nil, times))
+	wide.Meta = frame.Meta
+	for _, valueIdx := range valueIdxs {
+		values := make([][]*float64, len(groups))
+		for i := range values {
+			values[i] = make([]*float64, len(times))
+		}
+		for _, p := range points {
+			v, err := frame.Fields[valueIdx].NullableFloatAt(p.row)
+			if err != nil {
+				return nil, err
+			}
+			values[p.group][timeIndex[p.time]] = v
+		}
+		for i, group := range groups {
+			wide.Fields = append(wide.Fields, data.NewField(frame.Fields[valueIdx].Name, data.Labels{seriesBy: group}, values[i]))
+		}
+	}
+
+	if truncated {
+		wide.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("the query returned more than %d distinct values for %q, only the first %d are shown", maxPartitionedSeries, seriesBy, maxPartitionedSeries),
+		})
+	}
+
+	return wide, nil
+}
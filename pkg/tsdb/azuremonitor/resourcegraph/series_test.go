@@ -0,0 +1,82 @@
+package resourcegraph
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionBySeries(t *testing.T) {
+	t1 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	float := func(f float64) *float64 { return &f }
+
+	t.Run("should produce one series per group value", func(t *testing.T) {
+		frame := data.NewFrame("",
+			data.NewField("timestamp", nil, []*time.Time{&t2, &t1, &t1, &t2}),
+			data.NewField("location", nil, []*string{strPtr("eastus"), strPtr("eastus"), strPtr("westus"), strPtr("westus")}),
+			data.NewField("count", nil, []*float64{float(2), float(1), float(3), float(4)}),
+		)
+
+		wide, err := partitionBySeries(frame, "location")
+		require.NoError(t, err)
+		require.Len(t, wide.Fields, 3)
+
+		assert.Equal(t, "timestamp", wide.Fields[0].Name)
+		assert.Equal(t, t1, wide.Fields[0].At(0))
+		assert.Equal(t, t2, wide.Fields[0].At(1))
+
+		assert.Equal(t, "count", wide.Fields[1].Name)
+		assert.Equal(t, data.Labels{"location": "eastus"}, wide.Fields[1].Labels)
+		assert.Equal(t, float(1), wide.Fields[1].At(0))
+		assert.Equal(t, float(2), wide.Fields[1].At(1))
+
+		assert.Equal(t, "count", wide.Fields[2].Name)
+		assert.Equal(t, data.Labels{"location": "westus"}, wide.Fields[2].Labels)
+		assert.Equal(t, float(3), wide.Fields[2].At(0))
+		assert.Equal(t, float(4), wide.Fields[2].At(1))
+	})
+
+	t.Run("should cap the number of series", func(t *testing.T) {
+		rows := maxPartitionedSeries + 5
+		times := make([]time.Time, rows)
+		groups := make([]string, rows)
+		values := make([]float64, rows)
+		for i := 0; i < rows; i++ {
+			times[i] = t1
+			groups[i] = fmt.Sprintf("group-%d", i)
+			values[i] = float64(i)
+		}
+		frame := data.NewFrame("",
+			data.NewField("timestamp", nil, times),
+			data.NewField("group", nil, groups),
+			data.NewField("value", nil, values),
+		)
+
+		wide, err := partitionBySeries(frame, "group")
+		require.NoError(t, err)
+		assert.Len(t, wide.Fields, maxPartitionedSeries+1)
+		require.NotNil(t, wide.Meta)
+		require.Len(t, wide.Meta.Notices, 1)
+		assert.Equal(t, data.NoticeSeverityWarning, wide.Meta.Notices[0].Severity)
+	})
+
+	t.Run("should fail when the column is missing", func(t *testing.T) {
+		frame := data.NewFrame("",
+			data.NewField("timestamp", nil, []time.Time{t1}),
+			data.NewField("value", nil, []float64{1}),
+		)
+
+		_, err := partitionBySeries(frame, "location")
+		assert.Error(t, err)
+	})
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,80 @@
+package resourcegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	// tagsColumn is the column Azure Resource Graph returns resource tags in.
+	tagsColumn = "tags"
+	// tagColumnPrefix prefixes the flattened tag columns added to the frame.
+	tagColumnPrefix = "tag."
+	// defaultMaxTagColumns is used when no limit is configured.
+	defaultMaxTagColumns = 50
+)
+
+// expandTags adds a tag.<key> string column for every tag key found in the tags column.
+// Keys are added in alphabetical order, at most maxColumns of them; when there are more
+// a notice is added to the frame. Frames without a tags column are left untouched.
+func expandTags(frame *data.Frame, maxColumns int) error {
+	if maxColumns <= 0 {
+		maxColumns = defaultMaxTagColumns
+	}
+
+	tagsField, _ := frame.FieldByName(tagsColumn)
+	if tagsField == nil {
+		return nil
+	}
+	if tagsField.Type() != data.FieldTypeNullableString {
+		return fmt.Errorf("unexpected type %s for the %s column", tagsField.Type(), tagsColumn)
+	}
+
+	rows := make([]map[string]interface{}, tagsField.Len())
+	keySet := map[string]struct{}{}
+	for i := 0; i < tagsField.Len(); i++ {
+		raw, ok := tagsField.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		tags := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(raw.(string)), &tags); err != nil {
+			return fmt.Errorf("failed to parse the tags of row %d: %w", i, err)
+		}
+		rows[i] = tags
+		for key := range tags {
+			keySet[key] = struct{}{}
+		}
+	}
+
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > maxColumns {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("The result has %d tag keys, only the first %d are shown as columns", len(keys), maxColumns),
+		})
+		keys = keys[:maxColumns]
+	}
+
+	for _, key := range keys {
+		values := make([]*string, len(rows))
+		for i, tags := range rows {
+			value, ok := tags[key]
+			if !ok || value == nil {
+				continue
+			}
+			s := fmt.Sprint(value)
+			values[i] = &s
+		}
+		frame.Fields = append(frame.Fields, data.NewField(tagColumnPrefix+key, nil, values))
+	}
+
+	return nil
+}
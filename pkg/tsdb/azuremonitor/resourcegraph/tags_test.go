@@ -0,0 +1,69 @@
+package resourcegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/loganalytics"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandTags(t *testing.T) {
+	t.Run("should add a column per tag key of a tagged resource", func(t *testing.T) {
+		table := types.AzureResponseTable{}
+		err := json.Unmarshal([]byte(`{
+			"columns": [{"name": "name", "type": "string"}, {"name": "tags", "type": "object"}],
+			"rows": [
+				["vm-1", {"env": "prod", "team": "search"}],
+				["vm-2", {"env": "dev"}],
+				["vm-3", null]
+			]
+		}`), &table)
+		require.NoError(t, err)
+		frame, err := loganalytics.ResponseTableToFrame(&table)
+		require.NoError(t, err)
+
+		require.NoError(t, expandTags(frame, 10))
+		require.Len(t, frame.Fields, 4)
+
+		env, _ := frame.FieldByName("tag.env")
+		require.NotNil(t, env)
+		assert.Equal(t, strPtr("prod"), env.At(0))
+		assert.Equal(t, strPtr("dev"), env.At(1))
+		assert.Nil(t, env.At(2))
+
+		team, _ := frame.FieldByName("tag.team")
+		require.NotNil(t, team)
+		assert.Equal(t, strPtr("search"), team.At(0))
+		assert.Nil(t, team.At(1))
+		assert.Empty(t, frame.Meta.Notices)
+	})
+
+	t.Run("should cap the number of tag columns", func(t *testing.T) {
+		tags := map[string]string{}
+		for i := 0; i < 5; i++ {
+			tags[fmt.Sprintf("key%d", i)] = "value"
+		}
+		raw, err := json.Marshal(tags)
+		require.NoError(t, err)
+		frame := data.NewFrame("", data.NewField("tags", nil, []*string{strPtr(string(raw))}))
+
+		require.NoError(t, expandTags(frame, 2))
+		require.Len(t, frame.Fields, 3)
+		assert.Equal(t, "tag.key0", frame.Fields[1].Name)
+		assert.Equal(t, "tag.key1", frame.Fields[2].Name)
+		require.Len(t, frame.Meta.Notices, 1)
+		assert.Equal(t, data.NoticeSeverityWarning, frame.Meta.Notices[0].Severity)
+	})
+
+	t.Run("should leave frames without a tags column untouched", func(t *testing.T) {
+		frame := data.NewFrame("", data.NewField("name", nil, []*string{strPtr("vm-1")}))
+
+		require.NoError(t, expandTags(frame, 10))
+		require.Len(t, frame.Fields, 1)
+	})
+}
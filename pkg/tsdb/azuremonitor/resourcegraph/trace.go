@@ -0,0 +1,78 @@
+package resourcegraph
+
+import (
+	"encoding/json"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// traceFieldName is the name of the field holding the response of trace queries.
+const traceFieldName = "response"
+
+// traceFrame returns a frame with a single row holding the raw body of an Azure Resource Graph response.
+// It helps debugging queries whose columns aren't inferred as expected.
+func traceFrame(body []byte) *data.Frame {
+	return data.NewFrame("", data.NewField(traceFieldName, nil, []string{string(body)}))
+}
+
+// dropRawColumns removes the columns named in columns, and their values in every row, from the raw body of an
+// Azure Resource Graph response. Trace queries return the body as it is, so dropColumns can't apply to them.
+func dropRawColumns(body []byte, columns []string) ([]byte, error) {
+	if len(columns) == 0 {
+		return body, nil
+	}
+
+	var res map[string]json.RawMessage
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	var table map[string]json.RawMessage
+	if err := json.Unmarshal(res["data"], &table); err != nil || table == nil {
+		return body, err
+	}
+	var names []struct {
+		Name string `json:"name"`
+	}
+	var rawColumns []json.RawMessage
+	var rows [][]json.RawMessage
+	if err := json.Unmarshal(table["columns"], &names); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(table["columns"], &rawColumns); err != nil {
+		return nil, err
+	}
+	if rawRows, ok := table["rows"]; ok {
+		if err := json.Unmarshal(rawRows, &rows); err != nil {
+			return nil, err
+		}
+	}
+
+	keptColumns := make([]json.RawMessage, 0, len(rawColumns))
+	keptRows := make([][]json.RawMessage, len(rows))
+	for j := range keptRows {
+		keptRows[j] = []json.RawMessage{}
+	}
+	for i, column := range names {
+		if isDropped(column.Name, columns) {
+			continue
+		}
+		keptColumns = append(keptColumns, rawColumns[i])
+		for j, row := range rows {
+			if i < len(row) {
+				keptRows[j] = append(keptRows[j], row[i])
+			}
+		}
+	}
+
+	var err error
+	if table["columns"], err = json.Marshal(keptColumns); err != nil {
+		return nil, err
+	}
+	if table["rows"], err = json.Marshal(keptRows); err != nil {
+		return nil, err
+	}
+	if res["data"], err = json.Marshal(table); err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
@@ -0,0 +1,89 @@
+package resourcegraph
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceFrame(t *testing.T) {
+	frame := traceFrame([]byte(`{"data":{"rows":[]}}`))
+
+	require.Len(t, frame.Fields, 1)
+	assert.Equal(t, traceFieldName, frame.Fields[0].Name)
+	require.Equal(t, 1, frame.Rows())
+	assert.Equal(t, `{"data":{"rows":[]}}`, frame.Fields[0].At(0))
+}
+
+func TestDropRawColumns(t *testing.T) {
+	body := []byte(`{"count":2,"data":{"columns":[{"name":"name","type":"string"},{"name":"Secret","type":"string"},` +
+		`{"name":"cores","type":"long"}],"rows":[["vm1","s1",4],["vm2","s2",8]]},"resultTruncated":"false"}`)
+
+	t.Run("should return the body as it is without drop columns", func(t *testing.T) {
+		res, err := dropRawColumns(body, nil)
+		require.NoError(t, err)
+		assert.Equal(t, string(body), string(res))
+	})
+
+	t.Run("should remove the columns and their values", func(t *testing.T) {
+		res, err := dropRawColumns(body, []string{"secret"})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"count":2,"data":{"columns":[{"name":"name","type":"string"},{"name":"cores","type":"long"}],`+
+			`"rows":[["vm1",4],["vm2",8]]},"resultTruncated":"false"}`, string(res))
+	})
+
+	t.Run("should fail on a body that isn't JSON", func(t *testing.T) {
+		_, err := dropRawColumns([]byte("not json"), []string{"secret"})
+		require.Error(t, err)
+	})
+}
+
+func TestExecuteQueryTraceFormat(t *testing.T) {
+	rawBody := `{"totalRecords":2,"count":2,"data":{"columns":[{"name":"name","type":"string"},{"name":"cores","type":"long"}],` +
+		`"rows":[["vm1",4],["vm2",8]]},"resultTruncated":"false"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(rawBody))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	datasource := &AzureResourceGraphDatasource{}
+	dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic}
+	queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"azureResourceGraph": {"query": "resources", "resultFormat": "trace"}}`)}}
+
+	res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+	require.NoError(t, err)
+	require.NoError(t, res.Responses["A"].Error)
+	require.Len(t, res.Responses["A"].Frames, 1)
+
+	frame := res.Responses["A"].Frames[0]
+	require.Len(t, frame.Fields, 1)
+	assert.Equal(t, traceFieldName, frame.Fields[0].Name)
+	require.Equal(t, 1, frame.Rows())
+	assert.Equal(t, rawBody, frame.Fields[0].At(0))
+	assert.NotEmpty(t, frame.Meta.ExecutedQueryString)
+
+	t.Run("should not return dropped columns", func(t *testing.T) {
+		dsInfo := types.DatasourceInfo{Cloud: setting.AzurePublic, Settings: types.AzureMonitorSettings{ResourceGraphDropColumns: []string{"cores"}}}
+		res, err := datasource.ExecuteTimeSeriesQuery(context.Background(), queries, dsInfo, srv.Client(), srv.URL, tracer)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+
+		frame := res.Responses["A"].Frames[0]
+		require.Equal(t, 1, frame.Rows())
+		assert.JSONEq(t, `{"totalRecords":2,"count":2,"data":{"columns":[{"name":"name","type":"string"}],"rows":[["vm1"],["vm2"]]},`+
+			`"resultTruncated":"false"}`, frame.Fields[0].At(0).(string))
+	})
+}
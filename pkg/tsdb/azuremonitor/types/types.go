@@ -13,6 +13,13 @@ import (
 
 const (
 	TimeSeries = "time_series"
+	Table      = "table"
+	// AutoResultFormat picks TimeSeries or Table from the schema of the result.
+	AutoResultFormat = "auto"
+	// Long reshapes Azure Resource Graph results into label, metric and value columns.
+	Long = "long"
+	// Trace returns the raw Azure Resource Graph response in a single field, for debugging queries.
+	Trace = "trace"
 )
 
 var (
@@ -29,6 +36,18 @@ type AzureMonitorSettings struct {
 	SubscriptionId               string `json:"subscriptionId"`
 	LogAnalyticsDefaultWorkspace string `json:"logAnalyticsDefaultWorkspace"`
 	AppInsightsAppId             string `json:"appInsightsAppId"`
+	// ResourceGraphURL replaces the Azure Resource Graph endpoint, e.g. with a mock for offline tests.
+	// It is only honored when the server allows it.
+	ResourceGraphURL string `json:"resourceGraphUrl"`
+	// ResourceGraphDropColumns lists columns removed from Azure Resource Graph results before they are returned.
+	// It is part of the datasource settings so that queries can't opt out of it.
+	ResourceGraphDropColumns []string `json:"resourceGraphDropColumns"`
+	// ResourceGraphMaxRows stops requesting the following pages of an Azure Resource Graph result once it has
+	// that many rows, 0 uses the default.
+	ResourceGraphMaxRows int `json:"resourceGraphMaxRows"`
+	// QueryTimeout is how many seconds an Azure Resource Graph query may take, including all its pages and retries.
+	// 0 means no timeout.
+	QueryTimeout int `json:"queryTimeout"`
 }
 
 type DatasourceService struct {
@@ -46,6 +65,8 @@ type DatasourceInfo struct {
 	JSONData                map[string]interface{}
 	DecryptedSecureJSONData map[string]string
 	DatasourceID            int64
+	DatasourceUID           string
+	DatasourceName          string
 	OrgID                   int64
 }
 
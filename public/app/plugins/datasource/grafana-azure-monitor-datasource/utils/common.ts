@@ -36,6 +36,7 @@ export const routeNames = {
   logAnalytics: 'loganalytics',
   appInsights: 'appinsights',
   resourceGraph: 'resourcegraph',
+  resourceGraphEstimate: 'resourcegraph-estimate',
 };
 
 export function interpolateVariable(value: any, variable: { multi: any; includeAll: any }) {